@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -18,9 +19,11 @@ import (
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	kubeaggregatorv1beta1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -28,6 +31,11 @@ import (
 
 	"github.com/goodrain/rainbond-operator/pkg/apis"
 	"github.com/goodrain/rainbond-operator/pkg/controller"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rainbondcluster"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	rbdk8sutil "github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+	"github.com/goodrain/rainbond-operator/pkg/webhook"
 	"github.com/goodrain/rainbond-operator/version"
 )
 
@@ -37,6 +45,17 @@ var (
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
 )
+
+var (
+	enableWebhooks bool
+	webhookPort    int
+	webhookCertDir string
+	pruneOrphans   bool
+	upgradeTimeout time.Duration
+	healthInterval time.Duration
+	dbFailover     time.Duration
+	ingressClass   string
+)
 var log = logf.Log.WithName("cmd")
 
 func printVersion() {
@@ -47,6 +66,14 @@ func printVersion() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := render(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Add the zap logger flag set to the CLI. The flag set must
 	// be added before calling pflag.Parse().
 	pflag.CommandLine.AddFlagSet(zap.FlagSet())
@@ -55,6 +82,15 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	pflag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Enable the conversion, validating and defaulting webhooks of rainbond.io CRDs. The serving certificate is generated and the CRDs are switched to the conversion webhook and the v1beta1 storage version on start. If disabled, they are switched back to no conversion and v1alpha1, which fails once objects are stored as v1beta1.")
+	pflag.IntVar(&webhookPort, "webhook-port", 9443, "The port that the webhook server serves at.")
+	pflag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "The directory that the generated tls.crt and tls.key of the webhook server are written to.")
+	pflag.BoolVar(&pruneOrphans, "prune-orphans", false, "Delete the resources owned by a RbdComponent that are no longer produced for it. If false, they are only logged.")
+	pflag.DurationVar(&upgradeTimeout, "component-upgrade-timeout", rainbondcluster.ComponentUpgradeTimeout, "How long a component has to become ready during an upgrade before the upgrade is rolled back.")
+	pflag.DurationVar(&healthInterval, "health-check-interval", rbdcomponent.HealthCheckInterval, "How often the application level health checks of components are run.")
+	pflag.DurationVar(&dbFailover, "db-failover-timeout", chandler.DBFailoverTimeout, "How long the primary of rbd-db may stay unready in the highly available mode before a replica is promoted.")
+	pflag.StringVar(&ingressClass, "ingress-class", "", "The class of the Ingresses created for the components. If empty, the default class of the cluster is used.")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -95,6 +131,8 @@ func main() {
 		Namespace:          namespace,
 		MapperProvider:     restmapper.NewDynamicRESTMapper,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
+		CertDir:            webhookCertDir,
 	})
 	if err != nil {
 		log.Error(err, "")
@@ -113,12 +151,56 @@ func main() {
 		os.Exit(1)
 	}
 
+	rbdcomponent.PruneOrphans = pruneOrphans
+	rainbondcluster.ComponentUpgradeTimeout = upgradeTimeout
+	rbdcomponent.HealthCheckInterval = healthInterval
+	chandler.DBFailoverTimeout = dbFailover
+	chandler.RESTMapper = mgr.GetRESTMapper()
+	rbdcomponent.IngressClass = ingressClass
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		log.Error(err, "create discovery client")
+		os.Exit(1)
+	}
+	ingressVersion, err := rbdk8sutil.ServedIngressVersion(dc)
+	if err != nil {
+		log.Error(err, "detect the served group version of Ingress")
+		os.Exit(1)
+	}
+	log.Info("Detected the group version of Ingress", "GroupVersion", ingressVersion.String())
+	rbdcomponent.IngressVersion = ingressVersion
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	// Setup all Webhooks
+	// The cache of the manager is not started yet, so the certificate and the CRDs are set up with a direct client.
+	apiClient, err := client.New(cfg, client.Options{})
+	if err != nil {
+		log.Error(err, "create client")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		operatorNs, err := k8sutil.GetOperatorNamespace()
+		if err != nil {
+			log.Info("Could not get the operator namespace, using the watch namespace", "error", err.Error())
+			operatorNs = namespace
+		}
+		if err := webhook.Bootstrap(ctx, apiClient, operatorNs, webhookCertDir); err != nil {
+			log.Error(err, "bootstrap webhooks")
+			os.Exit(1)
+		}
+		if err := webhook.AddToManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	} else if err := webhook.DisableConversion(ctx, apiClient); err != nil {
+		log.Error(err, "disable conversion webhook")
+		os.Exit(1)
+	}
+
 	if err = serveCRMetrics(cfg); err != nil {
 		log.Info("Could not generate and serve custom resource metrics", "error", err.Error())
 	}
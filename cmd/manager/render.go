@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	kubeaggregatorv1beta1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/goodrain/rainbond-operator/pkg/apis"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent"
+	rbdk8sutil "github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+// render writes the resources that the operator creates for the rainbondcluster in the given file as
+// multi-document YAML, without talking to the apiserver.
+func render(args []string) error {
+	fs := pflag.NewFlagSet("render", pflag.ExitOnError)
+	filename := fs.StringP("filename", "f", "", "The file that contains the RainbondCluster, and optionally the RainbondPackage, RbdComponents and Secrets. Use - to read from stdin.")
+	namespace := fs.String("namespace", "rbd-system", "The namespace of the objects in the file that have none.")
+	ingressVersion := fs.String("ingress-api-version", rbdk8sutil.IngressExtensionsV1beta1.String(), "The group version of the Ingresses, one of networking.k8s.io/v1, networking.k8s.io/v1beta1 and extensions/v1beta1.")
+	fs.StringVar(&rbdcomponent.IngressClass, "ingress-class", "", "The class of the Ingresses.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *filename == "" {
+		return fmt.Errorf("--filename is required")
+	}
+	switch gv := *ingressVersion; gv {
+	case rbdk8sutil.IngressNetworkingV1.String(), rbdk8sutil.IngressNetworkingV1beta1.String(), rbdk8sutil.IngressExtensionsV1beta1.String():
+		rbdcomponent.IngressVersion, _ = schema.ParseGroupVersion(gv)
+	default:
+		return fmt.Errorf("unsupported --ingress-api-version %s", gv)
+	}
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, kubeaggregatorv1beta1.AddToScheme, apis.AddToScheme} {
+		if err := add(scheme); err != nil {
+			return err
+		}
+	}
+
+	in := os.Stdin
+	if *filename != "-" {
+		f, err := os.Open(*filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	objs, err := decodeObjects(scheme, in, *namespace)
+	if err != nil {
+		return fmt.Errorf("decode %s: %v", *filename, err)
+	}
+
+	result, err := rbdcomponent.Render(context.Background(), scheme, objs...)
+	if err != nil {
+		return err
+	}
+	var skipped []string
+	for name := range result.Skipped {
+		skipped = append(skipped, name)
+	}
+	sort.Strings(skipped)
+	for _, name := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: %s\n", name, result.Skipped[name])
+	}
+
+	return encodeObjects(os.Stdout, result.Resources)
+}
+
+// decodeObjects decodes the objects in the multi-document YAML or JSON.
+func decodeObjects(scheme *runtime.Scheme, in io.Reader, namespace string) ([]runtime.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(in))
+	var objs []runtime.Object
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accessor, ok := obj.(metav1.Object); ok && accessor.GetNamespace() == "" {
+			accessor.SetNamespace(namespace)
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// encodeObjects writes the objects as multi-document YAML.
+func encodeObjects(out io.Writer, objs []runtime.Object) error {
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
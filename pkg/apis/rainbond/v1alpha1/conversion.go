@@ -0,0 +1,445 @@
+package v1alpha1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+)
+
+// ConvertTo converts this RainbondCluster to the Hub version (v1beta1).
+func (in *RainbondCluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.RainbondCluster)
+	dst.ObjectMeta = *in.ObjectMeta.DeepCopy()
+
+	spec := in.Spec.DeepCopy()
+	// the secrets are saved by the defaulting webhook or the rainbondcluster controller
+	spec.MoveInlinePasswords()
+	dst.Spec = v1beta1.RainbondClusterSpec{
+		RainbondImageRepository: spec.RainbondImageRepository,
+		SuffixHTTPHost:          spec.SuffixHTTPHost,
+		GatewayIngressIPs:       spec.GatewayIngressIPs,
+		InstallMode:             v1beta1.InstallMode(spec.InstallMode),
+		StorageClassName:        spec.StorageClassName,
+		InstallVersion:          spec.InstallVersion,
+		ConfigCompleted:         spec.ConfigCompleted,
+		PurgeData:               spec.PurgeData,
+		NodeSelector:            spec.NodeSelector,
+		InstallPackageConfig: v1beta1.InstallPackageConfig{
+			URL:     spec.InstallPackageConfig.URL,
+			MD5:     spec.InstallPackageConfig.MD5,
+			Version: spec.InstallPackageConfig.Version,
+		},
+		RainbondShareStorage: v1beta1.RainbondShareStorage{
+			StorageClassName: spec.RainbondShareStorage.StorageClassName,
+		},
+	}
+	for _, node := range spec.GatewayNodes {
+		dst.Spec.GatewayNodes = append(dst.Spec.GatewayNodes, v1beta1.NodeAvailPorts(node))
+	}
+	if spec.ImageHub != nil {
+		dst.Spec.ImageHub = &v1beta1.ImageHub{
+			Domain:            spec.ImageHub.Domain,
+			Namespace:         spec.ImageHub.Namespace,
+			Username:          spec.ImageHub.Username,
+			PasswordSecretRef: spec.ImageHub.PasswordSecretRef,
+		}
+	}
+	dst.Spec.RegionDatabase = convertDatabaseTo(spec.RegionDatabase)
+	dst.Spec.UIDatabase = convertDatabaseTo(spec.UIDatabase)
+	if spec.EtcdConfig != nil {
+		dst.Spec.EtcdConfig = &v1beta1.EtcdConfig{
+			Endpoints:     spec.EtcdConfig.Endpoints,
+			TLSSecretName: spec.EtcdConfig.SecretName,
+		}
+	}
+	if fstab := spec.RainbondShareStorage.FstabLine; fstab != nil {
+		dst.Spec.RainbondShareStorage.FstabLine = (*v1beta1.FstabLine)(fstab)
+	}
+
+	if in.Status == nil {
+		dst.Status = nil
+		return nil
+	}
+	status := in.Status.DeepCopy()
+	dst.Status = &v1beta1.RainbondClusterStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		Phase:              v1beta1.RainbondClusterPhase(status.Phase),
+		MasterNodeNames:    status.MasterNodeNames,
+		MasterRoleLabel:    status.MasterRoleLabel,
+		CurrentVersion:     status.CurrentVersion,
+	}
+	if upgrade := status.Upgrade; upgrade != nil {
+		dst.Status.Upgrade = &v1beta1.UpgradeStatus{
+			FromVersion: upgrade.FromVersion,
+			ToVersion:   upgrade.ToVersion,
+			Phase:       v1beta1.UpgradePhase(upgrade.Phase),
+			StartTime:   upgrade.StartTime,
+			Message:     upgrade.Message,
+		}
+		for _, cpt := range upgrade.Components {
+			dst.Status.Upgrade.Components = append(dst.Status.Upgrade.Components, v1beta1.ComponentUpgradeStatus{
+				Name:          cpt.Name,
+				Phase:         v1beta1.ComponentUpgradePhase(cpt.Phase),
+				PreviousImage: cpt.PreviousImage,
+				Image:         cpt.Image,
+				StartTime:     cpt.StartTime,
+				Message:       cpt.Message,
+			})
+		}
+	}
+	for _, condition := range status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1beta1.RainbondClusterCondition{
+			Type:               v1beta1.RainbondClusterConditionType(condition.Type),
+			Status:             condition.Status,
+			ObservedGeneration: condition.ObservedGeneration,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	for _, ports := range status.NodeAvailPorts {
+		if ports != nil {
+			dst.Status.NodeAvailPorts = append(dst.Status.NodeAvailPorts, v1beta1.NodeAvailPorts(*ports))
+		}
+	}
+	for _, sc := range status.StorageClasses {
+		if sc != nil {
+			dst.Status.StorageClasses = append(dst.Status.StorageClasses, v1beta1.StorageClass(*sc))
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (in *RainbondCluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.RainbondCluster)
+	in.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	in.Spec = RainbondClusterSpec{
+		RainbondImageRepository: spec.RainbondImageRepository,
+		SuffixHTTPHost:          spec.SuffixHTTPHost,
+		GatewayIngressIPs:       spec.GatewayIngressIPs,
+		InstallMode:             InstallMode(spec.InstallMode),
+		StorageClassName:        spec.StorageClassName,
+		InstallVersion:          spec.InstallVersion,
+		ConfigCompleted:         spec.ConfigCompleted,
+		PurgeData:               spec.PurgeData,
+		NodeSelector:            spec.NodeSelector,
+		InstallPackageConfig: InstallPackageConfig{
+			URL:     spec.InstallPackageConfig.URL,
+			MD5:     spec.InstallPackageConfig.MD5,
+			Version: spec.InstallPackageConfig.Version,
+		},
+		RainbondShareStorage: RainbondShareStorage{
+			StorageClassName: spec.RainbondShareStorage.StorageClassName,
+		},
+	}
+	for _, node := range spec.GatewayNodes {
+		in.Spec.GatewayNodes = append(in.Spec.GatewayNodes, NodeAvailPorts(node))
+	}
+	if spec.ImageHub != nil {
+		in.Spec.ImageHub = &ImageHub{
+			Domain:            spec.ImageHub.Domain,
+			Namespace:         spec.ImageHub.Namespace,
+			Username:          spec.ImageHub.Username,
+			PasswordSecretRef: spec.ImageHub.PasswordSecretRef,
+		}
+	}
+	in.Spec.RegionDatabase = convertDatabaseFrom(spec.RegionDatabase)
+	in.Spec.UIDatabase = convertDatabaseFrom(spec.UIDatabase)
+	if spec.EtcdConfig != nil {
+		in.Spec.EtcdConfig = &EtcdConfig{
+			Endpoints:  spec.EtcdConfig.Endpoints,
+			SecretName: spec.EtcdConfig.TLSSecretName,
+		}
+	}
+	if fstab := spec.RainbondShareStorage.FstabLine; fstab != nil {
+		in.Spec.RainbondShareStorage.FstabLine = (*FstabLine)(fstab)
+	}
+
+	if src.Status == nil {
+		in.Status = nil
+		return nil
+	}
+	status := src.Status.DeepCopy()
+	in.Status = &RainbondClusterStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		Phase:              RainbondClusterPhase(status.Phase),
+		MasterNodeNames:    status.MasterNodeNames,
+		MasterRoleLabel:    status.MasterRoleLabel,
+		CurrentVersion:     status.CurrentVersion,
+	}
+	if upgrade := status.Upgrade; upgrade != nil {
+		in.Status.Upgrade = &UpgradeStatus{
+			FromVersion: upgrade.FromVersion,
+			ToVersion:   upgrade.ToVersion,
+			Phase:       UpgradePhase(upgrade.Phase),
+			StartTime:   upgrade.StartTime,
+			Message:     upgrade.Message,
+		}
+		for _, cpt := range upgrade.Components {
+			in.Status.Upgrade.Components = append(in.Status.Upgrade.Components, ComponentUpgradeStatus{
+				Name:          cpt.Name,
+				Phase:         ComponentUpgradePhase(cpt.Phase),
+				PreviousImage: cpt.PreviousImage,
+				Image:         cpt.Image,
+				StartTime:     cpt.StartTime,
+				Message:       cpt.Message,
+			})
+		}
+	}
+	for _, condition := range status.Conditions {
+		in.Status.Conditions = append(in.Status.Conditions, RainbondClusterCondition{
+			Type:               RainbondClusterConditionType(condition.Type),
+			Status:             condition.Status,
+			ObservedGeneration: condition.ObservedGeneration,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	for i := range status.NodeAvailPorts {
+		ports := NodeAvailPorts(status.NodeAvailPorts[i])
+		in.Status.NodeAvailPorts = append(in.Status.NodeAvailPorts, &ports)
+	}
+	for i := range status.StorageClasses {
+		sc := StorageClass(status.StorageClasses[i])
+		in.Status.StorageClasses = append(in.Status.StorageClasses, &sc)
+	}
+	return nil
+}
+
+func convertDatabaseTo(db *Database) *v1beta1.Database {
+	if db == nil {
+		return nil
+	}
+	return &v1beta1.Database{
+		Host:              db.Host,
+		Port:              db.Port,
+		Username:          db.Username,
+		PasswordSecretRef: db.PasswordSecretRef,
+	}
+}
+
+func convertDatabaseFrom(db *v1beta1.Database) *Database {
+	if db == nil {
+		return nil
+	}
+	return &Database{
+		Host:              db.Host,
+		Port:              db.Port,
+		Username:          db.Username,
+		PasswordSecretRef: db.PasswordSecretRef,
+	}
+}
+
+// ConvertTo converts this RbdComponent to the Hub version (v1beta1).
+func (in *RbdComponent) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.RbdComponent)
+	dst.ObjectMeta = *in.ObjectMeta.DeepCopy()
+
+	spec := in.Spec.DeepCopy()
+	dst.Spec = v1beta1.RbdComponentSpec{
+		ClusterName:       spec.ClusterName,
+		Replicas:          spec.Replicas,
+		Type:              spec.Type,
+		Version:           spec.Version,
+		LogLevel:          v1beta1.LogLevel(spec.LogLevel),
+		Image:             spec.Image,
+		ImagePullPolicy:   spec.ImagePullPolicy,
+		Configs:           spec.Configs,
+		PackagePath:       spec.PackagePath,
+		PriorityComponent: spec.PriorityComponent,
+		DependsOn:         spec.DependsOn,
+		Resources:         spec.Resources,
+		NodeSelector:      spec.NodeSelector,
+		Affinity:          spec.Affinity,
+		Tolerations:       spec.Tolerations,
+		PriorityClassName: spec.PriorityClassName,
+		Env:               spec.Env,
+		PodTemplatePatch:  spec.PodTemplatePatch,
+		ApplyMode:         v1beta1.ApplyMode(spec.ApplyMode),
+	}
+	if spec.Template != nil {
+		dst.Spec.Template = &v1beta1.TemplateSource{ConfigMapName: spec.Template.ConfigMapName}
+	}
+
+	if in.Status == nil {
+		dst.Status = nil
+		return nil
+	}
+	status := in.Status.DeepCopy()
+	controllerType := v1beta1.ControllerType(status.ControllerType)
+	if status.ControllerType == ControllerTypeStatefulSet {
+		controllerType = v1beta1.ControllerTypeStatefulSet
+	}
+	dst.Status = &v1beta1.RbdComponentStatus{
+		ControllerType:     controllerType,
+		ControllerName:     status.ControllerName,
+		ObservedGeneration: status.ObservedGeneration,
+		Replicas:           status.Replicas,
+		ReadyReplicas:      status.ReadyReplicas,
+		UpdatedReplicas:    status.UpdatedReplicas,
+		Image:              status.Image,
+		LastError:          status.LastError,
+		Reason:             status.Reason,
+		Message:            status.Message,
+	}
+	for _, condition := range status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1beta1.RbdComponentCondition{
+			Type:               v1beta1.RbdComponentConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	for _, pod := range status.Pods {
+		p := v1beta1.RbdComponentPodStatus{
+			Name:    pod.Name,
+			Phase:   pod.Phase,
+			HostIP:  pod.HostIP,
+			Reason:  pod.Reason,
+			Message: pod.Message,
+		}
+		for _, cs := range pod.ContainerStatuses {
+			p.ContainerStatuses = append(p.ContainerStatuses, v1beta1.RbdComponentPodContainerStatus(cs))
+		}
+		dst.Status.Pods = append(dst.Status.Pods, p)
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (in *RbdComponent) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.RbdComponent)
+	in.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	in.Spec = RbdComponentSpec{
+		ClusterName:       spec.ClusterName,
+		Replicas:          spec.Replicas,
+		Type:              spec.Type,
+		Version:           spec.Version,
+		LogLevel:          LogLevel(spec.LogLevel),
+		Image:             spec.Image,
+		ImagePullPolicy:   spec.ImagePullPolicy,
+		Configs:           spec.Configs,
+		PackagePath:       spec.PackagePath,
+		PriorityComponent: spec.PriorityComponent,
+		DependsOn:         spec.DependsOn,
+		Resources:         spec.Resources,
+		NodeSelector:      spec.NodeSelector,
+		Affinity:          spec.Affinity,
+		Tolerations:       spec.Tolerations,
+		PriorityClassName: spec.PriorityClassName,
+		Env:               spec.Env,
+		PodTemplatePatch:  spec.PodTemplatePatch,
+		ApplyMode:         ApplyMode(spec.ApplyMode),
+	}
+	if spec.Template != nil {
+		in.Spec.Template = &TemplateSource{ConfigMapName: spec.Template.ConfigMapName}
+	}
+
+	if src.Status == nil {
+		in.Status = nil
+		return nil
+	}
+	status := src.Status.DeepCopy()
+	controllerType := ControllerType(status.ControllerType)
+	if status.ControllerType == v1beta1.ControllerTypeStatefulSet {
+		controllerType = ControllerTypeStatefulSet
+	}
+	in.Status = &RbdComponentStatus{
+		ControllerType:     controllerType,
+		ControllerName:     status.ControllerName,
+		ObservedGeneration: status.ObservedGeneration,
+		Replicas:           status.Replicas,
+		ReadyReplicas:      status.ReadyReplicas,
+		UpdatedReplicas:    status.UpdatedReplicas,
+		Image:              status.Image,
+		LastError:          status.LastError,
+		Reason:             status.Reason,
+		Message:            status.Message,
+	}
+	for _, condition := range status.Conditions {
+		in.Status.Conditions = append(in.Status.Conditions, RbdComponentCondition{
+			Type:               RbdComponentConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	for _, pod := range status.Pods {
+		p := RbdComponentPodStatus{
+			Name:    pod.Name,
+			Phase:   pod.Phase,
+			HostIP:  pod.HostIP,
+			Reason:  pod.Reason,
+			Message: pod.Message,
+		}
+		for _, cs := range pod.ContainerStatuses {
+			p.ContainerStatuses = append(p.ContainerStatuses, RbdComponentPodContainerStatus(cs))
+		}
+		in.Status.Pods = append(in.Status.Pods, p)
+	}
+	return nil
+}
+
+// ConvertTo converts this RainbondPackage to the Hub version (v1beta1).
+func (in *RainbondPackage) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.RainbondPackage)
+	dst.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	dst.Spec = v1beta1.RainbondPackageSpec{PkgPath: in.Spec.PkgPath, ClusterName: in.Spec.ClusterName}
+
+	if in.Status == nil {
+		dst.Status = nil
+		return nil
+	}
+	dst.Status = &v1beta1.RainbondPackageStatus{ImagesNumber: in.Status.ImagesNumber, Version: in.Status.Version}
+	for _, condition := range in.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1beta1.PackageCondition{
+			Type:               v1beta1.PackageConditionType(condition.Type),
+			Status:             v1beta1.PackageConditionStatus(condition.Status),
+			LastHeartbeatTime:  condition.LastHeartbeatTime,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			Progress:           condition.Progress,
+		})
+	}
+	for _, image := range in.Status.ImagesPushed {
+		dst.Status.ImagesPushed = append(dst.Status.ImagesPushed, v1beta1.RainbondPackageImage(image))
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (in *RainbondPackage) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.RainbondPackage)
+	in.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	in.Spec = RainbondPackageSpec{PkgPath: src.Spec.PkgPath, ClusterName: src.Spec.ClusterName}
+
+	if src.Status == nil {
+		in.Status = nil
+		return nil
+	}
+	in.Status = &RainbondPackageStatus{ImagesNumber: src.Status.ImagesNumber, Version: src.Status.Version}
+	for _, condition := range src.Status.Conditions {
+		in.Status.Conditions = append(in.Status.Conditions, PackageCondition{
+			Type:               PackageConditionType(condition.Type),
+			Status:             PackageConditionStatus(condition.Status),
+			LastHeartbeatTime:  condition.LastHeartbeatTime,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			Progress:           condition.Progress,
+		})
+	}
+	for _, image := range src.Status.ImagesPushed {
+		in.Status.ImagesPushed = append(in.Status.ImagesPushed, RainbondPackageImage(image))
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestRainbondClusterRoundTrip(t *testing.T) {
+	now := metav1.Now()
+	cluster := &RainbondCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "rainbondcluster", Namespace: "rbd-system", Annotations: map[string]string{"foo": "bar"}},
+		Spec: RainbondClusterSpec{
+			SuffixHTTPHost:    "foo.grapps.cn",
+			GatewayIngressIPs: []string{"192.168.1.2"},
+			GatewayNodes:      []NodeAvailPorts{{NodeName: "node1", NodeIP: "192.168.1.2", Ports: []int{80, 443}}},
+			InstallMode:       InstallationModeWithPackage,
+			PurgeData:         true,
+			NodeSelector:      map[string]string{"rainbond.io/region": "staging"},
+			ImageHub:          &ImageHub{Domain: "goodrain.me", Username: "admin", PasswordSecretRef: PasswordSecretRef(ImageHubPasswordSecretName)},
+			RegionDatabase:    &Database{Host: "rbd-db", Port: 3306, Username: "root", PasswordSecretRef: PasswordSecretRef(RegionDBPasswordSecretName)},
+			EtcdConfig:        &EtcdConfig{Endpoints: []string{"http://rbd-etcd:2379"}, SecretName: "rbd-etcd-secret"},
+			InstallPackageConfig: InstallPackageConfig{
+				URL:     "https://rainbond-pkg.oss-cn-shanghai.aliyuncs.com/offline/5.3/rainbond.images.tgz",
+				MD5:     "b768a2459040acb751ab24c800944a17",
+				Version: "V5.3",
+			},
+			RainbondShareStorage: RainbondShareStorage{
+				FstabLine: &FstabLine{Device: "192.168.1.3:/data", MountPoint: "/grdata", Type: "nfs"},
+			},
+		},
+		Status: &RainbondClusterStatus{
+			Phase:           RainbondClusterRunning,
+			Conditions:      []RainbondClusterCondition{{Type: RainbondClusterConditionConfigValid, Status: corev1.ConditionTrue, LastTransitionTime: now}},
+			MasterNodeNames: []string{"node1"},
+			NodeAvailPorts:  []*NodeAvailPorts{{NodeName: "node1", Ports: []int{80}}},
+			StorageClasses:  []*StorageClass{{Name: "rainbondslsc", Provisioner: "rainbond.io/provisioner-sslc"}},
+			CurrentVersion:  "V5.2-dev",
+			Upgrade: &UpgradeStatus{
+				FromVersion: "V5.2-dev",
+				ToVersion:   "V5.3",
+				Phase:       UpgradeRollingForward,
+				StartTime:   now,
+				Components: []ComponentUpgradeStatus{
+					{Name: "rbd-api", Phase: ComponentUpgradeUpgrading, PreviousImage: "goodrain.me/rbd-api:V5.2-dev", Image: "goodrain.me/rbd-api:V5.3", StartTime: now},
+				},
+			},
+		},
+	}
+
+	hub := &v1beta1.RainbondCluster{}
+	if err := cluster.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.EtcdConfig.TLSSecretName != "rbd-etcd-secret" {
+		t.Errorf("Expected %s, but got %s", "rbd-etcd-secret", hub.Spec.EtcdConfig.TLSSecretName)
+	}
+	got := &RainbondCluster{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cluster, got) {
+		t.Errorf("Expected %+v, but got %+v", cluster, got)
+	}
+}
+
+func TestConvertInlinePasswords(t *testing.T) {
+	ref := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "rbd-db"}, Key: "password"}
+	cluster := &RainbondCluster{
+		Spec: RainbondClusterSpec{
+			ImageHub:       &ImageHub{Domain: "goodrain.me", Password: "secret"},
+			RegionDatabase: &Database{Host: "rbd-db", Password: "secret", PasswordSecretRef: ref},
+			UIDatabase:     &Database{Host: "rbd-db", Password: "secret"},
+		},
+	}
+
+	hub := &v1beta1.RainbondCluster{}
+	if err := cluster.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	want := v1beta1.RainbondClusterSpec{
+		ImageHub:       &v1beta1.ImageHub{Domain: "goodrain.me", PasswordSecretRef: PasswordSecretRef(ImageHubPasswordSecretName)},
+		RegionDatabase: &v1beta1.Database{Host: "rbd-db", PasswordSecretRef: ref},
+		UIDatabase:     &v1beta1.Database{Host: "rbd-db", PasswordSecretRef: PasswordSecretRef(UIDBPasswordSecretName)},
+	}
+	if !reflect.DeepEqual(want, hub.Spec) {
+		t.Errorf("Expected %+v, but got %+v", want, hub.Spec)
+	}
+	if cluster.Spec.ImageHub.Password != "secret" {
+		t.Errorf("Expected the source to be left as it is, but got %+v", cluster.Spec.ImageHub)
+	}
+
+	wantPasswords := map[string]string{ImageHubPasswordSecretName: "secret", UIDBPasswordSecretName: "secret"}
+	if got := cluster.Spec.MoveInlinePasswords(); !reflect.DeepEqual(wantPasswords, got) {
+		t.Errorf("Expected %v, but got %v", wantPasswords, got)
+	}
+}
+
+func TestRainbondClusterHubRoundTrip(t *testing.T) {
+	ref := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "rbd-db"}, Key: "password"}
+	hub := &v1beta1.RainbondCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "rainbondcluster"},
+		Spec: v1beta1.RainbondClusterSpec{
+			ImageHub:       &v1beta1.ImageHub{Domain: "goodrain.me", PasswordSecretRef: ref},
+			RegionDatabase: &v1beta1.Database{Host: "rbd-db", PasswordSecretRef: ref},
+			UIDatabase:     &v1beta1.Database{Host: "rbd-db", Port: 3306},
+		},
+	}
+
+	cluster := &RainbondCluster{}
+	if err := cluster.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	got := &v1beta1.RainbondCluster{}
+	if err := cluster.ConvertTo(got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hub, got) {
+		t.Errorf("Expected %+v, but got %+v", hub, got)
+	}
+}
+
+func TestRbdComponentRoundTrip(t *testing.T) {
+	cpt := &RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-db", Namespace: "rbd-system"},
+		Spec: RbdComponentSpec{
+			ClusterName:      "staging",
+			Replicas:         int32Ptr(2),
+			Image:            "goodrain.me/rbd-db:v5.2.0",
+			LogLevel:         LogLevelDebug,
+			Configs:          map[string]string{"foo": "bar"},
+			Env:              []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+			DependsOn:        []string{"rbd-db", "rbd-etcd"},
+			PodTemplatePatch: &runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"foo":"bar"}}}`)},
+			ApplyMode:        ApplyModeServerSideApply,
+			Template:         &TemplateSource{ConfigMapName: "rbd-log-shipper"},
+		},
+		Status: &RbdComponentStatus{
+			ControllerType: ControllerTypeStatefulSet,
+			ControllerName: "rbd-db",
+			Replicas:       2,
+			Conditions:     []RbdComponentCondition{{Type: RbdComponentAvailable, Status: corev1.ConditionTrue}},
+			Pods: []RbdComponentPodStatus{{
+				Name:              "rbd-db-0",
+				Phase:             "Ready",
+				ContainerStatuses: []RbdComponentPodContainerStatus{{Name: "rbd-db", Ready: true, State: "Running"}},
+			}},
+		},
+	}
+
+	hub := &v1beta1.RbdComponent{}
+	if err := cpt.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Status.ControllerType != v1beta1.ControllerTypeStatefulSet {
+		t.Errorf("Expected %s, but got %s", v1beta1.ControllerTypeStatefulSet, hub.Status.ControllerType)
+	}
+	got := &RbdComponent{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cpt, got) {
+		t.Errorf("Expected %+v, but got %+v", cpt, got)
+	}
+}
+
+func TestRainbondPackageRoundTrip(t *testing.T) {
+	pkg := &RainbondPackage{
+		ObjectMeta: metav1.ObjectMeta{Name: "rainbondpackage"},
+		Spec:       RainbondPackageSpec{PkgPath: "/opt/rainbond/pkg", ClusterName: "staging"},
+		Status: &RainbondPackageStatus{
+			Conditions:   []PackageCondition{{Type: PushImage, Status: Running, Progress: 50}},
+			ImagesNumber: 2,
+			ImagesPushed: []RainbondPackageImage{{Name: "goodrain.me/rbd-api"}},
+			Version:      "V5.3",
+		},
+	}
+
+	hub := &v1beta1.RainbondPackage{}
+	if err := pkg.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	got := &RainbondPackage{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pkg, got) {
+		t.Errorf("Expected %+v, but got %+v", pkg, got)
+	}
+}
@@ -0,0 +1,24 @@
+package v1alpha1
+
+// DefaultRainbondClusterName is the name of the RainbondCluster that RbdComponents and
+// RainbondPackages belong to if they don't specify one.
+const DefaultRainbondClusterName = "rainbondcluster"
+
+// Default fills in the default values of RainbondCluster.
+func (in *RainbondCluster) Default() {
+	if in.Spec.InstallMode == "" {
+		in.Spec.InstallMode = InstallationModeWithoutPackage
+	}
+}
+
+// Default fills in the default values of RbdComponent.
+func (in *RbdComponent) Default() {
+	in.Spec.ClusterName = in.RainbondClusterName()
+	if in.Spec.Replicas == nil {
+		replicas := in.Replicas()
+		in.Spec.Replicas = &replicas
+	}
+	in.Spec.ImagePullPolicy = in.ImagePullPolicy()
+	in.Spec.LogLevel = in.LogLevel()
+	in.Spec.ApplyMode = in.ApplyMode()
+}
@@ -0,0 +1,49 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The secrets in the namespace of the RainbondCluster the inline passwords are moved to.
+// v1beta1 only references passwords in secrets.
+const (
+	ImageHubPasswordSecretName = "rbd-imagehub-credentials"
+	RegionDBPasswordSecretName = "rbd-region-db-credentials"
+	UIDBPasswordSecretName     = "rbd-ui-db-credentials"
+	PasswordSecretKey          = "password"
+)
+
+// PasswordSecretRef returns the reference to the password in the secret name.
+func PasswordSecretRef(name string) *corev1.SecretKeySelector {
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Key:                  PasswordSecretKey,
+	}
+}
+
+// MoveInlinePasswords replaces the inline passwords with references to the secrets they are moved to.
+// It returns the moved passwords keyed by the names of the secrets, which have to be saved before the spec.
+// An inline password next to a reference is dropped, the reference takes precedence.
+func (in *RainbondClusterSpec) MoveInlinePasswords() map[string]string {
+	passwords := make(map[string]string)
+	move := func(name string, password *string, ref **corev1.SecretKeySelector) {
+		if *password == "" {
+			return
+		}
+		if *ref == nil {
+			passwords[name] = *password
+			*ref = PasswordSecretRef(name)
+		}
+		*password = ""
+	}
+	if hub := in.ImageHub; hub != nil {
+		move(ImageHubPasswordSecretName, &hub.Password, &hub.PasswordSecretRef)
+	}
+	if db := in.RegionDatabase; db != nil {
+		move(RegionDBPasswordSecretName, &db.Password, &db.PasswordSecretRef)
+	}
+	if db := in.UIDatabase; db != nil {
+		move(UIDBPasswordSecretName, &db.Password, &db.PasswordSecretRef)
+	}
+	return passwords
+}
@@ -1,8 +1,7 @@
 package v1alpha1
 
 import (
-	"fmt"
-
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -20,14 +19,32 @@ const (
 	LabelNodeRolePrefix = "node-role.kubernetes.io/"
 	// NodeLabelRole specifies the role of a node
 	NodeLabelRole = "kubernetes.io/role"
+
+	// Finalizer is added to RainbondCluster and RbdComponent, so that the operator can clean up
+	// the resources not garbage collected by kubernetes before they are deleted.
+	Finalizer = "rainbond.io/cleanup"
+
+	// PausedAnnotation stops the reconciliation of a RbdComponent, or of a RainbondCluster and all its
+	// RbdComponents, while it is "true".
+	PausedAnnotation = "rainbond.io/paused"
 )
 
+// IsPaused returns whether the reconciliation of obj is paused by PausedAnnotation.
+func IsPaused(obj metav1.Object) bool {
+	return obj.GetAnnotations()[PausedAnnotation] == "true"
+}
+
 // ImageHub image hub
 type ImageHub struct {
 	Domain    string `json:"domain,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
 	Username  string `json:"username,omitempty"`
-	Password  string `json:"password,omitempty"`
+	// Deprecated: use PasswordSecretRef instead, it is moved to a secret when the RainbondCluster is saved.
+	// +optional
+	Password string `json:"password,omitempty"`
+	// PasswordSecretRef selects the key of a secret that holds the password.
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
 }
 
 // Database defines the connection information of database.
@@ -35,7 +52,12 @@ type Database struct {
 	Host     string `json:"host,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Username string `json:"username,omitempty"`
+	// Deprecated: use PasswordSecretRef instead, it is moved to a secret when the RainbondCluster is saved.
+	// +optional
 	Password string `json:"password,omitempty"`
+	// PasswordSecretRef selects the key of a secret that holds the password.
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
 }
 
 // EtcdConfig defines the configuration of etcd client.
@@ -98,12 +120,25 @@ type RainbondClusterSpec struct {
 	ConfigCompleted bool `json:"configCompleted,omitempty"`
 	//InstallPackageConfig define install package download config
 	InstallPackageConfig InstallPackageConfig `json:"installPackageConfig,omitempty"`
+	// PurgeData wipes the hostpath data of the components, such as rbd-db and rbd-nfs,
+	// on their nodes when the components are deleted.
+	// +optional
+	PurgeData bool `json:"purgeData,omitempty"`
+	// NodeSelector restricts the components of the cluster to the nodes with these labels.
+	// The clusters in other namespaces must run on other nodes, since the components listen on
+	// host ports, so a cluster whose nodes overlap with those of an older cluster is rejected.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 //InstallPackageConfig define install package download config
 type InstallPackageConfig struct {
 	URL string `json:"url,omitempty"`
 	MD5 string `json:"md5,omitempty"`
+	// Version of Rainbond in the package. An upgrade in the WithPackage mode waits for it to be the install version,
+	// so that the package of the current version is not staged again.
+	// +optional
+	Version string `json:"version,omitempty"`
 }
 
 // NodeAvailPorts node avail port
@@ -119,8 +154,78 @@ type StorageClass struct {
 	Provisioner string `json:"provisioner"`
 }
 
+// RainbondClusterPhase is a label for the condition of a rainbondcluster at the current time.
+type RainbondClusterPhase string
+
+// These are the valid phases of a rainbondcluster.
+const (
+	// RainbondClusterSetting means the configuration of the cluster is not completed or invalid.
+	RainbondClusterSetting RainbondClusterPhase = "Setting"
+	// RainbondClusterInstalling means the package or the components are not ready yet.
+	RainbondClusterInstalling RainbondClusterPhase = "Installing"
+	// RainbondClusterRunning means all components are ready.
+	RainbondClusterRunning RainbondClusterPhase = "Running"
+	// RainbondClusterDegraded means the cluster has been running, but some components are not ready any more.
+	RainbondClusterDegraded RainbondClusterPhase = "Degraded"
+	// RainbondClusterUpgrading means the components are being upgraded to the install version.
+	RainbondClusterUpgrading RainbondClusterPhase = "Upgrading"
+)
+
+// RainbondClusterConditionType is a valid value for RainbondClusterCondition.Type
+type RainbondClusterConditionType string
+
+// These are valid conditions of rainbondcluster.
+const (
+	// RainbondClusterConditionConfigValid means the configuration of the cluster is completed and valid.
+	RainbondClusterConditionConfigValid RainbondClusterConditionType = "ConfigValid"
+	// RainbondClusterConditionImageHubReady means the image repository is available.
+	RainbondClusterConditionImageHubReady RainbondClusterConditionType = "ImageHubReady"
+	// RainbondClusterConditionPackageReady means the installation package is completed.
+	RainbondClusterConditionPackageReady RainbondClusterConditionType = "PackageReady"
+	// RainbondClusterConditionComponentsReady means all RbdComponents are ready.
+	RainbondClusterConditionComponentsReady RainbondClusterConditionType = "ComponentsReady"
+	// RainbondClusterConditionDegraded means some components are not ready after the cluster has been running.
+	RainbondClusterConditionDegraded RainbondClusterConditionType = "Degraded"
+	// RainbondClusterConditionPaused means the reconciliation of the cluster and its components is paused.
+	RainbondClusterConditionPaused RainbondClusterConditionType = "Paused"
+)
+
+// RainbondClusterReasonNodesConflict is the reason of the ConfigValid condition of a cluster whose nodes overlap
+// with those of an older cluster in another namespace. The components of the cluster are not reconciled.
+const RainbondClusterReasonNodesConflict = "NodesConflict"
+
+// RainbondClusterCondition contains condition information for rainbondcluster.
+// It has the same fields as the upstream metav1.Condition.
+type RainbondClusterCondition struct {
+	// Type of rainbondcluster condition.
+	Type RainbondClusterConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// The generation of the rainbondcluster that the condition was set based upon.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Last time the condition transit from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// (brief) reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // RainbondClusterStatus defines the observed state of RainbondCluster
 type RainbondClusterStatus struct {
+	// The generation observed by the rainbondcluster controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The phase of the rainbondcluster, computed from the conditions.
+	// +optional
+	Phase RainbondClusterPhase `json:"phase,omitempty"`
+	// Current service state of rainbondcluster.
+	// +optional
+	Conditions []RainbondClusterCondition `json:"conditions,omitempty"`
 	// Master node name list
 	MasterNodeNames []string          `json:"nodeNames,omitempty"`
 	NodeAvailPorts  []*NodeAvailPorts `json:"NodeAvailPorts,omitempty"`
@@ -129,6 +234,91 @@ type RainbondClusterStatus struct {
 	StorageClasses []*StorageClass `json:"storageClasses,omitempty"`
 	// Destination path of the installation package extraction.
 	MasterRoleLabel string `json:"masterRoleLabel,omitempty"`
+	// The install version that all components run, recorded when the installation or an upgrade completes.
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	// The state of the last upgrade.
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+}
+
+// UpgradePhase is the phase of the upgrade of a rainbondcluster.
+type UpgradePhase string
+
+// These are the valid phases of an upgrade.
+const (
+	// UpgradeStaging means the rainbondpackage is pushing the images of the new version.
+	UpgradeStaging UpgradePhase = "Staging"
+	// UpgradeRollingForward means the components are being upgraded one by one, in the order of their dependencies.
+	UpgradeRollingForward UpgradePhase = "RollingForward"
+	// UpgradeCompleted means all components run the new version.
+	UpgradeCompleted UpgradePhase = "Completed"
+	// UpgradeFailed means the images of the new version could not be staged in time. No component has been changed.
+	UpgradeFailed UpgradePhase = "Failed"
+	// UpgradeRollingBack means the upgraded components are being restored to their previous images one by one,
+	// in the reverse order of their dependencies.
+	UpgradeRollingBack UpgradePhase = "RollingBack"
+	// UpgradeRolledBack means the upgraded components have been restored to their previous images,
+	// because a component did not become ready in time or the install version changed again.
+	UpgradeRolledBack UpgradePhase = "RolledBack"
+)
+
+// ComponentUpgradePhase is the phase of the upgrade of a single component.
+type ComponentUpgradePhase string
+
+// These are the valid phases of the upgrade of a component.
+const (
+	// ComponentUpgradePending means the component has not been changed yet.
+	ComponentUpgradePending ComponentUpgradePhase = "Pending"
+	// ComponentUpgradeUpgrading means the component runs the new image, but is not ready yet.
+	ComponentUpgradeUpgrading ComponentUpgradePhase = "Upgrading"
+	// ComponentUpgradeUpgraded means the component is ready with the new image.
+	ComponentUpgradeUpgraded ComponentUpgradePhase = "Upgraded"
+	// ComponentUpgradeFailed means the component did not become ready with the new image in time.
+	ComponentUpgradeFailed ComponentUpgradePhase = "Failed"
+	// ComponentUpgradeRollingBack means the component runs its previous image again, but is not ready yet.
+	ComponentUpgradeRollingBack ComponentUpgradePhase = "RollingBack"
+	// ComponentUpgradeRolledBack means the component has been restored to its previous image.
+	ComponentUpgradeRolledBack ComponentUpgradePhase = "RolledBack"
+)
+
+// ComponentUpgradeStatus is the upgrade state of a component.
+type ComponentUpgradeStatus struct {
+	// Name of the rbdcomponent.
+	Name string `json:"name"`
+	// Phase of the upgrade of the component.
+	Phase ComponentUpgradePhase `json:"phase"`
+	// The image of the component before the upgrade, restored on rollback.
+	// +optional
+	PreviousImage string `json:"previousImage,omitempty"`
+	// The image of the component of the new version.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// The time the image of the component was changed.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Human readable message indicating details about the upgrade of the component.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeStatus is the state of the upgrade from one install version to another.
+type UpgradeStatus struct {
+	// The version the components ran before the upgrade.
+	FromVersion string `json:"fromVersion"`
+	// The version the components are upgraded to.
+	ToVersion string `json:"toVersion"`
+	// Phase of the upgrade.
+	Phase UpgradePhase `json:"phase"`
+	// The time the upgrade was started.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Human readable message indicating details about the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// The upgrade state of each component, in the order they are upgraded.
+	// +optional
+	Components []ComponentUpgradeStatus `json:"components,omitempty"`
 }
 
 // +genclient
@@ -137,6 +327,8 @@ type RainbondClusterStatus struct {
 // RainbondCluster is the Schema for the rainbondclusters API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=rainbondclusters,scope=Namespaced
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type RainbondCluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -194,10 +386,6 @@ func (in *RainbondCluster) GatewayIngressIPs() (ips []string) {
 	return nil
 }
 
-func (in *Database) RegionDataSource() string {
-	return fmt.Sprintf("--mysql=%s:%s@tcp(%s:%d)/region", in.Username, in.Password, in.Host, in.Port)
-}
-
 func (in *RainbondClusterStatus) MasterNodeLabel() map[string]string {
 	switch in.MasterRoleLabel {
 	case LabelNodeRolePrefix + "master":
@@ -221,3 +409,38 @@ func (in *RainbondClusterStatus) FirstMasterNodeLabel() map[string]string {
 		"kubernetes.io/hostname": in.MasterNodeNames[0],
 	}
 }
+
+// GetCondition returns the condition with the given type, nil if not found.
+func (in *RainbondClusterStatus) GetCondition(conditionType RainbondClusterConditionType) *RainbondClusterCondition {
+	for i := range in.Conditions {
+		if in.Conditions[i].Type == conditionType {
+			return &in.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition with the same type.
+// LastTransitionTime is kept if the status of the condition does not change.
+func (in *RainbondClusterStatus) SetCondition(condition RainbondClusterCondition) {
+	old := in.GetCondition(condition.Type)
+	if old == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		in.Conditions = append(in.Conditions, condition)
+		return
+	}
+	if old.Status == condition.Status {
+		condition.LastTransitionTime = old.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	*old = condition
+}
+
+// IsConditionTrue returns true if the condition with the given type is true.
+func (in *RainbondClusterStatus) IsConditionTrue(conditionType RainbondClusterConditionType) bool {
+	condition := in.GetCondition(conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
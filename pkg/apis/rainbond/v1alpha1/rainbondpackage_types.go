@@ -8,6 +8,10 @@ import (
 type RainbondPackageSpec struct {
 	// The path where the rainbond package is located.
 	PkgPath string `json:"pkgPath"`
+	// ClusterName is the name of the RainbondCluster, in the same namespace, that the package belongs to.
+	// Defaults to rainbondcluster.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
 }
 
 // RainbondPackagePhase is a label for the condition of a rainbondcluster at the current time.
@@ -77,6 +81,9 @@ type RainbondPackageStatus struct {
 	ImagesNumber int32 `json:"imagesNumber"`
 	// ImagesPushed contains the images have been pushed.
 	ImagesPushed []RainbondPackageImage `json:"images,omitempty"`
+	// The install version of the images that the package handles.
+	// +optional
+	Version string `json:"version,omitempty"`
 }
 
 // +genclient
@@ -105,3 +112,11 @@ type RainbondPackageList struct {
 func init() {
 	SchemeBuilder.Register(&RainbondPackage{}, &RainbondPackageList{})
 }
+
+// RainbondClusterName returns the name of the RainbondCluster that the package belongs to.
+func (in *RainbondPackage) RainbondClusterName() string {
+	if in.Spec.ClusterName == "" {
+		return DefaultRainbondClusterName
+	}
+	return in.Spec.ClusterName
+}
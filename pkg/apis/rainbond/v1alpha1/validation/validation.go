@@ -0,0 +1,226 @@
+package validation
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+var supportedInstallModes = []string{
+	string(rainbondv1alpha1.InstallationModeWithPackage),
+	string(rainbondv1alpha1.InstallationModeWithoutPackage),
+}
+
+var supportedLogLevels = []string{
+	string(rainbondv1alpha1.LogLevelDebug),
+	string(rainbondv1alpha1.LogLevelInfo),
+	string(rainbondv1alpha1.LogLevelWarning),
+	string(rainbondv1alpha1.LogLevelError),
+}
+
+var supportedApplyModes = []string{
+	string(rainbondv1alpha1.ApplyModeUpdate),
+	string(rainbondv1alpha1.ApplyModeServerSideApply),
+}
+
+var supportedPullPolicies = []string{
+	string(corev1.PullAlways),
+	string(corev1.PullNever),
+	string(corev1.PullIfNotPresent),
+}
+
+// ValidateRainbondCluster validates the spec of the given RainbondCluster.
+func ValidateRainbondCluster(cluster *rainbondv1alpha1.RainbondCluster) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := cluster.Spec
+
+	switch spec.InstallMode {
+	case "", rainbondv1alpha1.InstallationModeWithPackage, rainbondv1alpha1.InstallationModeWithoutPackage:
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("installMode"), spec.InstallMode, supportedInstallModes))
+	}
+
+	if spec.SuffixHTTPHost != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.SuffixHTTPHost) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("suffixHTTPHost"), spec.SuffixHTTPHost, msg))
+		}
+	}
+
+	for i, ip := range spec.GatewayIngressIPs {
+		if ip == "" {
+			// empty ip means not specified
+			continue
+		}
+		for _, msg := range validation.IsValidIP(ip) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("gatewayIngressIPs").Index(i), ip, msg))
+		}
+	}
+	for i, node := range spec.GatewayNodes {
+		for _, msg := range validation.IsValidIP(node.NodeIP) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("gatewayNodes").Index(i).Child("nodeIP"), node.NodeIP, msg))
+		}
+	}
+
+	allErrs = append(allErrs, validateDatabase(spec.RegionDatabase, specPath.Child("regionDatabase"))...)
+	allErrs = append(allErrs, validateDatabase(spec.UIDatabase, specPath.Child("uiDatabase"))...)
+	allErrs = append(allErrs, validateEtcdConfig(spec.EtcdConfig, specPath.Child("etcdConfig"))...)
+
+	if spec.ImageHub != nil {
+		if spec.ImageHub.Domain == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("imageHub", "domain"), ""))
+		}
+		allErrs = append(allErrs, validatePassword(spec.ImageHub.Password, spec.ImageHub.PasswordSecretRef, specPath.Child("imageHub"))...)
+	}
+
+	return allErrs
+}
+
+func validateDatabase(db *rainbondv1alpha1.Database, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if db == nil {
+		return allErrs
+	}
+	if db.Host == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	}
+	for _, msg := range validation.IsValidPortNum(db.Port) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), db.Port, msg))
+	}
+	allErrs = append(allErrs, validatePassword(db.Password, db.PasswordSecretRef, fldPath)...)
+	return allErrs
+}
+
+func validatePassword(password string, ref *corev1.SecretKeySelector, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if ref == nil {
+		return allErrs
+	}
+	if password != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("password"), "may not be specified when passwordSecretRef is specified"))
+	}
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("passwordSecretRef", "name"), ""))
+	}
+	if ref.Key == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("passwordSecretRef", "key"), ""))
+	}
+	return allErrs
+}
+
+func validateEtcdConfig(etcd *rainbondv1alpha1.EtcdConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if etcd == nil {
+		return allErrs
+	}
+	if len(etcd.Endpoints) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("endpoints"), ""))
+	}
+	for i, endpoint := range etcd.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("endpoints").Index(i), endpoint, err.Error()))
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("endpoints").Index(i), endpoint, "must start with http:// or https://"))
+			continue
+		}
+		if u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("endpoints").Index(i), endpoint, "host is required"))
+		}
+	}
+	return allErrs
+}
+
+// ValidateRbdComponent validates the spec of the given RbdComponent.
+func ValidateRbdComponent(cpt *rainbondv1alpha1.RbdComponent) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := cpt.Spec
+
+	allErrs = append(allErrs, validateClusterName(spec.ClusterName, specPath.Child("clusterName"))...)
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+	if spec.LogLevel != "" && !contains(supportedLogLevels, string(spec.LogLevel)) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("logLevel"), spec.LogLevel, supportedLogLevels))
+	}
+	if spec.ImagePullPolicy != "" && !contains(supportedPullPolicies, string(spec.ImagePullPolicy)) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("imagePullPolicy"), spec.ImagePullPolicy, supportedPullPolicies))
+	}
+	if spec.ApplyMode != "" && !contains(supportedApplyModes, string(spec.ApplyMode)) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("applyMode"), spec.ApplyMode, supportedApplyModes))
+	}
+	for key := range spec.Configs {
+		if strings.TrimSpace(key) == "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("configs"), key, "key must not be empty"))
+		}
+	}
+	allErrs = append(allErrs, validateDependsOn(cpt.Name, spec.DependsOn, specPath.Child("dependsOn"))...)
+	if spec.Type == rainbondv1alpha1.TemplateComponentType {
+		if spec.Template == nil || spec.Template.ConfigMapName == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("template", "configMapName"), "required for components of type template"))
+		}
+	} else if spec.Template != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("template"), "only allowed for components of type template"))
+	}
+
+	return allErrs
+}
+
+func validateDependsOn(name string, dependsOn []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := make(map[string]bool)
+	for i, dep := range dependsOn {
+		idxPath := fldPath.Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(dep) {
+			allErrs = append(allErrs, field.Invalid(idxPath, dep, msg))
+		}
+		if dep == name {
+			allErrs = append(allErrs, field.Invalid(idxPath, dep, "must not depend on itself"))
+		}
+		if seen[dep] {
+			allErrs = append(allErrs, field.Duplicate(idxPath, dep))
+		}
+		seen[dep] = true
+	}
+	return allErrs
+}
+
+// ValidateRainbondPackage validates the spec of the given RainbondPackage.
+func ValidateRainbondPackage(pkg *rainbondv1alpha1.RainbondPackage) field.ErrorList {
+	var allErrs field.ErrorList
+	if pkg.Spec.PkgPath != "" && !filepath.IsAbs(pkg.Spec.PkgPath) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "pkgPath"), pkg.Spec.PkgPath, "must be an absolute path"))
+	}
+	allErrs = append(allErrs, validateClusterName(pkg.Spec.ClusterName, field.NewPath("spec", "clusterName"))...)
+	return allErrs
+}
+
+func validateClusterName(name string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if name == "" {
+		// empty name means the default rainbondcluster
+		return allErrs
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+	return allErrs
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
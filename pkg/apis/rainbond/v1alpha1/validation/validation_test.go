@@ -0,0 +1,152 @@
+package validation
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestValidateRainbondCluster(t *testing.T) {
+	tests := []struct {
+		name string
+		spec rainbondv1alpha1.RainbondClusterSpec
+		want int
+	}{
+		{
+			name: "ok",
+			spec: rainbondv1alpha1.RainbondClusterSpec{
+				SuffixHTTPHost:    "foo.grapps.cn",
+				GatewayIngressIPs: []string{"192.168.1.2"},
+				InstallMode:       rainbondv1alpha1.InstallationModeWithoutPackage,
+				RegionDatabase:    &rainbondv1alpha1.Database{Host: "rbd-db", Port: 3306},
+				EtcdConfig:        &rainbondv1alpha1.EtcdConfig{Endpoints: []string{"http://rbd-etcd:2379"}},
+			},
+		},
+		{
+			name: "malformed suffix http host",
+			spec: rainbondv1alpha1.RainbondClusterSpec{SuffixHTTPHost: "foo_bar.cn"},
+			want: 1,
+		},
+		{
+			name: "invalid gateway ingress ip",
+			spec: rainbondv1alpha1.RainbondClusterSpec{GatewayIngressIPs: []string{"192.168.1.2", "foobar"}},
+			want: 1,
+		},
+		{
+			name: "database port out of range",
+			spec: rainbondv1alpha1.RainbondClusterSpec{UIDatabase: &rainbondv1alpha1.Database{Host: "rbd-db", Port: 70000}},
+			want: 1,
+		},
+		{
+			name: "both password and password secret ref",
+			spec: rainbondv1alpha1.RainbondClusterSpec{ImageHub: &rainbondv1alpha1.ImageHub{
+				Domain:            "goodrain.me",
+				Password:          "secret",
+				PasswordSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "rbd-hub"}, Key: "password"},
+			}},
+			want: 1,
+		},
+		{
+			name: "password secret ref without key",
+			spec: rainbondv1alpha1.RainbondClusterSpec{RegionDatabase: &rainbondv1alpha1.Database{
+				Host:              "rbd-db",
+				Port:              3306,
+				PasswordSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "rbd-db"}},
+			}},
+			want: 1,
+		},
+		{
+			name: "etcd endpoint without scheme",
+			spec: rainbondv1alpha1.RainbondClusterSpec{EtcdConfig: &rainbondv1alpha1.EtcdConfig{Endpoints: []string{"rbd-etcd:2379"}}},
+			want: 1,
+		},
+		{
+			name: "unknown install mode",
+			spec: rainbondv1alpha1.RainbondClusterSpec{InstallMode: "foobar"},
+			want: 1,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateRainbondCluster(&rainbondv1alpha1.RainbondCluster{Spec: tc.spec})
+			if len(errs) != tc.want {
+				t.Errorf("Expected %d errors, but got %v", tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestValidateRbdComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		spec rainbondv1alpha1.RbdComponentSpec
+		want int
+	}{
+		{
+			name: "ok",
+			spec: rainbondv1alpha1.RbdComponentSpec{ClusterName: "staging", LogLevel: rainbondv1alpha1.LogLevelDebug},
+		},
+		{
+			name: "malformed cluster name",
+			spec: rainbondv1alpha1.RbdComponentSpec{ClusterName: "Staging_Region"},
+			want: 1,
+		},
+		{
+			name: "unknown apply mode",
+			spec: rainbondv1alpha1.RbdComponentSpec{ApplyMode: "Patch"},
+			want: 1,
+		},
+		{
+			name: "unknown log level",
+			spec: rainbondv1alpha1.RbdComponentSpec{LogLevel: "foobar"},
+			want: 1,
+		},
+		{
+			name: "depends on",
+			spec: rainbondv1alpha1.RbdComponentSpec{DependsOn: []string{"rbd-db", "rbd-etcd"}},
+		},
+		{
+			name: "depends on itself",
+			spec: rainbondv1alpha1.RbdComponentSpec{DependsOn: []string{"rbd-api"}},
+			want: 1,
+		},
+		{
+			name: "duplicate dependency",
+			spec: rainbondv1alpha1.RbdComponentSpec{DependsOn: []string{"rbd-db", "rbd-db"}},
+			want: 1,
+		},
+		{
+			name: "template",
+			spec: rainbondv1alpha1.RbdComponentSpec{
+				Type:     rainbondv1alpha1.TemplateComponentType,
+				Template: &rainbondv1alpha1.TemplateSource{ConfigMapName: "rbd-log-shipper"},
+			},
+		},
+		{
+			name: "template without configmap",
+			spec: rainbondv1alpha1.RbdComponentSpec{Type: rainbondv1alpha1.TemplateComponentType},
+			want: 1,
+		},
+		{
+			name: "template of builtin component",
+			spec: rainbondv1alpha1.RbdComponentSpec{Template: &rainbondv1alpha1.TemplateSource{ConfigMapName: "rbd-api"}},
+			want: 1,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{Spec: tc.spec}
+			cpt.Name = "rbd-api"
+			errs := ValidateRbdComponent(cpt)
+			if len(errs) != tc.want {
+				t.Errorf("Expected %d errors, but got %v", tc.want, errs)
+			}
+		})
+	}
+}
@@ -23,12 +23,35 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentUpgradeStatus) DeepCopyInto(out *ComponentUpgradeStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentUpgradeStatus.
+func (in *ComponentUpgradeStatus) DeepCopy() *ComponentUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Database) DeepCopyInto(out *Database) {
 	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -82,6 +105,11 @@ func (in *FstabLine) DeepCopy() *FstabLine {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageHub) DeepCopyInto(out *ImageHub) {
 	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -182,6 +210,23 @@ func (in *RainbondCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RainbondClusterCondition) DeepCopyInto(out *RainbondClusterCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RainbondClusterCondition.
+func (in *RainbondClusterCondition) DeepCopy() *RainbondClusterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(RainbondClusterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RainbondClusterList) DeepCopyInto(out *RainbondClusterList) {
 	*out = *in
@@ -233,17 +278,17 @@ func (in *RainbondClusterSpec) DeepCopyInto(out *RainbondClusterSpec) {
 	if in.ImageHub != nil {
 		in, out := &in.ImageHub, &out.ImageHub
 		*out = new(ImageHub)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.RegionDatabase != nil {
 		in, out := &in.RegionDatabase, &out.RegionDatabase
 		*out = new(Database)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.UIDatabase != nil {
 		in, out := &in.UIDatabase, &out.UIDatabase
 		*out = new(Database)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.EtcdConfig != nil {
 		in, out := &in.EtcdConfig, &out.EtcdConfig
@@ -252,6 +297,13 @@ func (in *RainbondClusterSpec) DeepCopyInto(out *RainbondClusterSpec) {
 	}
 	in.RainbondShareStorage.DeepCopyInto(&out.RainbondShareStorage)
 	out.InstallPackageConfig = in.InstallPackageConfig
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -268,6 +320,13 @@ func (in *RainbondClusterSpec) DeepCopy() *RainbondClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RainbondClusterStatus) DeepCopyInto(out *RainbondClusterStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]RainbondClusterCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.MasterNodeNames != nil {
 		in, out := &in.MasterNodeNames, &out.MasterNodeNames
 		*out = make([]string, len(*in))
@@ -295,6 +354,11 @@ func (in *RainbondClusterStatus) DeepCopyInto(out *RainbondClusterStatus) {
 			}
 		}
 	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -463,7 +527,7 @@ func (in *RbdComponent) DeepCopyInto(out *RbdComponent) {
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(RbdComponentStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -486,6 +550,23 @@ func (in *RbdComponent) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RbdComponentCondition) DeepCopyInto(out *RbdComponentCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RbdComponentCondition.
+func (in *RbdComponentCondition) DeepCopy() *RbdComponentCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(RbdComponentCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RbdComponentList) DeepCopyInto(out *RbdComponentList) {
 	*out = *in
@@ -519,6 +600,43 @@ func (in *RbdComponentList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RbdComponentPodContainerStatus) DeepCopyInto(out *RbdComponentPodContainerStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RbdComponentPodContainerStatus.
+func (in *RbdComponentPodContainerStatus) DeepCopy() *RbdComponentPodContainerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RbdComponentPodContainerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RbdComponentPodStatus) DeepCopyInto(out *RbdComponentPodStatus) {
+	*out = *in
+	if in.ContainerStatuses != nil {
+		in, out := &in.ContainerStatuses, &out.ContainerStatuses
+		*out = make([]RbdComponentPodContainerStatus, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RbdComponentPodStatus.
+func (in *RbdComponentPodStatus) DeepCopy() *RbdComponentPodStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RbdComponentPodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RbdComponentSpec) DeepCopyInto(out *RbdComponentSpec) {
 	*out = *in
@@ -534,6 +652,52 @@ func (in *RbdComponentSpec) DeepCopyInto(out *RbdComponentSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodTemplatePatch != nil {
+		in, out := &in.PodTemplatePatch, &out.PodTemplatePatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(TemplateSource)
+		**out = **in
+	}
 	return
 }
 
@@ -550,6 +714,20 @@ func (in *RbdComponentSpec) DeepCopy() *RbdComponentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RbdComponentStatus) DeepCopyInto(out *RbdComponentStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]RbdComponentCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]RbdComponentPodStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -578,3 +756,43 @@ func (in *StorageClass) DeepCopy() *StorageClass {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateSource) DeepCopyInto(out *TemplateSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateSource.
+func (in *TemplateSource) DeepCopy() *TemplateSource {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]ComponentUpgradeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,12 @@
+package v1beta1
+
+// v1beta1 is the hub version, all other versions of rainbond.io convert to and from it.
+
+// Hub marks this type as a conversion hub.
+func (*RainbondCluster) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*RbdComponent) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*RainbondPackage) Hub() {}
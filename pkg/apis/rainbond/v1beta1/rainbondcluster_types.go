@@ -0,0 +1,327 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstallMode is the mode of Rainbond cluster installation
+type InstallMode string
+
+const (
+	// InstallationModeWithPackage means some Rainbond images are from the specified image repository, some are from the installation package.
+	InstallationModeWithPackage InstallMode = "WithPackage"
+	// InstallationModeWithoutPackage means all Rainbond images are from the specified image repository, not the installation package.
+	InstallationModeWithoutPackage InstallMode = "WithoutPackage"
+)
+
+// ImageHub image hub
+type ImageHub struct {
+	Domain    string `json:"domain,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Username  string `json:"username,omitempty"`
+	// PasswordSecretRef selects the key of a secret that holds the password.
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+// Database defines the connection information of database.
+type Database struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	// PasswordSecretRef selects the key of a secret that holds the password.
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+// EtcdConfig defines the configuration of etcd client.
+type EtcdConfig struct {
+	// Endpoints is a list of URLs.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// The name of secret that holds the certificates to connect to etcd with tls.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// FstabLine represents a line in file /etc/fstab.
+type FstabLine struct {
+	Device     string `json:"device,omitempty"`
+	MountPoint string `json:"mountPoint,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Options    string `json:"options,omitempty"`
+	Dump       int    `json:"dump,omitempty"`
+	Pass       int    `json:"pass,omitempty"`
+}
+
+// RainbondShareStorage defines the storage shared by rainbond components.
+type RainbondShareStorage struct {
+	StorageClassName string     `json:"storageClassName,omitempty"`
+	FstabLine        *FstabLine `json:"fstabLine,omitempty"`
+}
+
+// RainbondClusterSpec defines the desired state of RainbondCluster
+type RainbondClusterSpec struct {
+	// Repository of each Rainbond component image, eg. docker.io/rainbond.
+	// +optional
+	RainbondImageRepository string `json:"rainbondImageRepository,omitempty"`
+	// Suffix of component default domain name
+	SuffixHTTPHost string `json:"suffixHTTPHost,omitempty"`
+	// Ingress IP addresses of rbd-gateway. If not specified,
+	// the IP of the node where the rbd-gateway is located will be used.
+	GatewayIngressIPs []string `json:"gatewayIngressIPs,omitempty"`
+	// Information about the node where the gateway is located.
+	// If not specified, the gateway will run on nodes where all ports do not conflict.
+	GatewayNodes []NodeAvailPorts `json:"gatewayNodes,omitempty"`
+	// InstallMode is the mode of Rainbond cluster installation.
+	InstallMode InstallMode `json:"installMode,omitempty"`
+	// User-specified private image repository, replacing goodrain.me.
+	ImageHub *ImageHub `json:"imageHub,omitempty"`
+	// the storage class that rainbond component will be used.
+	// rainbond-operator will create one if StorageClassName is empty
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// the region database information that rainbond component will be used.
+	// rainbond-operator will create one if RegionDatabase is empty
+	RegionDatabase *Database `json:"regionDatabase,omitempty"`
+	// the ui database information that rainbond component will be used.
+	// rainbond-operator will create one if UIDatabase is empty
+	UIDatabase *Database `json:"uiDatabase,omitempty"`
+	// the etcd connection information that rainbond component will be used.
+	// rainbond-operator will create one if EtcdConfig is empty
+	EtcdConfig *EtcdConfig `json:"etcdConfig,omitempty"`
+	// define install rainbond version, This is usually image tag
+	InstallVersion       string               `json:"installVersion,omitempty"`
+	RainbondShareStorage RainbondShareStorage `json:"rainbondShareStorage,omitempty"`
+	// Whether the configuration has been completed
+	ConfigCompleted bool `json:"configCompleted,omitempty"`
+	// InstallPackageConfig define install package download config
+	InstallPackageConfig InstallPackageConfig `json:"installPackageConfig,omitempty"`
+	// PurgeData wipes the hostpath data of the components, such as rbd-db and rbd-nfs,
+	// on their nodes when the components are deleted.
+	// +optional
+	PurgeData bool `json:"purgeData,omitempty"`
+	// NodeSelector restricts the components of the cluster to the nodes with these labels.
+	// The clusters in other namespaces must run on other nodes, since the components listen on
+	// host ports, so a cluster whose nodes overlap with those of an older cluster is rejected.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// InstallPackageConfig define install package download config
+type InstallPackageConfig struct {
+	URL string `json:"url,omitempty"`
+	MD5 string `json:"md5,omitempty"`
+	// Version of Rainbond in the package. An upgrade in the WithPackage mode waits for it to be the install version,
+	// so that the package of the current version is not staged again.
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// NodeAvailPorts node avail port
+type NodeAvailPorts struct {
+	NodeName string `json:"nodeName,omitempty"`
+	NodeIP   string `json:"nodeIP,omitempty"`
+	Ports    []int  `json:"ports,omitempty"`
+}
+
+// StorageClass storage class
+type StorageClass struct {
+	Name        string `json:"name"`
+	Provisioner string `json:"provisioner"`
+}
+
+// RainbondClusterPhase is a label for the condition of a rainbondcluster at the current time.
+type RainbondClusterPhase string
+
+// These are the valid phases of a rainbondcluster.
+const (
+	// RainbondClusterSetting means the configuration of the cluster is not completed or invalid.
+	RainbondClusterSetting RainbondClusterPhase = "Setting"
+	// RainbondClusterInstalling means the package or the components are not ready yet.
+	RainbondClusterInstalling RainbondClusterPhase = "Installing"
+	// RainbondClusterRunning means all components are ready.
+	RainbondClusterRunning RainbondClusterPhase = "Running"
+	// RainbondClusterDegraded means the cluster has been running, but some components are not ready any more.
+	RainbondClusterDegraded RainbondClusterPhase = "Degraded"
+	// RainbondClusterUpgrading means the components are being upgraded to the install version.
+	RainbondClusterUpgrading RainbondClusterPhase = "Upgrading"
+)
+
+// RainbondClusterConditionType is a valid value for RainbondClusterCondition.Type
+type RainbondClusterConditionType string
+
+// These are valid conditions of rainbondcluster.
+const (
+	// RainbondClusterConditionConfigValid means the configuration of the cluster is completed and valid.
+	RainbondClusterConditionConfigValid RainbondClusterConditionType = "ConfigValid"
+	// RainbondClusterConditionImageHubReady means the image repository is available.
+	RainbondClusterConditionImageHubReady RainbondClusterConditionType = "ImageHubReady"
+	// RainbondClusterConditionPackageReady means the installation package is completed.
+	RainbondClusterConditionPackageReady RainbondClusterConditionType = "PackageReady"
+	// RainbondClusterConditionComponentsReady means all RbdComponents are ready.
+	RainbondClusterConditionComponentsReady RainbondClusterConditionType = "ComponentsReady"
+	// RainbondClusterConditionDegraded means some components are not ready after the cluster has been running.
+	RainbondClusterConditionDegraded RainbondClusterConditionType = "Degraded"
+	// RainbondClusterConditionPaused means the reconciliation of the cluster and its components is paused.
+	RainbondClusterConditionPaused RainbondClusterConditionType = "Paused"
+)
+
+// RainbondClusterCondition contains condition information for rainbondcluster.
+type RainbondClusterCondition struct {
+	// Type of rainbondcluster condition.
+	Type RainbondClusterConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// The generation of the rainbondcluster that the condition was set based upon.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Last time the condition transit from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// (brief) reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RainbondClusterStatus defines the observed state of RainbondCluster
+type RainbondClusterStatus struct {
+	// The generation observed by the rainbondcluster controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The phase of the rainbondcluster, computed from the conditions.
+	// +optional
+	Phase RainbondClusterPhase `json:"phase,omitempty"`
+	// Current service state of rainbondcluster.
+	// +optional
+	Conditions []RainbondClusterCondition `json:"conditions,omitempty"`
+	// Master node name list
+	// +optional
+	MasterNodeNames []string `json:"masterNodeNames,omitempty"`
+	// The ports of gateway available on each master node.
+	// +optional
+	NodeAvailPorts []NodeAvailPorts `json:"nodeAvailPorts,omitempty"`
+	// List of existing StorageClasses in the cluster
+	// +optional
+	StorageClasses []StorageClass `json:"storageClasses,omitempty"`
+	// The label of master nodes.
+	// +optional
+	MasterRoleLabel string `json:"masterRoleLabel,omitempty"`
+	// The install version that all components run, recorded when the installation or an upgrade completes.
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	// The state of the last upgrade.
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+}
+
+// UpgradePhase is the phase of the upgrade of a rainbondcluster.
+type UpgradePhase string
+
+// These are the valid phases of an upgrade.
+const (
+	// UpgradeStaging means the rainbondpackage is pushing the images of the new version.
+	UpgradeStaging UpgradePhase = "Staging"
+	// UpgradeRollingForward means the components are being upgraded one by one, in the order of their dependencies.
+	UpgradeRollingForward UpgradePhase = "RollingForward"
+	// UpgradeCompleted means all components run the new version.
+	UpgradeCompleted UpgradePhase = "Completed"
+	// UpgradeFailed means the images of the new version could not be staged in time. No component has been changed.
+	UpgradeFailed UpgradePhase = "Failed"
+	// UpgradeRollingBack means the upgraded components are being restored to their previous images one by one,
+	// in the reverse order of their dependencies.
+	UpgradeRollingBack UpgradePhase = "RollingBack"
+	// UpgradeRolledBack means the upgraded components have been restored to their previous images,
+	// because a component did not become ready in time or the install version changed again.
+	UpgradeRolledBack UpgradePhase = "RolledBack"
+)
+
+// ComponentUpgradePhase is the phase of the upgrade of a single component.
+type ComponentUpgradePhase string
+
+// These are the valid phases of the upgrade of a component.
+const (
+	// ComponentUpgradePending means the component has not been changed yet.
+	ComponentUpgradePending ComponentUpgradePhase = "Pending"
+	// ComponentUpgradeUpgrading means the component runs the new image, but is not ready yet.
+	ComponentUpgradeUpgrading ComponentUpgradePhase = "Upgrading"
+	// ComponentUpgradeUpgraded means the component is ready with the new image.
+	ComponentUpgradeUpgraded ComponentUpgradePhase = "Upgraded"
+	// ComponentUpgradeFailed means the component did not become ready with the new image in time.
+	ComponentUpgradeFailed ComponentUpgradePhase = "Failed"
+	// ComponentUpgradeRollingBack means the component runs its previous image again, but is not ready yet.
+	ComponentUpgradeRollingBack ComponentUpgradePhase = "RollingBack"
+	// ComponentUpgradeRolledBack means the component has been restored to its previous image.
+	ComponentUpgradeRolledBack ComponentUpgradePhase = "RolledBack"
+)
+
+// ComponentUpgradeStatus is the upgrade state of a component.
+type ComponentUpgradeStatus struct {
+	// Name of the rbdcomponent.
+	Name string `json:"name"`
+	// Phase of the upgrade of the component.
+	Phase ComponentUpgradePhase `json:"phase"`
+	// The image of the component before the upgrade, restored on rollback.
+	// +optional
+	PreviousImage string `json:"previousImage,omitempty"`
+	// The image of the component of the new version.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// The time the image of the component was changed.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Human readable message indicating details about the upgrade of the component.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeStatus is the state of the upgrade from one install version to another.
+type UpgradeStatus struct {
+	// The version the components ran before the upgrade.
+	FromVersion string `json:"fromVersion"`
+	// The version the components are upgraded to.
+	ToVersion string `json:"toVersion"`
+	// Phase of the upgrade.
+	Phase UpgradePhase `json:"phase"`
+	// The time the upgrade was started.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Human readable message indicating details about the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// The upgrade state of each component, in the order they are upgraded.
+	// +optional
+	Components []ComponentUpgradeStatus `json:"components,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RainbondCluster is the Schema for the rainbondclusters API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=rainbondclusters,scope=Namespaced
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type RainbondCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RainbondClusterSpec    `json:"spec,omitempty"`
+	Status *RainbondClusterStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RainbondClusterList contains a list of RainbondCluster
+type RainbondClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RainbondCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RainbondCluster{}, &RainbondClusterList{})
+}
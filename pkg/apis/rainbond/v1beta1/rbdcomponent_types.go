@@ -0,0 +1,255 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// LogLevel -
+type LogLevel string
+
+const (
+	// LogLevelDebug -
+	LogLevelDebug LogLevel = "debug"
+	// LogLevelInfo -
+	LogLevelInfo LogLevel = "info"
+	// LogLevelWarning -
+	LogLevelWarning LogLevel = "warning"
+	// LogLevelError -
+	LogLevelError LogLevel = "error"
+)
+
+// RbdComponentSpec defines the desired state of RbdComponent
+type RbdComponentSpec struct {
+	// ClusterName is the name of the RainbondCluster, in the same namespace, that the component belongs to.
+	// Defaults to rainbondcluster.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+	// Number of desired pods. This is a pointer to distinguish between explicit
+	// zero and not specified. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// type of rainbond component. The components of type template are rendered from the manifests in Template,
+	// the others are handled by the operator according to their names.
+	Type string `json:"type,omitempty"`
+	// version of rainbond component
+	Version  string   `json:"version,omitempty"`
+	LogLevel LogLevel `json:"logLevel,omitempty"`
+	// Docker image name.
+	Image string `json:"image,omitempty"`
+	// Image pull policy.
+	// One of Always, Never, IfNotPresent.
+	// Defaults to Always if :latest tag is specified, or IfNotPresent otherwise.
+	// Cannot be updated.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// component config map, rendered into command line flags or configuration files.
+	// The supported keys depend on the component, unknown keys are rejected.
+	Configs     map[string]string `json:"configs,omitempty"`
+	PackagePath string            `json:"packagePath,omitempty"`
+	//  Whether this component needs to be created first, without waiting for the rainbondpackage.
+	// The order between components is declared with DependsOn.
+	PriorityComponent bool `json:"priorityComponent,omitempty"`
+	// DependsOn is the names of the RbdComponents of the same cluster that have to be available
+	// before the resources of the component are created.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Compute resources of the main container of the component.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// NodeSelector replaces the node selector chosen by the operator, eg. the first master node.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Affinity replaces the scheduling constraints chosen by the operator.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// Tolerations are added to the tolerations of the component pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// If specified, indicates the pod's priority.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// List of extra environment variables to set in the main container.
+	// Variables with the same name as the ones set by the operator take precedence.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// PodTemplatePatch is a strategic merge patch applied to the pod template of the
+	// DaemonSet, StatefulSet or Deployment generated for the component.
+	// It can be used to add sidecars, volumes or probes that the operator does not model.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PodTemplatePatch *runtime.RawExtension `json:"podTemplatePatch,omitempty"`
+	// ApplyMode is how the resources of the component are written to the API server, one of Update, ServerSideApply.
+	// With ServerSideApply, fields owned by other field managers are left out of the apply, the conflicts with
+	// the fields taken by others in the meantime are reported.
+	// Defaults to Update.
+	// +optional
+	ApplyMode ApplyMode `json:"applyMode,omitempty"`
+	// Template is where the manifests of the component come from, required if Type is template.
+	// +optional
+	Template *TemplateSource `json:"template,omitempty"`
+}
+
+// TemplateComponentType is the type of the components rendered from the manifests in RbdComponentSpec.Template.
+const TemplateComponentType = "template"
+
+// TemplateSource is where the manifests of a template component come from.
+type TemplateSource struct {
+	// ConfigMapName is the name of the ConfigMap, in the namespace of the component, that holds the manifests.
+	// Each key holds Go templates of YAML documents, the keys are rendered in sorted order.
+	// Only namespaced ConfigMaps, Secrets, Services, PersistentVolumeClaims, workloads, Jobs, Ingresses
+	// and PodDisruptionBudgets are allowed, and the pods must use the default service account.
+	ConfigMapName string `json:"configMapName"`
+}
+
+// ApplyMode is how the resources of a component are written to the API server.
+type ApplyMode string
+
+const (
+	// ApplyModeUpdate replaces the resources when their desired state changes.
+	ApplyModeUpdate ApplyMode = "Update"
+	// ApplyModeServerSideApply applies the resources with server-side apply.
+	ApplyModeServerSideApply ApplyMode = "ServerSideApply"
+)
+
+// ControllerType -
+type ControllerType string
+
+const (
+	// ControllerTypeDeployment -
+	ControllerTypeDeployment ControllerType = "deployment"
+	// ControllerTypeDaemonSet -
+	ControllerTypeDaemonSet ControllerType = "daemonset"
+	// ControllerTypeStatefulSet -
+	ControllerTypeStatefulSet ControllerType = "statefulset"
+	// ControllerTypeUnknown -
+	ControllerTypeUnknown ControllerType = "unknown"
+)
+
+// RbdComponentConditionType is a valid value for RbdComponentCondition.Type
+type RbdComponentConditionType string
+
+// These are valid conditions of rbdcomponent.
+const (
+	// RbdComponentAvailable means at least one pod of the component is ready.
+	RbdComponentAvailable RbdComponentConditionType = "Available"
+	// RbdComponentProgressing means the pods of the component are being created or updated.
+	RbdComponentProgressing RbdComponentConditionType = "Progressing"
+	// RbdComponentDegraded means the operator failed to reconcile the component, or some pods keep failing.
+	RbdComponentDegraded RbdComponentConditionType = "Degraded"
+	// RbdComponentConflicted means some fields of the resources of the component are managed by
+	// other field managers, so they could not be applied with server-side apply.
+	RbdComponentConflicted RbdComponentConditionType = "Conflicted"
+	// RbdComponentHealthy means the application level health check of the component passed,
+	// it is only set for the components that have one.
+	RbdComponentHealthy RbdComponentConditionType = "Healthy"
+	// RbdComponentPaused means the reconciliation of the component is paused, by the component or its cluster.
+	RbdComponentPaused RbdComponentConditionType = "Paused"
+)
+
+// RbdComponentCondition contains condition information for rbdcomponent.
+type RbdComponentCondition struct {
+	// Type of rbdcomponent condition.
+	Type RbdComponentConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// Last time the condition transit from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// (brief) reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RbdComponentPodStatus represents information about the status of a pod, which belongs to RbdComponent.
+type RbdComponentPodStatus struct {
+	Name string `json:"name"`
+	// Ready or NotReady
+	Phase             string                           `json:"phase"`
+	HostIP            string                           `json:"hostIP,omitempty"`
+	Reason            string                           `json:"reason,omitempty"`
+	Message           string                           `json:"message,omitempty"`
+	ContainerStatuses []RbdComponentPodContainerStatus `json:"containerStatuses,omitempty"`
+}
+
+// RbdComponentPodContainerStatus represents information about the status of a container in the pod.
+type RbdComponentPodContainerStatus struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// Specifies whether the container has passed its readiness probe.
+	Ready bool `json:"ready"`
+	// Running, Waiting or Terminated
+	State   string `json:"state,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// RbdComponentStatus defines the observed state of RbdComponent
+type RbdComponentStatus struct {
+	// Type of Controller owned by RbdComponent
+	ControllerType ControllerType `json:"controllerType,omitempty"`
+	// ControllerName represents the Controller associated with RbdComponent
+	// The controller could be Deployment, StatefulSet or DaemonSet
+	ControllerName string `json:"controllerName,omitempty"`
+	// The generation of the RbdComponent that the status was generated for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Total number of pods desired by the controller.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// Total number of ready pods targeted by the controller.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// Total number of pods targeted by the controller that have the desired template spec.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+	// The image of the component that is actually running.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// The last error occurred while reconciling the component.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// Current service state of rbdcomponent.
+	// +optional
+	Conditions []RbdComponentCondition `json:"conditions,omitempty"`
+	// The status of pods owned by the controller.
+	// +optional
+	Pods []RbdComponentPodStatus `json:"pods,omitempty"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RbdComponent is the Schema for the rbdcomponents API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=rbdcomponents,scope=Namespaced
+// +kubebuilder:storageversion
+type RbdComponent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RbdComponentSpec    `json:"spec,omitempty"`
+	Status *RbdComponentStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RbdComponentList contains a list of RbdComponent
+type RbdComponentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RbdComponent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RbdComponent{}, &RbdComponentList{})
+}
@@ -0,0 +1,212 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1/validation"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	"github.com/goodrain/rainbond-operator/pkg/util/constants"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the rainbondcluster conditions.
+const (
+	reasonConfigNotCompleted = "ConfigNotCompleted"
+	reasonInvalidConfig      = "InvalidConfig"
+	reasonImageHubNotReady   = "ImageHubNotReady"
+	reasonPackageNotFound    = "PackageNotFound"
+	reasonPackageFailed      = "PackageFailed"
+	reasonPackageInstalling  = "PackageInstalling"
+	reasonNoComponents       = "NoComponents"
+	reasonComponentsNotReady = "ComponentsNotReady"
+	reasonReady              = "Ready"
+)
+
+func newCondition(conditionType rainbondv1alpha1.RainbondClusterConditionType, ok bool, reason, message string) rainbondv1alpha1.RainbondClusterCondition {
+	status := corev1.ConditionFalse
+	if ok {
+		status = corev1.ConditionTrue
+	}
+	return rainbondv1alpha1.RainbondClusterCondition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// setConditions refreshes the conditions, the observed generation and the phase of the given status.
+func (r *ReconcileRainbondCluster) setConditions(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, status *rainbondv1alpha1.RainbondClusterStatus, imageHubErr error) error {
+	conditions := []rainbondv1alpha1.RainbondClusterCondition{configCondition(cluster)}
+
+	hubCondition, err := r.imageHubCondition(ctx, cluster, imageHubErr)
+	if err != nil {
+		return err
+	}
+	conditions = append(conditions, hubCondition)
+
+	pkgCondition, err := r.packageCondition(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	conditions = append(conditions, pkgCondition)
+
+	cptCondition, err := r.componentsCondition(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	conditions = append(conditions, cptCondition)
+
+	wasRunning := status.Phase == rainbondv1alpha1.RainbondClusterRunning || status.Phase == rainbondv1alpha1.RainbondClusterDegraded
+	if wasRunning && cptCondition.Status != corev1.ConditionTrue && cptCondition.Reason != reasonNoComponents {
+		conditions = append(conditions, newCondition(rainbondv1alpha1.RainbondClusterConditionDegraded, true, cptCondition.Reason, cptCondition.Message))
+	} else {
+		conditions = append(conditions, newCondition(rainbondv1alpha1.RainbondClusterConditionDegraded, false, "", ""))
+	}
+
+	for _, condition := range conditions {
+		condition.ObservedGeneration = cluster.Generation
+		status.SetCondition(condition)
+	}
+	status.ObservedGeneration = cluster.Generation
+	status.Phase = phaseOf(status)
+
+	return nil
+}
+
+// configCondition checks if the configuration of the rainbondcluster is completed and valid.
+func configCondition(cluster *rainbondv1alpha1.RainbondCluster) rainbondv1alpha1.RainbondClusterCondition {
+	if !cluster.Spec.ConfigCompleted {
+		return newCondition(rainbondv1alpha1.RainbondClusterConditionConfigValid, false, reasonConfigNotCompleted, "the configuration is not completed")
+	}
+	if errs := validation.ValidateRainbondCluster(cluster); len(errs) > 0 {
+		return newCondition(rainbondv1alpha1.RainbondClusterConditionConfigValid, false, reasonInvalidConfig, errs.ToAggregate().Error())
+	}
+	return newCondition(rainbondv1alpha1.RainbondClusterConditionConfigValid, true, reasonReady, "")
+}
+
+// imageHubCondition checks if the image repository is ready. The readiness of the rbd-hub installed by the cluster
+// comes from its RbdComponent, an external image repository is probed at most once in imageHubProbeTTL.
+func (r *ReconcileRainbondCluster) imageHubCondition(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, imageHubErr error) (rainbondv1alpha1.RainbondClusterCondition, error) {
+	notReady := func(message string) rainbondv1alpha1.RainbondClusterCondition {
+		return newCondition(rainbondv1alpha1.RainbondClusterConditionImageHubReady, false, reasonImageHubNotReady, message)
+	}
+	hub := cluster.Spec.ImageHub
+	if hub == nil {
+		if imageHubErr != nil {
+			return notReady(imageHubErr.Error()), nil
+		}
+		return notReady("image repository is not configured"), nil
+	}
+
+	cpt := &rainbondv1alpha1.RbdComponent{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: chandler.HubName}, cpt)
+	if err != nil && !errors.IsNotFound(err) {
+		return rainbondv1alpha1.RainbondClusterCondition{}, fmt.Errorf("get rbdcomponent %s: %v", chandler.HubName, err)
+	}
+	switch {
+	case err == nil && cpt.RainbondClusterName() == cluster.Name:
+		if !componentReady(cpt) {
+			return notReady(fmt.Sprintf("%s is not ready", chandler.HubName)), nil
+		}
+	case hub.Domain == constants.DefImageRepositoryDomain:
+		return notReady(fmt.Sprintf("%s not found", chandler.HubName)), nil
+	default:
+		if err := cachedProbeImageHub(hub.Domain, hub.Domain); err != nil {
+			return notReady(err.Error()), nil
+		}
+	}
+	return newCondition(rainbondv1alpha1.RainbondClusterConditionImageHubReady, true, reasonReady, ""), nil
+}
+
+func (r *ReconcileRainbondCluster) packageCondition(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) (rainbondv1alpha1.RainbondClusterCondition, error) {
+	pkg, err := rbdutil.GetRainbondPackage(ctx, r.client, cluster)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return newCondition(rainbondv1alpha1.RainbondClusterConditionPackageReady, false, reasonPackageNotFound, "rainbondpackage not found"), nil
+		}
+		return rainbondv1alpha1.RainbondClusterCondition{}, fmt.Errorf("get rainbondpackage: %v", err)
+	}
+	if pkg.Status != nil {
+		for _, cond := range pkg.Status.Conditions {
+			if cond.Status == rainbondv1alpha1.Failed {
+				return newCondition(rainbondv1alpha1.RainbondClusterConditionPackageReady, false, reasonPackageFailed, cond.Message), nil
+			}
+			if cond.Type == rainbondv1alpha1.Ready && cond.Status == rainbondv1alpha1.Completed {
+				return newCondition(rainbondv1alpha1.RainbondClusterConditionPackageReady, true, reasonReady, ""), nil
+			}
+		}
+	}
+	return newCondition(rainbondv1alpha1.RainbondClusterConditionPackageReady, false, reasonPackageInstalling, "rainbondpackage is not completed"), nil
+}
+
+func (r *ReconcileRainbondCluster) componentsCondition(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) (rainbondv1alpha1.RainbondClusterCondition, error) {
+	cpts := &rainbondv1alpha1.RbdComponentList{}
+	if err := r.client.List(ctx, cpts, client.InNamespace(cluster.Namespace)); err != nil {
+		return rainbondv1alpha1.RainbondClusterCondition{}, fmt.Errorf("list rbdcomponents: %v", err)
+	}
+	var total int
+	var notReady []string
+	for i := range cpts.Items {
+		if cpts.Items[i].RainbondClusterName() != cluster.Name {
+			continue
+		}
+		total++
+		if !componentReady(&cpts.Items[i]) {
+			notReady = append(notReady, cpts.Items[i].Name)
+		}
+	}
+	if total == 0 {
+		return newCondition(rainbondv1alpha1.RainbondClusterConditionComponentsReady, false, reasonNoComponents, "no rbdcomponents found"), nil
+	}
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		message := fmt.Sprintf("%d of %d components are not ready: %s", len(notReady), total, strings.Join(notReady, ", "))
+		return newCondition(rainbondv1alpha1.RainbondClusterConditionComponentsReady, false, reasonComponentsNotReady, message), nil
+	}
+	return newCondition(rainbondv1alpha1.RainbondClusterConditionComponentsReady, true, reasonReady, ""), nil
+}
+
+// componentReady checks if all replicas of the workload of the RbdComponent are ready.
+func componentReady(cpt *rainbondv1alpha1.RbdComponent) bool {
+	if cpt.Status == nil || cpt.Status.Reason != "" {
+		return false
+	}
+	if cpt.Status.ControllerType == rainbondv1alpha1.ControllerTypeUnknown {
+		// no workload for this component
+		return true
+	}
+	return cpt.Status.Replicas > 0 && cpt.Status.ReadyReplicas >= cpt.Status.Replicas
+}
+
+// phaseOf computes the phase of the rainbondcluster from its conditions.
+func phaseOf(status *rainbondv1alpha1.RainbondClusterStatus) rainbondv1alpha1.RainbondClusterPhase {
+	if !status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionConfigValid) {
+		return rainbondv1alpha1.RainbondClusterSetting
+	}
+	if status.Upgrade != nil && upgradeInProgress(status.Upgrade) {
+		return rainbondv1alpha1.RainbondClusterUpgrading
+	}
+	if status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionDegraded) {
+		return rainbondv1alpha1.RainbondClusterDegraded
+	}
+	if status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionComponentsReady) {
+		return rainbondv1alpha1.RainbondClusterRunning
+	}
+	pkg := status.GetCondition(rainbondv1alpha1.RainbondClusterConditionPackageReady)
+	cpt := status.GetCondition(rainbondv1alpha1.RainbondClusterConditionComponentsReady)
+	if pkg != nil && pkg.Reason == reasonPackageNotFound && cpt != nil && cpt.Reason == reasonNoComponents {
+		// the installation has not started yet
+		return rainbondv1alpha1.RainbondClusterSetting
+	}
+	return rainbondv1alpha1.RainbondClusterInstalling
+}
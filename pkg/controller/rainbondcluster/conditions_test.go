@@ -0,0 +1,201 @@
+package rainbondcluster
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestPhaseOf(t *testing.T) {
+	cond := func(conditionType rainbondv1alpha1.RainbondClusterConditionType, ok bool, reason string) rainbondv1alpha1.RainbondClusterCondition {
+		return newCondition(conditionType, ok, reason, "")
+	}
+	tests := []struct {
+		name       string
+		conditions []rainbondv1alpha1.RainbondClusterCondition
+		want       rainbondv1alpha1.RainbondClusterPhase
+	}{
+		{
+			name: "config not completed",
+			conditions: []rainbondv1alpha1.RainbondClusterCondition{
+				cond(rainbondv1alpha1.RainbondClusterConditionConfigValid, false, reasonConfigNotCompleted),
+			},
+			want: rainbondv1alpha1.RainbondClusterSetting,
+		},
+		{
+			name: "installation not started",
+			conditions: []rainbondv1alpha1.RainbondClusterCondition{
+				cond(rainbondv1alpha1.RainbondClusterConditionConfigValid, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionPackageReady, false, reasonPackageNotFound),
+				cond(rainbondv1alpha1.RainbondClusterConditionComponentsReady, false, reasonNoComponents),
+			},
+			want: rainbondv1alpha1.RainbondClusterSetting,
+		},
+		{
+			name: "installing",
+			conditions: []rainbondv1alpha1.RainbondClusterCondition{
+				cond(rainbondv1alpha1.RainbondClusterConditionConfigValid, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionPackageReady, false, reasonPackageInstalling),
+				cond(rainbondv1alpha1.RainbondClusterConditionComponentsReady, false, reasonNoComponents),
+			},
+			want: rainbondv1alpha1.RainbondClusterInstalling,
+		},
+		{
+			name: "running",
+			conditions: []rainbondv1alpha1.RainbondClusterCondition{
+				cond(rainbondv1alpha1.RainbondClusterConditionConfigValid, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionPackageReady, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionComponentsReady, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionDegraded, false, ""),
+			},
+			want: rainbondv1alpha1.RainbondClusterRunning,
+		},
+		{
+			name: "degraded",
+			conditions: []rainbondv1alpha1.RainbondClusterCondition{
+				cond(rainbondv1alpha1.RainbondClusterConditionConfigValid, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionPackageReady, true, reasonReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionComponentsReady, false, reasonComponentsNotReady),
+				cond(rainbondv1alpha1.RainbondClusterConditionDegraded, true, reasonComponentsNotReady),
+			},
+			want: rainbondv1alpha1.RainbondClusterDegraded,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			status := &rainbondv1alpha1.RainbondClusterStatus{}
+			for _, c := range tc.conditions {
+				status.SetCondition(c)
+			}
+			if got := phaseOf(status); got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetCondition(t *testing.T) {
+	status := &rainbondv1alpha1.RainbondClusterStatus{}
+	status.SetCondition(newCondition(rainbondv1alpha1.RainbondClusterConditionImageHubReady, false, reasonImageHubNotReady, "foo"))
+	first := status.Conditions[0].LastTransitionTime
+	if first.IsZero() {
+		t.Errorf("Expected last transition time to be set")
+	}
+
+	status.SetCondition(newCondition(rainbondv1alpha1.RainbondClusterConditionImageHubReady, false, reasonImageHubNotReady, "bar"))
+	if len(status.Conditions) != 1 {
+		t.Fatalf("Expected 1 condition, but got %d", len(status.Conditions))
+	}
+	if status.Conditions[0].Message != "bar" {
+		t.Errorf("Expected message bar, but got %s", status.Conditions[0].Message)
+	}
+	if !status.Conditions[0].LastTransitionTime.Equal(&first) {
+		t.Errorf("Expected last transition time %v, but got %v", first, status.Conditions[0].LastTransitionTime)
+	}
+}
+
+func TestImageHubCondition(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+	external := strings.TrimPrefix(registry.URL, "https://")
+
+	hub := func(readyReplicas int32) *rainbondv1alpha1.RbdComponent {
+		return &rainbondv1alpha1.RbdComponent{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rbd-hub"},
+			Status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType: rainbondv1alpha1.ControllerTypeDaemonSet,
+				Replicas:       1,
+				ReadyReplicas:  readyReplicas,
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		imageHub    *rainbondv1alpha1.ImageHub
+		imageHubErr error
+		hub         *rainbondv1alpha1.RbdComponent
+		want        corev1.ConditionStatus
+		wantMessage string
+	}{
+		{name: "not configured", want: corev1.ConditionFalse, wantMessage: "image repository is not configured"},
+		{name: "probe failed", imageHubErr: errors.New("foo"), want: corev1.ConditionFalse, wantMessage: "foo"},
+		{name: "rbd-hub ready", imageHub: &rainbondv1alpha1.ImageHub{Domain: "goodrain.me"}, hub: hub(1), want: corev1.ConditionTrue},
+		{name: "rbd-hub not ready", imageHub: &rainbondv1alpha1.ImageHub{Domain: "goodrain.me"}, hub: hub(0), want: corev1.ConditionFalse, wantMessage: "rbd-hub is not ready"},
+		{name: "rbd-hub not found", imageHub: &rainbondv1alpha1.ImageHub{Domain: "goodrain.me"}, want: corev1.ConditionFalse, wantMessage: "rbd-hub not found"},
+		{name: "external", imageHub: &rainbondv1alpha1.ImageHub{Domain: external}, want: corev1.ConditionTrue},
+		{name: "external unavailable", imageHub: &rainbondv1alpha1.ImageHub{Domain: "127.0.0.1:1"}, want: corev1.ConditionFalse},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &rainbondv1alpha1.RainbondCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: rainbondv1alpha1.DefaultRainbondClusterName},
+				Spec:       rainbondv1alpha1.RainbondClusterSpec{ImageHub: tc.imageHub},
+			}
+			var objs []runtime.Object
+			if tc.hub != nil {
+				objs = append(objs, tc.hub)
+			}
+			r := &ReconcileRainbondCluster{client: fake.NewFakeClientWithScheme(scheme, objs...), scheme: scheme}
+
+			got, err := r.imageHubCondition(context.Background(), cluster, tc.imageHubErr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got.Status)
+			}
+			if tc.wantMessage != "" && got.Message != tc.wantMessage {
+				t.Errorf("Expected %v, but got %v", tc.wantMessage, got.Message)
+			}
+		})
+	}
+}
+
+func TestCachedProbeImageHub(t *testing.T) {
+	var requests int32
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer registry.Close()
+	addr := strings.TrimPrefix(registry.URL, "https://")
+	key := addr + "/" + addr
+
+	for i := 0; i < 2; i++ {
+		if err := cachedProbeImageHub(addr, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Expected 1, but got %d", got)
+	}
+
+	imageHubProbes.Lock()
+	imageHubProbes.m[key] = imageHubProbe{probeTime: time.Now().Add(-imageHubProbeTTL)}
+	imageHubProbes.Unlock()
+	if err := cachedProbeImageHub(addr, addr); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("Expected 2, but got %d", got)
+	}
+}
@@ -0,0 +1,99 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+// nodeSelectorOf returns the labels of the nodes of the cluster that also have the given labels.
+func nodeSelectorOf(cluster *rainbondv1alpha1.RainbondCluster, selector map[string]string) map[string]string {
+	merged := make(map[string]string, len(selector)+len(cluster.Spec.NodeSelector))
+	for k, v := range selector {
+		merged[k] = v
+	}
+	for k, v := range cluster.Spec.NodeSelector {
+		merged[k] = v
+	}
+	return merged
+}
+
+// nodesConflict checks if the nodes of the cluster overlap with those of an older cluster in another namespace.
+// The components of both clusters would listen on the same host ports of these nodes.
+// It returns a message describing the conflict, empty if there is none.
+func (r *ReconcileRainbondCluster) nodesConflict(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) (string, error) {
+	// the cache of the manager only holds the rainbondclusters in the watched namespace
+	clusters := &rainbondv1alpha1.RainbondClusterList{}
+	if err := r.reader.List(ctx, clusters); err != nil {
+		return "", fmt.Errorf("list rainbondclusters: %v", err)
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.client.List(ctx, nodes); err != nil {
+		return "", fmt.Errorf("list nodes: %v", err)
+	}
+
+	own := selectedNodes(nodes.Items, cluster.Spec.NodeSelector)
+	for i := range clusters.Items {
+		other := &clusters.Items[i]
+		if other.Namespace == cluster.Namespace || other.DeletionTimestamp != nil || !olderThan(other, cluster) {
+			continue
+		}
+		var shared []string
+		for name := range selectedNodes(nodes.Items, other.Spec.NodeSelector) {
+			if own[name] {
+				shared = append(shared, name)
+			}
+		}
+		if len(shared) == 0 {
+			continue
+		}
+		sort.Strings(shared)
+		return fmt.Sprintf("nodes %s are used by the rainbondcluster %s/%s, set spec.nodeSelector to select other nodes",
+			strings.Join(shared, ", "), other.Namespace, other.Name), nil
+	}
+	return "", nil
+}
+
+// selectedNodes returns the names of the nodes with the given labels, all nodes if there are no labels.
+func selectedNodes(nodes []corev1.Node, selector map[string]string) map[string]bool {
+	set := labels.SelectorFromSet(selector)
+	names := make(map[string]bool)
+	for _, node := range nodes {
+		if set.Matches(labels.Set(node.Labels)) {
+			names[node.Name] = true
+		}
+	}
+	return names
+}
+
+// olderThan returns whether the cluster a was created before b, by the namespace if at the same time.
+func olderThan(a, b *rainbondv1alpha1.RainbondCluster) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace < b.Namespace
+}
+
+// rejectNodes marks the configuration of the cluster invalid because of the conflict of nodes.
+func (r *ReconcileRainbondCluster) rejectNodes(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, message string) error {
+	status := cluster.Status.DeepCopy()
+	if status == nil {
+		status = &rainbondv1alpha1.RainbondClusterStatus{}
+	}
+	condition := newCondition(rainbondv1alpha1.RainbondClusterConditionConfigValid, false, rainbondv1alpha1.RainbondClusterReasonNodesConflict, message)
+	condition.ObservedGeneration = cluster.Generation
+	status.SetCondition(condition)
+	status.Phase = phaseOf(status)
+	if equality.Semantic.DeepEqual(cluster.Status, status) {
+		return nil
+	}
+	cluster.Status = status
+	return r.client.Status().Update(ctx, cluster)
+}
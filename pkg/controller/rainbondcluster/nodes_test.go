@@ -0,0 +1,67 @@
+package rainbondcluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNodesConflict(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	node := func(name, region string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"region": region}}}
+	}
+	now := time.Now()
+	cluster := func(namespace string, age time.Duration, selector map[string]string) *rainbondv1alpha1.RainbondCluster {
+		return &rainbondv1alpha1.RainbondCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         namespace,
+				Name:              rainbondv1alpha1.DefaultRainbondClusterName,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec: rainbondv1alpha1.RainbondClusterSpec{NodeSelector: selector},
+		}
+	}
+	production := map[string]string{"region": "production"}
+	staging := map[string]string{"region": "staging"}
+
+	tests := []struct {
+		name     string
+		cluster  *rainbondv1alpha1.RainbondCluster
+		others   []runtime.Object
+		conflict bool
+	}{
+		{name: "only one", cluster: cluster("rbd-system", time.Hour, nil)},
+		{name: "all nodes of both", cluster: cluster("rbd-staging", time.Minute, nil), others: []runtime.Object{cluster("rbd-system", time.Hour, nil)}, conflict: true},
+		{name: "newer one", cluster: cluster("rbd-system", time.Hour, nil), others: []runtime.Object{cluster("rbd-staging", time.Minute, nil)}},
+		{name: "separate nodes", cluster: cluster("rbd-staging", time.Minute, staging), others: []runtime.Object{cluster("rbd-system", time.Hour, production)}},
+		{name: "overlapping nodes", cluster: cluster("rbd-staging", time.Minute, staging), others: []runtime.Object{cluster("rbd-system", time.Hour, nil)}, conflict: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := append([]runtime.Object{tc.cluster, node("node1", "production"), node("node2", "staging")}, tc.others...)
+			cli := fake.NewFakeClientWithScheme(scheme, objs...)
+			r := &ReconcileRainbondCluster{client: cli, reader: cli, scheme: scheme}
+
+			message, err := r.nodesConflict(context.Background(), tc.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := message != ""; got != tc.conflict {
+				t.Errorf("Expected conflict %v, but got %q", tc.conflict, message)
+			}
+		})
+	}
+}
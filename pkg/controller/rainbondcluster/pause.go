@@ -0,0 +1,60 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+// Reasons of the Paused condition.
+const (
+	reasonPausedByAnnotation = "PausedByAnnotation"
+	reasonResumed            = "Resumed"
+)
+
+// pause sets the Paused condition of the rainbondcluster, the rest of the status is left as it is.
+// The rbdcomponents of the rainbondcluster pause themselves.
+func (r *ReconcileRainbondCluster) pause(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) error {
+	status := cluster.Status.DeepCopy()
+	if status == nil {
+		status = &rainbondv1alpha1.RainbondClusterStatus{}
+	}
+	if status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionPaused) {
+		return nil
+	}
+	message := fmt.Sprintf("paused by the %s annotation", rainbondv1alpha1.PausedAnnotation)
+	condition := newCondition(rainbondv1alpha1.RainbondClusterConditionPaused, true, reasonPausedByAnnotation, message)
+	condition.ObservedGeneration = cluster.Generation
+	status.SetCondition(condition)
+	cluster.Status = status
+	if err := r.client.Status().Update(ctx, cluster); err != nil {
+		return err
+	}
+	r.recorder.Eventf(cluster, corev1.EventTypeNormal, "Paused", "Reconciliation of the rainbondcluster and its rbdcomponents %s", message)
+	return nil
+}
+
+// resumeSummary describes what the rainbondcluster controller does once the reconciliation resumes.
+// The changes reverted on the resources of the rbdcomponents are reported by the rbdcomponents.
+func resumeSummary(cluster *rainbondv1alpha1.RainbondCluster, status *rainbondv1alpha1.RainbondClusterStatus) string {
+	var actions []string
+	version := rbdutil.GetInstallVersion(cluster)
+	switch {
+	case status.Upgrade != nil && status.Upgrade.ToVersion != version && upgradeInProgress(status.Upgrade):
+		actions = append(actions, fmt.Sprintf("roll back the upgrade to %s", status.Upgrade.ToVersion))
+	case status.Upgrade != nil && status.Upgrade.ToVersion == version && upgradeInProgress(status.Upgrade):
+		actions = append(actions, fmt.Sprintf("continue the upgrade from %s to %s", status.Upgrade.FromVersion, status.Upgrade.ToVersion))
+	case status.CurrentVersion != "" && status.CurrentVersion != version:
+		actions = append(actions, fmt.Sprintf("upgrade from %s to %s", status.CurrentVersion, version))
+	}
+	if cluster.Spec.ImageHub == nil {
+		actions = append(actions, "set up the image hub")
+	}
+	actions = append(actions, "revert the resources of the rbdcomponents, see their events")
+	return strings.Join(actions, ", ")
+}
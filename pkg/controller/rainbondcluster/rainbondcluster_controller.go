@@ -7,12 +7,10 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
-	"github.com/goodrain/rainbond-operator/pkg/util/constants"
 	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
-	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
@@ -21,9 +19,11 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -44,7 +44,12 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileRainbondCluster{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileRainbondCluster{
+		client:   mgr.GetClient(),
+		reader:   mgr.GetAPIReader(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor("rainbondcluster-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -56,17 +61,29 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	}
 
 	// Watch for changes to primary resource RainbondCluster
-	// Only watch rainbondcluster, because only support one rainbond cluster.
-	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondCluster{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "rainbondcluster",
-			Namespace: "rbd-system",
-		},
-	}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondCluster{}}, &handler.EnqueueRequestForObject{})
 	if err != nil {
 		return err
 	}
 
+	// The conditions of rainbondcluster depend on the rainbondpackage and rbdcomponents.
+	toRainbondCluster := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			owner, ok := obj.Object.(interface{ RainbondClusterName() string })
+			if !ok {
+				return nil
+			}
+			return []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: owner.RainbondClusterName()}},
+			}
+		}),
+	}
+	for _, t := range []runtime.Object{&rainbondv1alpha1.RainbondPackage{}, &rainbondv1alpha1.RbdComponent{}} {
+		if err := c.Watch(&source.Kind{Type: t}, toRainbondCluster); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -78,7 +95,10 @@ type ReconcileRainbondCluster struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// reader reads from the apiserver, for the objects out of the watched namespace.
+	reader   client.Reader
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a RainbondCluster object and makes changes based on the state read
@@ -107,51 +127,156 @@ func (r *ReconcileRainbondCluster) Reconcile(request reconcile.Request) (reconci
 		return reconcile.Result{}, err
 	}
 
-	if rainbondcluster.Status != nil && len(rainbondcluster.Status.NodeAvailPorts) > 0 && rainbondcluster.Spec.ImageHub != nil {
+	if rainbondcluster.DeletionTimestamp != nil {
+		if !k8sutil.HasFinalizer(rainbondcluster, rainbondv1alpha1.Finalizer) {
+			return reconcile.Result{}, nil
+		}
+		done, err := r.teardown(ctx, rainbondcluster)
+		if err != nil {
+			reqLogger.Error(err, "failed to tear down rainbondcluster")
+			r.recorder.Eventf(rainbondcluster, corev1.EventTypeWarning, "TeardownFailed", "Failed to tear down: %v", err)
+			return reconcile.Result{RequeueAfter: time.Second * 2}, err
+		}
+		if !done {
+			reqLogger.Info("waiting for the teardown of rainbondcluster")
+			return reconcile.Result{RequeueAfter: time.Second * 3}, nil
+		}
+		controllerutil.RemoveFinalizer(rainbondcluster, rainbondv1alpha1.Finalizer)
+		if err := r.client.Update(ctx, rainbondcluster); err != nil {
+			return reconcile.Result{RequeueAfter: time.Second * 2}, err
+		}
+		return reconcile.Result{}, nil
+	}
+	if !k8sutil.HasFinalizer(rainbondcluster, rainbondv1alpha1.Finalizer) {
+		controllerutil.AddFinalizer(rainbondcluster, rainbondv1alpha1.Finalizer)
+		if err := r.client.Update(ctx, rainbondcluster); err != nil {
+			reqLogger.Error(err, "add finalizer to rainbondcluster")
+			return reconcile.Result{RequeueAfter: time.Second * 2}, err
+		}
+	}
+	if rainbondv1alpha1.IsPaused(rainbondcluster) {
+		reqLogger.Info("Reconciliation paused")
+		if err := r.pause(ctx, rainbondcluster); err != nil {
+			reqLogger.Error(err, "failed to update rainbondcluster status")
+			return reconcile.Result{RequeueAfter: time.Second * 2}, err
+		}
+		// the change of the annotation triggers the next reconciliation
 		return reconcile.Result{}, nil
 	}
 
-	// TODO: do not create claims here
-	claims := r.claims(rainbondcluster)
-	for i := range claims {
-		claim := claims[i]
-		// Set RbdComponent cpt as the owner and controller
-		if err := controllerutil.SetControllerReference(rainbondcluster, claim, r.scheme); err != nil {
-			reqLogger.Error(err, "set controller reference")
+	// the defaulting webhook moves the inline passwords when it is enabled
+	if passwords := rainbondcluster.Spec.MoveInlinePasswords(); len(passwords) > 0 {
+		if err := rbdutil.SavePasswordSecrets(ctx, r.client, rainbondcluster.Namespace, passwords); err != nil {
+			reqLogger.Error(err, "failed to save the inline passwords of rainbondcluster")
 			return reconcile.Result{RequeueAfter: time.Second * 2}, err
 		}
-		if err = k8sutil.UpdateOrCreateResource(ctx, r.client, reqLogger, claim, claim); err != nil {
-			reqLogger.Error(err, "update or create pvc")
+		if err := r.client.Update(ctx, rainbondcluster); err != nil {
+			reqLogger.Error(err, "move the inline passwords of rainbondcluster")
 			return reconcile.Result{RequeueAfter: time.Second * 2}, err
 		}
+		r.recorder.Event(rainbondcluster, corev1.EventTypeNormal, "PasswordsMoved", "Moved the inline passwords to secrets")
+		return reconcile.Result{}, nil
 	}
 
-	status, err := r.generateRainbondClusterStatus(ctx, rainbondcluster)
+	conflict, err := r.nodesConflict(ctx, rainbondcluster)
 	if err != nil {
-		reqLogger.Error(err, "failed to generate rainbondcluster status")
+		reqLogger.Error(err, "failed to check the nodes of rainbondcluster")
 		return reconcile.Result{RequeueAfter: time.Second * 2}, err
 	}
-	rainbondcluster.Status = status
-	if err := r.client.Status().Update(ctx, rainbondcluster); err != nil {
-		reqLogger.Error(err, "failed to update rainbondcluster status")
-		return reconcile.Result{RequeueAfter: time.Second * 2}, err
+	if conflict != "" {
+		reqLogger.Info("Nodes conflict with another rainbondcluster", "Message", conflict)
+		r.recorder.Event(rainbondcluster, corev1.EventTypeWarning, rainbondv1alpha1.RainbondClusterReasonNodesConflict, conflict)
+		if err := r.rejectNodes(ctx, rainbondcluster, conflict); err != nil {
+			reqLogger.Error(err, "failed to update rainbondcluster status")
+			return reconcile.Result{RequeueAfter: time.Second * 2}, err
+		}
+		// the other rainbondcluster is not watched, check again later
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
 	}
 
-	if rainbondcluster.Spec.ImageHub == nil {
-		reqLogger.Info("image hub is empty, do sth.")
-		imageHub, err := r.getImageHub(rainbondcluster)
+	oldStatus := rainbondcluster.Status.DeepCopy()
+	status := rainbondcluster.Status.DeepCopy()
+	var imageHubErr error
+	if status == nil || len(status.NodeAvailPorts) == 0 || rainbondcluster.Spec.ImageHub == nil {
+		// TODO: do not create claims here
+		claims := rbdutil.ClusterClaims(rainbondcluster)
+		for i := range claims {
+			claim := claims[i]
+			// Set RbdComponent cpt as the owner and controller
+			if err := controllerutil.SetControllerReference(rainbondcluster, claim, r.scheme); err != nil {
+				reqLogger.Error(err, "set controller reference")
+				return reconcile.Result{RequeueAfter: time.Second * 2}, err
+			}
+			if err = k8sutil.UpdateOrCreateResource(ctx, r.client, reqLogger, claim, claim); err != nil {
+				reqLogger.Error(err, "update or create pvc")
+				r.recorder.Eventf(rainbondcluster, corev1.EventTypeWarning, "UpdateOrCreateFailed", "Failed to create or update claim %s: %v", claim.Name, err)
+				return reconcile.Result{RequeueAfter: time.Second * 2}, err
+			}
+		}
+
+		generated, err := r.generateRainbondClusterStatus(ctx, rainbondcluster)
 		if err != nil {
-			reqLogger.Error(err, "set image hub info")
+			reqLogger.Error(err, "failed to generate rainbondcluster status")
 			return reconcile.Result{RequeueAfter: time.Second * 2}, err
 		}
-		rainbondcluster.Spec.ImageHub = imageHub
-		if err = r.client.Update(ctx, rainbondcluster); err != nil {
-			reqLogger.Error(err, "update rainbondcluster")
+		if status != nil {
+			generated.Conditions = status.Conditions
+			generated.Phase = status.Phase
+		}
+		status = generated
+		rainbondcluster.Status = status
+
+		if rainbondcluster.Spec.ImageHub == nil {
+			reqLogger.Info("image hub is empty, do sth.")
+			imageHub, err := r.getImageHub(rainbondcluster)
+			if err != nil {
+				reqLogger.Error(err, "set image hub info")
+				r.recorder.Eventf(rainbondcluster, corev1.EventTypeWarning, "ImageHubUnavailable", "Image hub is not ready: %v", err)
+				imageHubErr = err
+			} else {
+				rainbondcluster.Spec.ImageHub = imageHub
+				r.recorder.Eventf(rainbondcluster, corev1.EventTypeNormal, "ImageHubReady", "Image hub %s is ready", imageHub.Domain)
+				if err = r.client.Update(ctx, rainbondcluster); err != nil {
+					reqLogger.Error(err, "update rainbondcluster")
+					return reconcile.Result{RequeueAfter: time.Second * 2}, err
+				}
+			}
+		}
+	}
+
+	if status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionPaused) {
+		summary := resumeSummary(rainbondcluster, status)
+		reqLogger.Info("Reconciliation resumed", "Summary", summary)
+		r.recorder.Eventf(rainbondcluster, corev1.EventTypeNormal, "Resumed", "Reconciliation resumed, will %s", summary)
+		condition := newCondition(rainbondv1alpha1.RainbondClusterConditionPaused, false, reasonResumed, "")
+		condition.ObservedGeneration = rainbondcluster.Generation
+		status.SetCondition(condition)
+	}
+
+	upgradeWait, err := r.upgrade(ctx, rainbondcluster, status)
+	if err != nil {
+		reqLogger.Error(err, "failed to upgrade rainbondcluster")
+		r.recorder.Eventf(rainbondcluster, corev1.EventTypeWarning, "UpgradeError", "Failed to upgrade: %v", err)
+		return reconcile.Result{RequeueAfter: time.Second * 2}, err
+	}
+
+	if err := r.setConditions(ctx, rainbondcluster, status, imageHubErr); err != nil {
+		reqLogger.Error(err, "failed to set rainbondcluster conditions")
+		return reconcile.Result{RequeueAfter: time.Second * 2}, err
+	}
+	if !equality.Semantic.DeepEqual(oldStatus, status) {
+		rainbondcluster.Status = status
+		if err := r.client.Status().Update(ctx, rainbondcluster); err != nil {
+			reqLogger.Error(err, "failed to update rainbondcluster status")
 			return reconcile.Result{RequeueAfter: time.Second * 2}, err
 		}
 	}
 
-	return reconcile.Result{Requeue: false}, nil
+	if imageHubErr != nil {
+		return reconcile.Result{RequeueAfter: time.Second * 2}, imageHubErr
+	}
+
+	return reconcile.Result{RequeueAfter: upgradeWait}, nil
 }
 
 func (r *ReconcileRainbondCluster) availableStorageClasses() []*rainbondv1alpha1.StorageClass {
@@ -260,7 +385,7 @@ func checkPortOccupation(address string) bool {
 func (r *ReconcileRainbondCluster) generateRainbondClusterStatus(ctx context.Context, rainbondCluster *rainbondv1alpha1.RainbondCluster) (*rainbondv1alpha1.RainbondClusterStatus, error) {
 	klog.Infof("Generating status for %q", format.RainbondCluster(rainbondCluster))
 
-	masterRoleLabel, err := r.getMasterRoleLabel(ctx)
+	masterRoleLabel, err := r.getMasterRoleLabel(ctx, rainbondCluster)
 	if err != nil {
 		return nil, fmt.Errorf("get master role label: %v", err)
 	}
@@ -270,57 +395,15 @@ func (r *ReconcileRainbondCluster) generateRainbondClusterStatus(ctx context.Con
 		MasterRoleLabel: masterRoleLabel,
 		StorageClasses:  r.availableStorageClasses(),
 	}
-	s.NodeAvailPorts = r.listNodeAvailablePorts(s.MasterNodeLabel())
-	s.MasterNodeNames = r.listMasterNodeNames(s.MasterNodeLabel())
+	s.NodeAvailPorts = r.listNodeAvailablePorts(nodeSelectorOf(rainbondCluster, s.MasterNodeLabel()))
+	s.MasterNodeNames = r.listMasterNodeNames(nodeSelectorOf(rainbondCluster, s.MasterNodeLabel()))
 
 	return s, nil
 }
 
-func (r *ReconcileRainbondCluster) claims(cluster *rainbondv1alpha1.RainbondCluster) []*corev1.PersistentVolumeClaim {
-	storageRequest := resource.NewQuantity(10, resource.BinarySI) // TODO: size
-
-	grdata := &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: constants.Namespace,
-			Name:      constants.GrDataPVC,
-		},
-		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{
-				corev1.ReadWriteMany,
-			},
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: *storageRequest,
-				},
-			},
-			StorageClassName: commonutil.String(rbdutil.GetStorageClass(cluster)),
-		},
-	}
-
-	cache := &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: constants.Namespace,
-			Name:      "cache",
-		},
-		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{
-				corev1.ReadWriteMany,
-			},
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: *storageRequest,
-				},
-			},
-			StorageClassName: commonutil.String(rbdutil.GetStorageClass(cluster)),
-		},
-	}
-
-	return []*corev1.PersistentVolumeClaim{grdata, cache}
-}
-
-func (r *ReconcileRainbondCluster) getMasterRoleLabel(ctx context.Context) (string, error) {
+func (r *ReconcileRainbondCluster) getMasterRoleLabel(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) (string, error) {
 	nodes := &corev1.NodeList{}
-	if err := r.client.List(ctx, nodes); err != nil {
+	if err := r.client.List(ctx, nodes, client.MatchingLabels(cluster.Spec.NodeSelector)); err != nil {
 		log.Error(err, "list nodes: %v", err)
 		return "", nil
 	}
@@ -339,33 +422,7 @@ func (r *ReconcileRainbondCluster) getMasterRoleLabel(ctx context.Context) (stri
 }
 
 func (r *ReconcileRainbondCluster) getImageHub(cluster *rainbondv1alpha1.RainbondCluster) (*rainbondv1alpha1.ImageHub, error) {
-	imageHubReady := func() error {
-		httpClient := &http.Client{
-			Timeout: 1 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true, // TODO: can't ignore TLS
-				},
-			},
-		}
-
-		domain := rbdutil.GetImageRepository(cluster)
-		u, err := url.Parse(fmt.Sprintf("https://%s/v2/", cluster.GatewayIngressIP()))
-		if err != nil {
-			return fmt.Errorf("failed to parse url %s: %v", fmt.Sprintf("https://%s/v2/", domain), err)
-		}
-
-		request := &http.Request{URL: u, Host: domain}
-		res, err := httpClient.Do(request)
-		if err != nil {
-			return fmt.Errorf("image repository unavailable: %v", err)
-		}
-		if res.StatusCode != http.StatusOK {
-			return fmt.Errorf("image repository unavailable. http status code: %d", res.StatusCode)
-		}
-		return nil
-	}
-	if err := imageHubReady(); err != nil {
+	if err := probeImageHub(cluster.GatewayIngressIP(), rbdutil.GetImageRepository(cluster)); err != nil {
 		return nil, fmt.Errorf("image repository not ready: %v", err)
 	}
 
@@ -373,3 +430,61 @@ func (r *ReconcileRainbondCluster) getImageHub(cluster *rainbondv1alpha1.Rainbon
 		Domain: "goodrain.me",
 	}, nil
 }
+
+// imageHubProbeTTL is how long the result of probing an external image repository is reused, so the
+// reconciliations of the cluster do not wait for the repository every time.
+const imageHubProbeTTL = 30 * time.Second
+
+// imageHubProbes are the last results of probeImageHub, by the address and the host probed.
+var imageHubProbes = struct {
+	sync.Mutex
+	m map[string]imageHubProbe
+}{m: make(map[string]imageHubProbe)}
+
+type imageHubProbe struct {
+	err       error
+	probeTime time.Time
+}
+
+// cachedProbeImageHub returns the result of the last probeImageHub of the repository within imageHubProbeTTL,
+// or probes it again.
+func cachedProbeImageHub(addr, host string) error {
+	key := addr + "/" + host
+	imageHubProbes.Lock()
+	defer imageHubProbes.Unlock()
+	if probe, ok := imageHubProbes.m[key]; ok && time.Since(probe.probeTime) < imageHubProbeTTL {
+		return probe.err
+	}
+	err := probeImageHub(addr, host)
+	imageHubProbes.m[key] = imageHubProbe{err: err, probeTime: time.Now()}
+	return err
+}
+
+// probeImageHub requests the /v2/ endpoint of the image repository at addr, with host as the Host header.
+// The repository answers 401 if authentication is required, which still means it is up.
+func probeImageHub(addr, host string) error {
+	httpClient := &http.Client{
+		Timeout: 1 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true, // TODO: can't ignore TLS
+			},
+		},
+	}
+
+	u, err := url.Parse(fmt.Sprintf("https://%s/v2/", addr))
+	if err != nil {
+		return fmt.Errorf("failed to parse url %s: %v", fmt.Sprintf("https://%s/v2/", addr), err)
+	}
+
+	request := &http.Request{URL: u, Host: host}
+	res, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("image repository unavailable: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("image repository unavailable. http status code: %d", res.StatusCode)
+	}
+	return nil
+}
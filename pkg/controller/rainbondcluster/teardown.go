@@ -0,0 +1,94 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// teardown deletes what belongs to the rainbondcluster in dependency order: the components first,
+// then the claims, then the storage components, and the rainbondpackage at last.
+// It returns true once everything is gone.
+func (r *ReconcileRainbondCluster) teardown(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) (bool, error) {
+	cpts := &rainbondv1alpha1.RbdComponentList{}
+	if err := r.client.List(ctx, cpts, client.InNamespace(cluster.Namespace)); err != nil {
+		return false, fmt.Errorf("list rbdcomponents: %v", err)
+	}
+	var components, storageComponents []rainbondv1alpha1.RbdComponent
+	for _, cpt := range cpts.Items {
+		if cpt.RainbondClusterName() != cluster.Name {
+			continue
+		}
+		if chandler.IsStorageComponent(cpt.Name) {
+			storageComponents = append(storageComponents, cpt)
+		} else {
+			components = append(components, cpt)
+		}
+	}
+
+	if len(components) > 0 {
+		return false, r.deleteComponents(ctx, components)
+	}
+
+	// the claims are provisioned by the storage components
+	claimsGone := true
+	for _, claim := range rbdutil.ClusterClaims(cluster) {
+		old := &corev1.PersistentVolumeClaim{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}, old); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, fmt.Errorf("get claim %s: %v", claim.Name, err)
+		}
+		if !metav1.IsControlledBy(old, cluster) {
+			continue
+		}
+		claimsGone = false
+		if old.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.client.Delete(ctx, old); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("delete claim %s: %v", claim.Name, err)
+		}
+	}
+	if !claimsGone {
+		return false, nil
+	}
+
+	if len(storageComponents) > 0 {
+		return false, r.deleteComponents(ctx, storageComponents)
+	}
+
+	pkg, err := rbdutil.GetRainbondPackage(ctx, r.client, cluster)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("get rainbondpackage: %v", err)
+	}
+	if err := r.client.Delete(ctx, pkg); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("delete rainbondpackage: %v", err)
+	}
+	return true, nil
+}
+
+func (r *ReconcileRainbondCluster) deleteComponents(ctx context.Context, components []rainbondv1alpha1.RbdComponent) error {
+	for i := range components {
+		cpt := &components[i]
+		if cpt.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.client.Delete(ctx, cpt); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete rbdcomponent %s: %v", cpt.Name, err)
+		}
+	}
+	return nil
+}
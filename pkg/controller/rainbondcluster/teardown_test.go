@@ -0,0 +1,71 @@
+package rainbondcluster
+
+import (
+	"context"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTeardown(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "rbd-system", Name: name}
+	}
+	cluster := &rainbondv1alpha1.RainbondCluster{ObjectMeta: objectMeta("rainbondcluster")}
+	cli := fake.NewFakeClientWithScheme(scheme,
+		cluster,
+		&rainbondv1alpha1.RbdComponent{ObjectMeta: objectMeta("rbd-api")},
+		&rainbondv1alpha1.RbdComponent{ObjectMeta: objectMeta("rbd-db")},
+		&rainbondv1alpha1.RbdComponent{ObjectMeta: objectMeta("rbd-worker"), Spec: rainbondv1alpha1.RbdComponentSpec{ClusterName: "other"}},
+		&rainbondv1alpha1.RainbondPackage{ObjectMeta: objectMeta("rainbondpackage")},
+	)
+	r := &ReconcileRainbondCluster{client: cli, scheme: scheme}
+
+	exists := func(obj runtime.Object, name string) bool {
+		return cli.Get(context.Background(), types.NamespacedName{Namespace: "rbd-system", Name: name}, obj) == nil
+	}
+	tests := []struct {
+		name                             string
+		wantDone                         bool
+		wantAPI, wantDB, wantPkg, wantWk bool
+	}{
+		{name: "components first", wantDB: true, wantPkg: true, wantWk: true},
+		{name: "then storage components", wantPkg: true, wantWk: true},
+		{name: "then rainbondpackage", wantDone: true, wantWk: true},
+		{name: "done", wantDone: true, wantWk: true},
+	}
+	for _, tc := range tests {
+		done, err := r.teardown(context.Background(), cluster)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if done != tc.wantDone {
+			t.Errorf("%s: Expected done %v, but got %v", tc.name, tc.wantDone, done)
+		}
+		if got := exists(&rainbondv1alpha1.RbdComponent{}, "rbd-api"); got != tc.wantAPI {
+			t.Errorf("%s: Expected rbd-api exists %v, but got %v", tc.name, tc.wantAPI, got)
+		}
+		if got := exists(&rainbondv1alpha1.RbdComponent{}, "rbd-db"); got != tc.wantDB {
+			t.Errorf("%s: Expected rbd-db exists %v, but got %v", tc.name, tc.wantDB, got)
+		}
+		if got := exists(&rainbondv1alpha1.RainbondPackage{}, "rainbondpackage"); got != tc.wantPkg {
+			t.Errorf("%s: Expected rainbondpackage exists %v, but got %v", tc.name, tc.wantPkg, got)
+		}
+		if got := exists(&rainbondv1alpha1.RbdComponent{}, "rbd-worker"); got != tc.wantWk {
+			t.Errorf("%s: Expected rbd-worker of other cluster exists %v, but got %v", tc.name, tc.wantWk, got)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+// ComponentUpgradeTimeout is how long a component has to become ready with the image of the new version,
+// before the upgrade is rolled back.
+var ComponentUpgradeTimeout = 10 * time.Minute
+
+// UpgradeStagingTimeout is how long the images of the new version may take to be staged, before the upgrade fails.
+var UpgradeStagingTimeout = time.Hour
+
+// upgradeCheckInterval is how often an upgrade in progress is checked, besides the changes of the components.
+const upgradeCheckInterval = 10 * time.Second
+
+// upgrade rolls the components forward to the install version of the rainbondcluster, one by one in the order
+// of their dependencies, once the rainbondpackage has staged the images of the new version.
+// It returns how long to wait before checking the upgrade again, zero if there is nothing to wait for.
+func (r *ReconcileRainbondCluster) upgrade(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, status *rainbondv1alpha1.RainbondClusterStatus) (time.Duration, error) {
+	version := rbdutil.GetInstallVersion(cluster)
+	if status.CurrentVersion == "" {
+		if !status.IsConditionTrue(rainbondv1alpha1.RainbondClusterConditionComponentsReady) {
+			return 0, nil
+		}
+		// the install version may have been changed on a cluster created before the upgrades were tracked
+		cpts, err := r.listComponents(ctx, cluster)
+		if err != nil {
+			return 0, err
+		}
+		status.CurrentVersion = runningVersion(cpts, version)
+		if status.CurrentVersion == version {
+			return 0, nil
+		}
+		return upgradeCheckInterval, nil
+	}
+
+	if status.Upgrade != nil && status.Upgrade.ToVersion != version && upgradeInProgress(status.Upgrade) {
+		if status.Upgrade.Phase != rainbondv1alpha1.UpgradeRollingBack {
+			r.startRollBack(cluster, status.Upgrade, fmt.Sprintf("the install version has changed to %s", version))
+		}
+		wait, err := r.rollBack(ctx, cluster, status.Upgrade)
+		if err != nil || wait > 0 {
+			return wait, err
+		}
+		// plan the next upgrade after the restored images are observed
+		return upgradeCheckInterval, nil
+	}
+	if status.CurrentVersion == version {
+		return 0, nil
+	}
+	if status.Upgrade == nil || status.Upgrade.ToVersion != version {
+		upgrade, err := r.newUpgrade(ctx, cluster, status.CurrentVersion, version)
+		if err != nil {
+			return 0, err
+		}
+		status.Upgrade = upgrade
+		r.recorder.Eventf(cluster, corev1.EventTypeNormal, "UpgradeStarted", "Upgrading from %s to %s", upgrade.FromVersion, upgrade.ToVersion)
+	}
+
+	var wait time.Duration
+	var err error
+	switch status.Upgrade.Phase {
+	case rainbondv1alpha1.UpgradeStaging:
+		wait, err = r.stage(ctx, cluster, status.Upgrade)
+	case rainbondv1alpha1.UpgradeRollingForward:
+		wait, err = r.rollForward(ctx, cluster, status.Upgrade)
+	case rainbondv1alpha1.UpgradeRollingBack:
+		wait, err = r.rollBack(ctx, cluster, status.Upgrade)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status.Upgrade.Phase == rainbondv1alpha1.UpgradeCompleted {
+		status.CurrentVersion = status.Upgrade.ToVersion
+	}
+	return wait, nil
+}
+
+func upgradeInProgress(upgrade *rainbondv1alpha1.UpgradeStatus) bool {
+	switch upgrade.Phase {
+	case rainbondv1alpha1.UpgradeStaging, rainbondv1alpha1.UpgradeRollingForward, rainbondv1alpha1.UpgradeRollingBack:
+		return true
+	}
+	return false
+}
+
+// runningVersion returns the version most of the components run, by their versions, or by the tags of their images
+// if none of them has a version. It returns the install version if the components tell nothing.
+func runningVersion(cpts []*rainbondv1alpha1.RbdComponent, installVersion string) string {
+	count := func(versionOf func(cpt *rainbondv1alpha1.RbdComponent) string) string {
+		counts := make(map[string]int)
+		var most string
+		for _, cpt := range cpts {
+			version := versionOf(cpt)
+			if version == "" {
+				continue
+			}
+			counts[version]++
+			if counts[version] > counts[most] || counts[version] == counts[most] && version < most {
+				most = version
+			}
+		}
+		return most
+	}
+	if version := count(func(cpt *rainbondv1alpha1.RbdComponent) string { return cpt.Spec.Version }); version != "" {
+		return version
+	}
+	if version := count(func(cpt *rainbondv1alpha1.RbdComponent) string { return imageTag(cpt.Spec.Image) }); version != "" {
+		return version
+	}
+	return installVersion
+}
+
+// newUpgrade plans the upgrade of the components whose images are tagged with the current version.
+func (r *ReconcileRainbondCluster) newUpgrade(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, from, to string) (*rainbondv1alpha1.UpgradeStatus, error) {
+	cpts, err := r.listComponents(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	upgrade := &rainbondv1alpha1.UpgradeStatus{
+		FromVersion: from,
+		ToVersion:   to,
+		Phase:       rainbondv1alpha1.UpgradeStaging,
+		StartTime:   metav1.Now(),
+		Message:     fmt.Sprintf("waiting for the images of %s to be pushed", to),
+	}
+	for _, cpt := range dependencyOrder(cpts) {
+		image, ok := imageWithVersion(cpt.Spec.Image, from, to)
+		if !ok {
+			continue
+		}
+		upgrade.Components = append(upgrade.Components, rainbondv1alpha1.ComponentUpgradeStatus{
+			Name:          cpt.Name,
+			Phase:         rainbondv1alpha1.ComponentUpgradePending,
+			PreviousImage: cpt.Spec.Image,
+			Image:         image,
+		})
+	}
+	return upgrade, nil
+}
+
+// stage waits for the rainbondpackage to push the images of the new version, for up to UpgradeStagingTimeout.
+// In the WithPackage mode, the package has to be of the new version.
+func (r *ReconcileRainbondCluster) stage(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, upgrade *rainbondv1alpha1.UpgradeStatus) (time.Duration, error) {
+	if time.Since(upgrade.StartTime.Time) >= UpgradeStagingTimeout {
+		upgrade.Phase = rainbondv1alpha1.UpgradeFailed
+		upgrade.Message = fmt.Sprintf("the images of %s are not staged after %s: %s", upgrade.ToVersion, UpgradeStagingTimeout, upgrade.Message)
+		r.recorder.Eventf(cluster, corev1.EventTypeWarning, "UpgradeFailed", "The images of %s are not staged after %s", upgrade.ToVersion, UpgradeStagingTimeout)
+		return 0, nil
+	}
+	if cluster.Spec.InstallMode == rainbondv1alpha1.InstallationModeWithPackage && cluster.Spec.InstallPackageConfig.Version != upgrade.ToVersion {
+		upgrade.Message = fmt.Sprintf("waiting for installPackageConfig to be set to the package of %s", upgrade.ToVersion)
+		return upgradeCheckInterval, nil
+	}
+
+	pkg, err := rbdutil.GetRainbondPackage(ctx, r.client, cluster)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			upgrade.Message = "rainbondpackage not found"
+			return upgradeCheckInterval, nil
+		}
+		return 0, fmt.Errorf("get rainbondpackage: %v", err)
+	}
+	if pkg.Status == nil || pkg.Status.Version != upgrade.ToVersion {
+		return upgradeCheckInterval, nil
+	}
+	for _, cond := range pkg.Status.Conditions {
+		if cond.Status == rainbondv1alpha1.Failed {
+			upgrade.Phase = rainbondv1alpha1.UpgradeFailed
+			upgrade.Message = fmt.Sprintf("failed to stage the images of %s: %s", upgrade.ToVersion, cond.Message)
+			r.recorder.Eventf(cluster, corev1.EventTypeWarning, "UpgradeFailed", "Failed to stage the images of %s: %s", upgrade.ToVersion, cond.Message)
+			return 0, nil
+		}
+		if cond.Type == rainbondv1alpha1.Ready && cond.Status == rainbondv1alpha1.Completed {
+			upgrade.Phase = rainbondv1alpha1.UpgradeRollingForward
+			upgrade.Message = ""
+			r.recorder.Eventf(cluster, corev1.EventTypeNormal, "UpgradeStaged", "The images of %s are staged", upgrade.ToVersion)
+			return r.rollForward(ctx, cluster, upgrade)
+		}
+	}
+	return upgradeCheckInterval, nil
+}
+
+// rollForward upgrades the next component once the previous one is ready with its new image,
+// and rolls the upgrade back if a component is not ready in time.
+func (r *ReconcileRainbondCluster) rollForward(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, upgrade *rainbondv1alpha1.UpgradeStatus) (time.Duration, error) {
+	for i := range upgrade.Components {
+		status := &upgrade.Components[i]
+		if status.Phase == rainbondv1alpha1.ComponentUpgradeUpgraded {
+			continue
+		}
+
+		cpt := &rainbondv1alpha1.RbdComponent{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: status.Name}, cpt); err != nil {
+			if errors.IsNotFound(err) {
+				status.Phase = rainbondv1alpha1.ComponentUpgradeUpgraded
+				status.Message = "rbdcomponent not found"
+				continue
+			}
+			return 0, fmt.Errorf("get rbdcomponent %s: %v", status.Name, err)
+		}
+
+		if status.Phase == rainbondv1alpha1.ComponentUpgradePending {
+			cpt.Spec.Image = status.Image
+			cpt.Spec.Version = upgrade.ToVersion
+			if err := r.client.Update(ctx, cpt); err != nil {
+				return 0, fmt.Errorf("update image of rbdcomponent %s: %v", cpt.Name, err)
+			}
+			status.Phase = rainbondv1alpha1.ComponentUpgradeUpgrading
+			status.StartTime = metav1.Now()
+			upgrade.Message = fmt.Sprintf("upgrading %s", cpt.Name)
+			r.recorder.Eventf(cluster, corev1.EventTypeNormal, "UpgradingComponent", "Upgrading %s to %s", cpt.Name, status.Image)
+			return upgradeCheckInterval, nil
+		}
+
+		if upgradedReady(cpt, status.Image) {
+			status.Phase = rainbondv1alpha1.ComponentUpgradeUpgraded
+			status.Message = ""
+			continue
+		}
+		if time.Since(status.StartTime.Time) < ComponentUpgradeTimeout {
+			return upgradeCheckInterval, nil
+		}
+		status.Phase = rainbondv1alpha1.ComponentUpgradeFailed
+		status.Message = fmt.Sprintf("not ready after %s", ComponentUpgradeTimeout)
+		r.startRollBack(cluster, upgrade, fmt.Sprintf("%s is not ready after %s", cpt.Name, ComponentUpgradeTimeout))
+		return r.rollBack(ctx, cluster, upgrade)
+	}
+
+	upgrade.Phase = rainbondv1alpha1.UpgradeCompleted
+	upgrade.Message = ""
+	r.recorder.Eventf(cluster, corev1.EventTypeNormal, "Upgraded", "Upgraded from %s to %s", upgrade.FromVersion, upgrade.ToVersion)
+	return 0, nil
+}
+
+// startRollBack starts to restore the components changed by the upgrade.
+func (r *ReconcileRainbondCluster) startRollBack(cluster *rainbondv1alpha1.RainbondCluster, upgrade *rainbondv1alpha1.UpgradeStatus, reason string) {
+	upgrade.Phase = rainbondv1alpha1.UpgradeRollingBack
+	upgrade.Message = reason
+	r.recorder.Eventf(cluster, corev1.EventTypeWarning, "UpgradeRollingBack", "Rolling back the upgrade to %s: %s", upgrade.ToVersion, reason)
+}
+
+// rollBack restores the previous images of the components changed by the upgrade in the reverse order of rollForward.
+// The next component is restored once the previous one is ready with its previous image, or is not ready in time.
+func (r *ReconcileRainbondCluster) rollBack(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster, upgrade *rainbondv1alpha1.UpgradeStatus) (time.Duration, error) {
+	for i := len(upgrade.Components) - 1; i >= 0; i-- {
+		status := &upgrade.Components[i]
+		if status.Phase == rainbondv1alpha1.ComponentUpgradePending || status.Phase == rainbondv1alpha1.ComponentUpgradeRolledBack {
+			continue
+		}
+		cpt := &rainbondv1alpha1.RbdComponent{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: status.Name}, cpt); err != nil {
+			if errors.IsNotFound(err) {
+				status.Phase = rainbondv1alpha1.ComponentUpgradeRolledBack
+				continue
+			}
+			return 0, fmt.Errorf("get rbdcomponent %s: %v", status.Name, err)
+		}
+
+		if status.Phase != rainbondv1alpha1.ComponentUpgradeRollingBack {
+			cpt.Spec.Image = status.PreviousImage
+			cpt.Spec.Version = upgrade.FromVersion
+			if err := r.client.Update(ctx, cpt); err != nil {
+				return 0, fmt.Errorf("restore image of rbdcomponent %s: %v", cpt.Name, err)
+			}
+			status.Phase = rainbondv1alpha1.ComponentUpgradeRollingBack
+			status.StartTime = metav1.Now()
+			r.recorder.Eventf(cluster, corev1.EventTypeNormal, "RollingBackComponent", "Restoring %s to %s", cpt.Name, status.PreviousImage)
+			return upgradeCheckInterval, nil
+		}
+
+		if !upgradedReady(cpt, status.PreviousImage) {
+			if time.Since(status.StartTime.Time) < ComponentUpgradeTimeout {
+				return upgradeCheckInterval, nil
+			}
+			status.Message = fmt.Sprintf("not ready with the previous image after %s", ComponentUpgradeTimeout)
+		}
+		status.Phase = rainbondv1alpha1.ComponentUpgradeRolledBack
+	}
+	upgrade.Phase = rainbondv1alpha1.UpgradeRolledBack
+	r.recorder.Eventf(cluster, corev1.EventTypeWarning, "UpgradeRolledBack", "Rolled back the upgrade to %s: %s", upgrade.ToVersion, upgrade.Message)
+	return 0, nil
+}
+
+func (r *ReconcileRainbondCluster) listComponents(ctx context.Context, cluster *rainbondv1alpha1.RainbondCluster) ([]*rainbondv1alpha1.RbdComponent, error) {
+	list := &rainbondv1alpha1.RbdComponentList{}
+	if err := r.client.List(ctx, list, client.InNamespace(cluster.Namespace)); err != nil {
+		return nil, fmt.Errorf("list rbdcomponents: %v", err)
+	}
+	var cpts []*rainbondv1alpha1.RbdComponent
+	for i := range list.Items {
+		if list.Items[i].RainbondClusterName() == cluster.Name {
+			cpts = append(cpts, &list.Items[i])
+		}
+	}
+	return cpts, nil
+}
+
+// dependencyOrder sorts the components so that each one comes after the components in its DependsOn.
+// Components in a dependency cycle are appended in the order of their names.
+func dependencyOrder(cpts []*rainbondv1alpha1.RbdComponent) []*rainbondv1alpha1.RbdComponent {
+	remaining := make(map[string]*rainbondv1alpha1.RbdComponent, len(cpts))
+	for _, cpt := range cpts {
+		remaining[cpt.Name] = cpt
+	}
+	sortedNames := func() []string {
+		var names []string
+		for name := range remaining {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	var ordered []*rainbondv1alpha1.RbdComponent
+	for len(remaining) > 0 {
+		var ready []string
+		for _, name := range sortedNames() {
+			waiting := false
+			for _, dep := range remaining[name].Spec.DependsOn {
+				if _, ok := remaining[dep]; ok {
+					waiting = true
+					break
+				}
+			}
+			if !waiting {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			ready = sortedNames()
+		}
+		for _, name := range ready {
+			ordered = append(ordered, remaining[name])
+			delete(remaining, name)
+		}
+	}
+	return ordered
+}
+
+// imageWithVersion replaces the tag of the image if it is the from version.
+func imageWithVersion(image, from, to string) (string, bool) {
+	if tag := imageTag(image); tag == "" || tag != from {
+		return "", false
+	}
+	return image[:strings.LastIndex(image, ":")+1] + to, true
+}
+
+// imageTag returns the tag of the image, empty if it has none.
+func imageTag(image string) string {
+	idx := strings.LastIndex(image, ":")
+	if idx < 0 || strings.Contains(image[idx:], "/") {
+		return ""
+	}
+	return image[idx+1:]
+}
+
+// upgradedReady checks if all replicas of the RbdComponent are updated and ready, and every pod runs the given image.
+func upgradedReady(cpt *rainbondv1alpha1.RbdComponent, image string) bool {
+	if !componentReady(cpt) {
+		return false
+	}
+	if cpt.Status.ControllerType == rainbondv1alpha1.ControllerTypeUnknown {
+		return true
+	}
+	// the status may be generated before the new image is applied
+	if cpt.Status.ObservedGeneration < cpt.Generation {
+		return false
+	}
+	if cpt.Status.UpdatedReplicas < cpt.Status.Replicas || len(cpt.Status.Pods) == 0 {
+		return false
+	}
+	for _, pod := range cpt.Status.Pods {
+		if podImage(cpt, pod) != image {
+			return false
+		}
+	}
+	return true
+}
+
+// podImage returns the image of the main container of the pod, which is named after the RbdComponent, or the first one.
+func podImage(cpt *rainbondv1alpha1.RbdComponent, pod rainbondv1alpha1.RbdComponentPodStatus) string {
+	var image string
+	for i, cs := range pod.ContainerStatuses {
+		if cs.Name == cpt.Name {
+			return cs.Image
+		}
+		if i == 0 {
+			image = cs.Image
+		}
+	}
+	return image
+}
@@ -0,0 +1,390 @@
+package rainbondcluster
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestImageWithVersion(t *testing.T) {
+	tests := []struct {
+		image  string
+		want   string
+		wantOK bool
+	}{
+		{image: "goodrain.me/rbd-api:V5.2-dev", want: "goodrain.me/rbd-api:V5.3", wantOK: true},
+		{image: "goodrain.me/rbd-db:v5.1.9"},
+		{image: "goodrain.me/rbd-dns"},
+		{image: "goodrain.me:5000/rbd-dns"},
+	}
+	for _, tc := range tests {
+		got, ok := imageWithVersion(tc.image, "V5.2-dev", "V5.3")
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: Expected %s %v, but got %s %v", tc.image, tc.want, tc.wantOK, got, ok)
+		}
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	newComponent := func(name string, dependsOn ...string) *rainbondv1alpha1.RbdComponent {
+		cpt := &rainbondv1alpha1.RbdComponent{Spec: rainbondv1alpha1.RbdComponentSpec{DependsOn: dependsOn}}
+		cpt.Name = name
+		return cpt
+	}
+	tests := []struct {
+		name string
+		cpts []*rainbondv1alpha1.RbdComponent
+		want []string
+	}{
+		{
+			name: "dependencies first",
+			cpts: []*rainbondv1alpha1.RbdComponent{
+				newComponent("rbd-api", "rbd-db", "rbd-etcd"),
+				newComponent("rbd-app-ui", "rbd-api"),
+				newComponent("rbd-db"),
+				newComponent("rbd-etcd"),
+			},
+			want: []string{"rbd-db", "rbd-etcd", "rbd-api", "rbd-app-ui"},
+		},
+		{
+			name: "missing dependency",
+			cpts: []*rainbondv1alpha1.RbdComponent{
+				newComponent("rbd-worker", "rbd-db"),
+				newComponent("rbd-api"),
+			},
+			want: []string{"rbd-api", "rbd-worker"},
+		},
+		{
+			name: "cycle",
+			cpts: []*rainbondv1alpha1.RbdComponent{
+				newComponent("rbd-worker", "rbd-api"),
+				newComponent("rbd-api", "rbd-worker"),
+				newComponent("rbd-mq", "rbd-api"),
+			},
+			want: []string{"rbd-api", "rbd-mq", "rbd-worker"},
+		},
+	}
+	for _, tc := range tests {
+		var got []string
+		for _, cpt := range dependencyOrder(tc.cpts) {
+			got = append(got, cpt.Name)
+		}
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s: Expected %v, but got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "rbd-system", Name: name}
+	}
+	newComponent := func(name, image string, dependsOn ...string) *rainbondv1alpha1.RbdComponent {
+		return &rainbondv1alpha1.RbdComponent{
+			ObjectMeta: objectMeta(name),
+			Spec:       rainbondv1alpha1.RbdComponentSpec{Image: image, DependsOn: dependsOn},
+		}
+	}
+	image := func(cli client.Client, name string) string {
+		cpt := &rainbondv1alpha1.RbdComponent{}
+		if err := cli.Get(context.Background(), types.NamespacedName{Namespace: "rbd-system", Name: name}, cpt); err != nil {
+			t.Fatal(err)
+		}
+		return cpt.Spec.Image
+	}
+	setReady := func(cli client.Client, name string) {
+		cpt := &rainbondv1alpha1.RbdComponent{}
+		if err := cli.Get(context.Background(), types.NamespacedName{Namespace: "rbd-system", Name: name}, cpt); err != nil {
+			t.Fatal(err)
+		}
+		cpt.Status = &rainbondv1alpha1.RbdComponentStatus{
+			ControllerType:     rainbondv1alpha1.ControllerTypeDeployment,
+			ObservedGeneration: cpt.Generation,
+			Replicas:           1,
+			ReadyReplicas:      1,
+			UpdatedReplicas:    1,
+			Image:              cpt.Spec.Image,
+			Pods: []rainbondv1alpha1.RbdComponentPodStatus{{
+				Name:              name + "-0",
+				Phase:             "Ready",
+				ContainerStatuses: []rainbondv1alpha1.RbdComponentPodContainerStatus{{Name: name, Image: cpt.Spec.Image, Ready: true, State: "Running"}},
+			}},
+		}
+		if err := cli.Update(context.Background(), cpt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		apiReady        bool
+		wantPhase       rainbondv1alpha1.UpgradePhase
+		wantVersion     string
+		wantAPI, wantWk string
+	}{
+		{
+			name:        "completed",
+			apiReady:    true,
+			wantPhase:   rainbondv1alpha1.UpgradeCompleted,
+			wantVersion: "V5.3",
+			wantAPI:     "goodrain.me/rbd-api:V5.3",
+			wantWk:      "goodrain.me/rbd-worker:V5.3",
+		},
+		{
+			name:        "rolled back",
+			wantPhase:   rainbondv1alpha1.UpgradeRolledBack,
+			wantVersion: "V5.2-dev",
+			wantAPI:     "goodrain.me/rbd-api:V5.2-dev",
+			wantWk:      "goodrain.me/rbd-worker:V5.2-dev",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &rainbondv1alpha1.RainbondCluster{
+				ObjectMeta: objectMeta("rainbondcluster"),
+				Spec:       rainbondv1alpha1.RainbondClusterSpec{InstallVersion: "V5.3"},
+			}
+			pkg := &rainbondv1alpha1.RainbondPackage{
+				ObjectMeta: objectMeta("rainbondpackage"),
+				Status: &rainbondv1alpha1.RainbondPackageStatus{
+					Version:    "V5.3",
+					Conditions: []rainbondv1alpha1.PackageCondition{{Type: rainbondv1alpha1.Ready, Status: rainbondv1alpha1.Completed}},
+				},
+			}
+			cli := fake.NewFakeClientWithScheme(scheme,
+				cluster,
+				pkg,
+				newComponent("rbd-api", "goodrain.me/rbd-api:V5.2-dev", "rbd-worker"),
+				newComponent("rbd-worker", "goodrain.me/rbd-worker:V5.2-dev", "rbd-etcd"),
+				newComponent("rbd-etcd", "goodrain.me/etcd:v3.3.18"),
+			)
+			r := &ReconcileRainbondCluster{client: cli, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+			status := &rainbondv1alpha1.RainbondClusterStatus{CurrentVersion: "V5.2-dev"}
+
+			// stages and upgrades rbd-worker first
+			if _, err := r.upgrade(context.Background(), cluster, status); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, cpt := range status.Upgrade.Components {
+				names = append(names, cpt.Name)
+			}
+			if want := []string{"rbd-worker", "rbd-api"}; !reflect.DeepEqual(want, names) {
+				t.Fatalf("Expected %v, but got %v", want, names)
+			}
+			if got := image(cli, "rbd-api"); got != "goodrain.me/rbd-api:V5.2-dev" {
+				t.Errorf("Expected rbd-api to wait for rbd-worker, but got %s", got)
+			}
+
+			// rbd-api is upgraded once rbd-worker is ready
+			setReady(cli, "rbd-worker")
+			if _, err := r.upgrade(context.Background(), cluster, status); err != nil {
+				t.Fatal(err)
+			}
+			if got := image(cli, "rbd-api"); got != "goodrain.me/rbd-api:V5.3" {
+				t.Errorf("Expected %s, but got %s", "goodrain.me/rbd-api:V5.3", got)
+			}
+
+			if tc.apiReady {
+				setReady(cli, "rbd-api")
+			} else {
+				status.Upgrade.Components[1].StartTime = metav1.NewTime(time.Now().Add(-ComponentUpgradeTimeout))
+			}
+			if _, err := r.upgrade(context.Background(), cluster, status); err != nil {
+				t.Fatal(err)
+			}
+			if !tc.apiReady {
+				// rbd-api is restored first, rbd-worker once rbd-api is ready again
+				if got := image(cli, "rbd-worker"); got != "goodrain.me/rbd-worker:V5.3" {
+					t.Errorf("Expected rbd-worker to wait for rbd-api, but got %s", got)
+				}
+				for _, name := range []string{"rbd-api", "rbd-worker"} {
+					setReady(cli, name)
+					if _, err := r.upgrade(context.Background(), cluster, status); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if status.Upgrade.Phase != tc.wantPhase {
+				t.Errorf("Expected %s, but got %s", tc.wantPhase, status.Upgrade.Phase)
+			}
+			if status.CurrentVersion != tc.wantVersion {
+				t.Errorf("Expected %s, but got %s", tc.wantVersion, status.CurrentVersion)
+			}
+			if got := image(cli, "rbd-api"); got != tc.wantAPI {
+				t.Errorf("Expected %s, but got %s", tc.wantAPI, got)
+			}
+			if got := image(cli, "rbd-worker"); got != tc.wantWk {
+				t.Errorf("Expected %s, but got %s", tc.wantWk, got)
+			}
+		})
+	}
+}
+
+func TestUpgradedReady(t *testing.T) {
+	pod := func(name string, images ...string) rainbondv1alpha1.RbdComponentPodStatus {
+		pod := rainbondv1alpha1.RbdComponentPodStatus{Name: name, Phase: "Ready"}
+		for i, image := range images {
+			name := "rbd-api"
+			if i > 0 {
+				name = fmt.Sprintf("sidecar-%d", i)
+			}
+			pod.ContainerStatuses = append(pod.ContainerStatuses, rainbondv1alpha1.RbdComponentPodContainerStatus{Name: name, Image: image, State: "Running"})
+		}
+		return pod
+	}
+	newComponent := func(generation, observed int64, updated int32, pods ...rainbondv1alpha1.RbdComponentPodStatus) *rainbondv1alpha1.RbdComponent {
+		return &rainbondv1alpha1.RbdComponent{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbd-api", Generation: generation},
+			Status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType:     rainbondv1alpha1.ControllerTypeDeployment,
+				ObservedGeneration: observed,
+				Replicas:           2,
+				ReadyReplicas:      2,
+				UpdatedReplicas:    updated,
+				Image:              "rbd-api:V5.3",
+				Pods:               pods,
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		cpt  *rainbondv1alpha1.RbdComponent
+		want bool
+	}{
+		{
+			name: "upgraded",
+			cpt:  newComponent(2, 2, 2, pod("a", "rbd-api:V5.3", "sidecar:v1"), pod("b", "rbd-api:V5.3")),
+			want: true,
+		},
+		{
+			name: "status of an old generation",
+			cpt:  newComponent(3, 2, 2, pod("a", "rbd-api:V5.3"), pod("b", "rbd-api:V5.3")),
+		},
+		{
+			name: "replicas not updated",
+			cpt:  newComponent(2, 2, 1, pod("a", "rbd-api:V5.3"), pod("b", "rbd-api:V5.3")),
+		},
+		{
+			name: "a pod with the old image",
+			cpt:  newComponent(2, 2, 2, pod("a", "rbd-api:V5.3"), pod("b", "rbd-api:V5.2")),
+		},
+		{
+			name: "no pods",
+			cpt:  newComponent(2, 2, 2),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := upgradedReady(tc.cpt, "rbd-api:V5.3"); got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	pkg := &rainbondv1alpha1.RainbondPackage{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rainbondpackage"},
+		Status: &rainbondv1alpha1.RainbondPackageStatus{
+			Version:    "V5.3",
+			Conditions: []rainbondv1alpha1.PackageCondition{{Type: rainbondv1alpha1.Ready, Status: rainbondv1alpha1.Completed}},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		started        time.Duration
+		installMode    rainbondv1alpha1.InstallMode
+		packageVersion string
+		want           rainbondv1alpha1.UpgradePhase
+	}{
+		{name: "staged", installMode: rainbondv1alpha1.InstallationModeWithoutPackage, want: rainbondv1alpha1.UpgradeCompleted},
+		{name: "package of the new version", installMode: rainbondv1alpha1.InstallationModeWithPackage, packageVersion: "V5.3", want: rainbondv1alpha1.UpgradeCompleted},
+		{name: "package of the current version", installMode: rainbondv1alpha1.InstallationModeWithPackage, packageVersion: "V5.2-dev", want: rainbondv1alpha1.UpgradeStaging},
+		{name: "timed out", started: UpgradeStagingTimeout, installMode: rainbondv1alpha1.InstallationModeWithPackage, want: rainbondv1alpha1.UpgradeFailed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &rainbondv1alpha1.RainbondCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rainbondcluster"},
+				Spec: rainbondv1alpha1.RainbondClusterSpec{
+					InstallMode:          tc.installMode,
+					InstallPackageConfig: rainbondv1alpha1.InstallPackageConfig{Version: tc.packageVersion},
+				},
+			}
+			r := &ReconcileRainbondCluster{client: fake.NewFakeClientWithScheme(scheme, pkg), scheme: scheme, recorder: record.NewFakeRecorder(10)}
+			upgrade := &rainbondv1alpha1.UpgradeStatus{
+				FromVersion: "V5.2-dev",
+				ToVersion:   "V5.3",
+				Phase:       rainbondv1alpha1.UpgradeStaging,
+				StartTime:   metav1.NewTime(time.Now().Add(-tc.started)),
+			}
+
+			if _, err := r.stage(context.Background(), cluster, upgrade); err != nil {
+				t.Fatal(err)
+			}
+			if upgrade.Phase != tc.want {
+				t.Errorf("Expected %s, but got %s", tc.want, upgrade.Phase)
+			}
+		})
+	}
+}
+
+func TestRunningVersion(t *testing.T) {
+	newComponent := func(image, version string) *rainbondv1alpha1.RbdComponent {
+		return &rainbondv1alpha1.RbdComponent{Spec: rainbondv1alpha1.RbdComponentSpec{Image: image, Version: version}}
+	}
+	tests := []struct {
+		name string
+		cpts []*rainbondv1alpha1.RbdComponent
+		want string
+	}{
+		{name: "no components", want: "V5.3"},
+		{
+			name: "versions",
+			cpts: []*rainbondv1alpha1.RbdComponent{
+				newComponent("goodrain.me/rbd-api:V5.2-dev", "V5.2-dev"),
+				newComponent("goodrain.me/rbd-worker:V5.2-dev", "V5.2-dev"),
+				newComponent("goodrain.me/rbd-db:v5.1.9", ""),
+			},
+			want: "V5.2-dev",
+		},
+		{
+			name: "tags of images",
+			cpts: []*rainbondv1alpha1.RbdComponent{
+				newComponent("goodrain.me/rbd-api:V5.2-dev", ""),
+				newComponent("goodrain.me/rbd-worker:V5.2-dev", ""),
+				newComponent("goodrain.me/rbd-db:v5.1.9", ""),
+			},
+			want: "V5.2-dev",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runningVersion(tc.cpts, "V5.3"); got != tc.want {
+				t.Errorf("Expected %s, but got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package rainbondpackage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+var (
+	phaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rainbond_operator_package_phase_duration_seconds",
+		Help: "Time spent in a phase of the RainbondPackage, DownloadPackage, UnpackPackage or PushImage. Updated while the phase is running.",
+	}, []string{"namespace", "name", "phase"})
+	downloadedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rainbond_operator_package_downloaded_bytes",
+		Help: "Bytes of the RainbondPackage downloaded.",
+	}, []string{"namespace", "name"})
+	phaseImages = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rainbond_operator_package_images",
+		Help: "Number of images unpacked from the RainbondPackage, or pushed to the image hub.",
+	}, []string{"namespace", "name", "phase"})
+	imagePushFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rainbond_operator_image_push_failures_total",
+		Help: "Number of failed pushes of an image to the image hub.",
+	}, []string{"namespace", "name", "image"})
+)
+
+func init() {
+	// Served with the other controller-runtime metrics on the metrics port of the manager.
+	metrics.Registry.MustRegister(phaseDuration, downloadedBytes, phaseImages, imagePushFailures)
+}
+
+// startPhase marks the beginning of a phase of the package.
+func (p *pkg) startPhase(phase rainbondv1alpha1.PackageConditionType) {
+	p.phaseStarted = time.Now()
+	phaseDuration.WithLabelValues(p.pkg.Namespace, p.pkg.Name, string(phase)).Set(0)
+}
+
+// observePhase records how long the running phase of the package has taken so far.
+func (p *pkg) observePhase(phase rainbondv1alpha1.PackageConditionType) {
+	phaseDuration.WithLabelValues(p.pkg.Namespace, p.pkg.Name, string(phase)).Set(time.Since(p.phaseStarted).Seconds())
+}
+
+// observeDownloaded records the bytes of the package downloaded.
+func (p *pkg) observeDownloaded(bytes int64) {
+	downloadedBytes.WithLabelValues(p.pkg.Namespace, p.pkg.Name).Set(float64(bytes))
+}
+
+// observeImagesUnpacked records the progress of the UnpackPackage phase.
+func (p *pkg) observeImagesUnpacked(num int32) {
+	phaseImages.WithLabelValues(p.pkg.Namespace, p.pkg.Name, string(rainbondv1alpha1.UnpackPackage)).Set(float64(num))
+}
+
+// observeImagesPushed records the progress of the PushImage phase.
+func (p *pkg) observeImagesPushed() {
+	p.observePhase(rainbondv1alpha1.PushImage)
+	phaseImages.WithLabelValues(p.pkg.Namespace, p.pkg.Name, string(rainbondv1alpha1.PushImage)).Set(float64(len(p.pkg.Status.ImagesPushed)))
+}
+
+// pushFailedImages are the images of the packages whose pushes have failed, to drop their metrics with the package.
+var pushFailedImages = struct {
+	sync.Mutex
+	m map[types.NamespacedName]map[string]bool
+}{m: make(map[types.NamespacedName]map[string]bool)}
+
+// observePushFailure records a failed push of the image.
+func (p *pkg) observePushFailure(image string) {
+	key := types.NamespacedName{Namespace: p.pkg.Namespace, Name: p.pkg.Name}
+	pushFailedImages.Lock()
+	defer pushFailedImages.Unlock()
+	if pushFailedImages.m[key] == nil {
+		pushFailedImages.m[key] = make(map[string]bool)
+	}
+	pushFailedImages.m[key][image] = true
+	imagePushFailures.WithLabelValues(key.Namespace, key.Name, image).Inc()
+}
+
+// forgetPackage drops the metrics of a deleted package.
+func forgetPackage(pkg types.NamespacedName) {
+	for _, phase := range []rainbondv1alpha1.PackageConditionType{rainbondv1alpha1.DownloadPackage, rainbondv1alpha1.UnpackPackage, rainbondv1alpha1.PushImage} {
+		phaseDuration.DeleteLabelValues(pkg.Namespace, pkg.Name, string(phase))
+		phaseImages.DeleteLabelValues(pkg.Namespace, pkg.Name, string(phase))
+	}
+	downloadedBytes.DeleteLabelValues(pkg.Namespace, pkg.Name)
+
+	pushFailedImages.Lock()
+	defer pushFailedImages.Unlock()
+	for image := range pushFailedImages.m[pkg] {
+		imagePushFailures.DeleteLabelValues(pkg.Namespace, pkg.Name, image)
+	}
+	delete(pushFailedImages.m, pkg)
+}
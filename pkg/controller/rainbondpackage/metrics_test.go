@@ -0,0 +1,51 @@
+package rainbondpackage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestPackageMetrics(t *testing.T) {
+	newPkg := func(namespace string) *pkg {
+		return &pkg{
+			pkg: &rainbondv1alpha1.RainbondPackage{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "rainbondpackage"},
+				Status:     &rainbondv1alpha1.RainbondPackageStatus{ImagesPushed: []rainbondv1alpha1.RainbondPackageImage{{Name: "rbd-api"}}},
+			},
+		}
+	}
+	p, other := newPkg("rbd-system"), newPkg("rbd-other")
+	key := types.NamespacedName{Namespace: p.pkg.Namespace, Name: p.pkg.Name}
+	defer forgetPackage(types.NamespacedName{Namespace: other.pkg.Namespace, Name: other.pkg.Name})
+
+	p.startPhase(rainbondv1alpha1.PushImage)
+	p.phaseStarted = time.Now().Add(-time.Second)
+	p.observeImagesPushed()
+	p.observeDownloaded(1024)
+	p.observePushFailure("goodrain.me/rbd-api:V5.3")
+	other.observeDownloaded(2048)
+
+	if got := testutil.ToFloat64(phaseImages.WithLabelValues(key.Namespace, key.Name, string(rainbondv1alpha1.PushImage))); got != 1 {
+		t.Errorf("Expected 1, but got %v", got)
+	}
+	if got := testutil.ToFloat64(downloadedBytes.WithLabelValues(key.Namespace, key.Name)); got != 1024 {
+		t.Errorf("Expected 1024, but got %v", got)
+	}
+
+	forgetPackage(key)
+	if phaseDuration.DeleteLabelValues(key.Namespace, key.Name, string(rainbondv1alpha1.PushImage)) {
+		t.Errorf("Expected the phase duration to be dropped")
+	}
+	if imagePushFailures.DeleteLabelValues(key.Namespace, key.Name, "goodrain.me/rbd-api:V5.3") {
+		t.Errorf("Expected the image push failures to be dropped")
+	}
+	if got := testutil.ToFloat64(downloadedBytes.WithLabelValues(other.pkg.Namespace, other.pkg.Name)); got != 2048 {
+		t.Errorf("Expected 2048, but got %v", got)
+	}
+}
@@ -24,16 +24,20 @@ import (
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	"github.com/goodrain/rainbond-operator/pkg/util/constants"
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
 	"github.com/goodrain/rainbond-operator/pkg/util/retryutil"
 
 	"github.com/docker/distribution/reference"
 	dtype "github.com/docker/docker/api/types"
 	dtypes "github.com/docker/docker/api/types"
 	dclient "github.com/docker/docker/client"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -56,7 +60,11 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileRainbondPackage{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileRainbondPackage{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor("rainbondpackage-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -68,12 +76,26 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	}
 
 	// Watch for changes to primary resource RainbondPackage
-	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondPackage{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "rainbondpackage",
-			Namespace: "rbd-system",
-		},
-	}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondPackage{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// The package is staged again when its rainbondcluster starts an upgrade.
+	cli := mgr.GetClient()
+	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondCluster{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			cluster, ok := obj.Object.(*rainbondv1alpha1.RainbondCluster)
+			if !ok {
+				return nil
+			}
+			pkg, err := rbdutil.GetRainbondPackage(context.Background(), cli, cluster)
+			if err != nil {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: pkg.Namespace, Name: pkg.Name}}}
+		}),
+	})
 	if err != nil {
 		return err
 	}
@@ -88,8 +110,9 @@ var _ reconcile.Reconciler = &ReconcileRainbondPackage{}
 type ReconcileRainbondPackage struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a RainbondPackage object and makes changes based on the state read
@@ -112,11 +135,23 @@ func (r *ReconcileRainbondPackage) Reconcile(request reconcile.Request) (reconci
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			forgetPackage(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
+	if version := r.stagingVersion(ctx, pkg); version != "" {
+		reqLogger.Info("stage rainbondpackage for upgrade", "Version", version)
+		pkg.Status = initPackageStatus()
+		pkg.Status.Version = version
+		if err := updateCRStatus(r.client, pkg); err != nil {
+			reqLogger.Error(err, "update package status failure ")
+			return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		r.recorder.Eventf(pkg, corev1.EventTypeNormal, "Staging", "Staging the images of version %s", version)
+		return reconcile.Result{}, nil
+	}
 	updateStatus, re := checkStatusCanReturn(pkg)
 	if updateStatus {
 		if err := updateCRStatus(r.client, pkg); err != nil {
@@ -129,8 +164,9 @@ func (r *ReconcileRainbondPackage) Reconcile(request reconcile.Request) (reconci
 		return *re, nil
 	}
 	//need handle condition
-	p, err := newpkg(ctx, r.client, pkg, reqLogger)
+	p, err := newpkg(ctx, r.client, r.recorder, pkg, reqLogger)
 	if err != nil {
+		r.recorder.Eventf(pkg, corev1.EventTypeWarning, "InitFailed", "Failed to create package handle: %v", err)
 		p.updateConditionStatus(rainbondv1alpha1.Init, rainbondv1alpha1.Failed)
 		p.updateConditionResion(rainbondv1alpha1.Init, err.Error(), "create package handle failure")
 		p.updateCRStatus()
@@ -141,8 +177,10 @@ func (r *ReconcileRainbondPackage) Reconcile(request reconcile.Request) (reconci
 	if err = p.handle(); err != nil {
 		if err == errorClusterConfigNoLocalHub {
 			reqLogger.Info("waiting local image hub ready")
+			r.recorder.Event(pkg, corev1.EventTypeNormal, "WaitingForImageHub", "Waiting for the local image hub to be ready")
 		} else if err == errorClusterConfigNotReady {
 			reqLogger.Info("waiting cluster config ready")
+			r.recorder.Event(pkg, corev1.EventTypeNormal, "WaitingForClusterConfig", "Waiting for the rainbondcluster to be configured")
 		} else {
 			reqLogger.Error(err, "failed to handle rainbond package.")
 		}
@@ -214,9 +252,38 @@ func checkStatusCanReturn(pkg *rainbondv1alpha1.RainbondPackage) (updateStatus b
 	return false, nil
 }
 
+// stagingVersion returns the version that the rainbondcluster of the package is upgrading to,
+// if the package has not handled the images of it yet. The package is left alone while it is running,
+// and in the WithPackage mode until the package of the version is configured.
+func (r *ReconcileRainbondPackage) stagingVersion(ctx context.Context, pkg *rainbondv1alpha1.RainbondPackage) string {
+	if pkg.Status == nil {
+		return ""
+	}
+	cluster := &rainbondv1alpha1.RainbondCluster{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: pkg.Namespace, Name: pkg.RainbondClusterName()}, cluster); err != nil {
+		return ""
+	}
+	if cluster.Status == nil || cluster.Status.Upgrade == nil || cluster.Status.Upgrade.Phase != rainbondv1alpha1.UpgradeStaging {
+		return ""
+	}
+	if pkg.Status.Version == cluster.Status.Upgrade.ToVersion {
+		return ""
+	}
+	if cluster.Spec.InstallMode == rainbondv1alpha1.InstallationModeWithPackage && cluster.Spec.InstallPackageConfig.Version != cluster.Status.Upgrade.ToVersion {
+		return ""
+	}
+	for _, cond := range pkg.Status.Conditions {
+		if cond.Status == rainbondv1alpha1.Running {
+			return ""
+		}
+	}
+	return cluster.Status.Upgrade.ToVersion
+}
+
 type pkg struct {
 	ctx                 context.Context
 	client              client.Client
+	recorder            record.EventRecorder
 	dcli                *dclient.Client
 	pkg                 *rainbondv1alpha1.RainbondPackage
 	cluster             *rainbondv1alpha1.RainbondCluster
@@ -231,9 +298,11 @@ type pkg struct {
 	//need download images
 	images  map[string]string
 	version string
+	// phaseStarted is when the running phase of the package started.
+	phaseStarted time.Time
 }
 
-func newpkg(ctx context.Context, client client.Client, p *rainbondv1alpha1.RainbondPackage, reqLogger logr.Logger) (*pkg, error) {
+func newpkg(ctx context.Context, client client.Client, recorder record.EventRecorder, p *rainbondv1alpha1.RainbondPackage, reqLogger logr.Logger) (*pkg, error) {
 	dcli, err := newDockerClient(ctx)
 	if err != nil {
 		reqLogger.Error(err, "failed to create docker client")
@@ -242,12 +311,13 @@ func newpkg(ctx context.Context, client client.Client, p *rainbondv1alpha1.Rainb
 	pkg := &pkg{
 		ctx:           ctx,
 		client:        client,
+		recorder:      recorder,
 		pkg:           p.DeepCopy(),
 		dcli:          dcli,
 		totalImageNum: 23,
 		images:        make(map[string]string, 23),
 		log:           reqLogger,
-		version:       "V5.2-dev",
+		version:       constants.DefInstallVersion,
 	}
 	return pkg, nil
 }
@@ -259,8 +329,9 @@ func (p *pkg) setCluster(c *rainbondv1alpha1.RainbondCluster) error {
 	if c.Spec.ImageHub == nil || c.Spec.ImageHub.Domain == "" {
 		return errorClusterConfigNoLocalHub
 	}
-	if c.Spec.InstallVersion != "" {
-		p.version = c.Spec.InstallVersion
+	p.version = rbdutil.GetInstallVersion(c)
+	if p.pkg.Status != nil {
+		p.pkg.Status.Version = p.version
 	}
 	p.localPackagePath = p.pkg.Spec.PkgPath
 	p.downloadPackageURL = c.Spec.InstallPackageConfig.URL
@@ -319,7 +390,7 @@ func (p *pkg) checkClusterConfig() error {
 	cluster := &rainbondv1alpha1.RainbondCluster{}
 	ctx, cancel := context.WithTimeout(p.ctx, time.Second*5)
 	defer cancel()
-	if err := p.client.Get(ctx, types.NamespacedName{Namespace: p.pkg.Namespace, Name: "rainbondcluster"}, cluster); err != nil {
+	if err := p.client.Get(ctx, types.NamespacedName{Namespace: p.pkg.Namespace, Name: p.pkg.RainbondClusterName()}, cluster); err != nil {
 		p.log.Error(err, "failed to get rainbondcluster.")
 		return err
 	}
@@ -507,6 +578,9 @@ func (p *pkg) donwnloadPackage() error {
 		_ = file.Close()
 		if err == nil {
 			p.log.Info("rainbond package file is exists")
+			if info, err := os.Stat(p.localPackagePath); err == nil {
+				p.observeDownloaded(info.Size())
+			}
 			return nil
 		}
 	}
@@ -517,6 +591,8 @@ func (p *pkg) donwnloadPackage() error {
 		for {
 			select {
 			case <-ticker.C:
+				p.observePhase(rainbondv1alpha1.DownloadPackage)
+				p.observeDownloaded(downloadListener.CurrentBytes)
 				progress := downloadListener.Percent
 				//Make time for later in the download process
 				realProgress := int32(progress) - int32(float64(progress)*0.05)
@@ -543,6 +619,7 @@ func (p *pkg) donwnloadPackage() error {
 	}
 	//stop watch progress
 	stop <- struct{}{}
+	p.observeDownloaded(downloadListener.CurrentBytes)
 	p.log.Info(fmt.Sprintf("success download package from %s", p.downloadPackageURL))
 	return nil
 }
@@ -559,6 +636,7 @@ func (p *pkg) handle() error {
 		}
 		p.updateConditionStatus(rainbondv1alpha1.Init, rainbondv1alpha1.Waiting)
 		p.updateConditionResion(rainbondv1alpha1.Init, err.Error(), "get rainbond cluster config failure")
+		p.recorder.Eventf(p.pkg, corev1.EventTypeWarning, "ClusterConfigFailed", "Failed to get rainbondcluster config: %v", err)
 		p.updateCRStatus()
 		return err
 	}
@@ -572,43 +650,60 @@ func (p *pkg) handle() error {
 	}
 	if p.canDownload() {
 		p.updateConditionStatus(rainbondv1alpha1.DownloadPackage, rainbondv1alpha1.Running)
+		p.startPhase(rainbondv1alpha1.DownloadPackage)
 		p.updateCRStatus()
+		p.recorder.Eventf(p.pkg, corev1.EventTypeNormal, "Downloading", "Downloading package from %s", p.downloadPackageURL)
 		//download pkg
-		if err := p.donwnloadPackage(); err != nil {
+		err := p.donwnloadPackage()
+		p.observePhase(rainbondv1alpha1.DownloadPackage)
+		if err != nil {
 			p.log.Error(err, "download package")
 			p.updateConditionStatus(rainbondv1alpha1.DownloadPackage, rainbondv1alpha1.Failed)
 			p.updateConditionResion(rainbondv1alpha1.DownloadPackage, err.Error(), "download package failure")
+			p.recorder.Eventf(p.pkg, corev1.EventTypeWarning, "DownloadFailed", "Failed to download package: %v", err)
 			p.updateCRStatus()
 			return fmt.Errorf("failed to download package %s", err.Error())
 		}
 		p.log.Info("handle downlaod package success")
 		p.updateConditionStatus(rainbondv1alpha1.DownloadPackage, rainbondv1alpha1.Completed)
+		p.recorder.Event(p.pkg, corev1.EventTypeNormal, "Downloaded", "Package downloaded")
 		return p.updateCRStatus()
 	}
 
 	if p.canUnpack() {
 		p.updateConditionStatus(rainbondv1alpha1.UnpackPackage, rainbondv1alpha1.Running)
+		p.startPhase(rainbondv1alpha1.UnpackPackage)
 		p.updateCRStatus()
+		p.recorder.Eventf(p.pkg, corev1.EventTypeNormal, "Unpacking", "Unpacking package %s", p.pkg.Spec.PkgPath)
 		//unstar the installation package
-		if err := p.untartar(); err != nil {
+		err := p.untartar()
+		p.observePhase(rainbondv1alpha1.UnpackPackage)
+		p.observeImagesUnpacked(countImages(pkgDst))
+		if err != nil {
 			p.updateConditionStatus(rainbondv1alpha1.UnpackPackage, rainbondv1alpha1.Failed)
 			p.updateConditionResion(rainbondv1alpha1.UnpackPackage, err.Error(), "unpack package failure")
+			p.recorder.Eventf(p.pkg, corev1.EventTypeWarning, "UnpackFailed", "Failed to unpack package %s: %v", p.pkg.Spec.PkgPath, err)
 			p.updateCRStatus()
 			return fmt.Errorf("failed to untar %s: %v", p.pkg.Spec.PkgPath, err)
 		}
 		p.log.Info("handle package unpack success")
 		p.updateConditionStatus(rainbondv1alpha1.UnpackPackage, rainbondv1alpha1.Completed)
+		p.recorder.Event(p.pkg, corev1.EventTypeNormal, "Unpacked", "Package unpacked")
 		return p.updateCRStatus()
 	}
 
 	if p.canPushImage() {
 		p.updateConditionStatus(rainbondv1alpha1.PushImage, rainbondv1alpha1.Running)
+		p.startPhase(rainbondv1alpha1.PushImage)
 		p.updateCRStatus()
+		p.recorder.Eventf(p.pkg, corev1.EventTypeNormal, "PushingImages", "Pushing images to %s", p.pushImageDomain)
 		if p.downloadPackage {
 			p.log.Info("start load and push images")
 			if err := p.imagesLoadAndPush(); err != nil {
 				p.updateConditionStatus(rainbondv1alpha1.PushImage, rainbondv1alpha1.Failed)
 				p.updateConditionResion(rainbondv1alpha1.PushImage, err.Error(), "load and push images failure")
+				p.observePhase(rainbondv1alpha1.PushImage)
+				p.recorder.Eventf(p.pkg, corev1.EventTypeWarning, "PushImagesFailed", "Failed to load and push images: %v", err)
 				p.updateCRStatus()
 				return fmt.Errorf("failed to load and push images: %v", err)
 			}
@@ -617,17 +712,22 @@ func (p *pkg) handle() error {
 			if err := p.imagePullAndPush(); err != nil {
 				p.updateConditionStatus(rainbondv1alpha1.PushImage, rainbondv1alpha1.Failed)
 				p.updateConditionResion(rainbondv1alpha1.PushImage, err.Error(), "pull and push images failure")
+				p.observePhase(rainbondv1alpha1.PushImage)
+				p.recorder.Eventf(p.pkg, corev1.EventTypeWarning, "PushImagesFailed", "Failed to pull and push images: %v", err)
 				p.updateCRStatus()
 				return fmt.Errorf("failed to pull and push images: %v", err)
 			}
 		}
+		p.observePhase(rainbondv1alpha1.PushImage)
 		p.log.Info("handle images success")
 		p.updateConditionStatus(rainbondv1alpha1.PushImage, rainbondv1alpha1.Completed)
+		p.recorder.Event(p.pkg, corev1.EventTypeNormal, "ImagesPushed", "Images pushed")
 		return p.updateCRStatus()
 	}
 
 	if p.canReady() {
 		p.updateConditionStatus(rainbondv1alpha1.Ready, rainbondv1alpha1.Completed)
+		p.recorder.Event(p.pkg, corev1.EventTypeNormal, "Ready", "Package is ready")
 		return p.updateCRStatus()
 	}
 
@@ -651,6 +751,8 @@ func (p *pkg) untartar() error {
 			select {
 			case <-ticker.C:
 				num := countImages(pkgDst)
+				p.observePhase(rainbondv1alpha1.UnpackPackage)
+				p.observeImagesUnpacked(num)
 				progress := num * 100 / p.totalImageNum
 				if p.updateConditionProgress(rainbondv1alpha1.UnpackPackage, progress) {
 					if err := p.updateCRStatus(); err != nil {
@@ -699,6 +801,7 @@ func (p *pkg) imagePullAndPush() error {
 		}
 		count++
 		p.pkg.Status.ImagesPushed = append(p.pkg.Status.ImagesPushed, rainbondv1alpha1.RainbondPackageImage{Name: localImage})
+		p.observeImagesPushed()
 		progress := count * 100 / p.pkg.Status.ImagesNumber
 		if p.updateConditionProgress(rainbondv1alpha1.PushImage, progress) {
 			if err := p.updateCRStatus(); err != nil {
@@ -750,6 +853,7 @@ func (p *pkg) imagesLoadAndPush() error {
 			}
 			count++
 			p.pkg.Status.ImagesPushed = append(p.pkg.Status.ImagesPushed, rainbondv1alpha1.RainbondPackageImage{Name: newImage})
+			p.observeImagesPushed()
 			progress := count * 100 / p.pkg.Status.ImagesNumber
 			if p.updateConditionProgress(rainbondv1alpha1.PushImage, progress) {
 				if err := p.updateCRStatus(); err != nil {
@@ -814,7 +918,12 @@ func (p *pkg) imageLoad(file string) (string, error) {
 	return imageName, nil
 }
 
-func (p *pkg) imagePush(image string) error {
+func (p *pkg) imagePush(image string) (err error) {
+	defer func() {
+		if err != nil {
+			p.observePushFailure(image)
+		}
+	}()
 	p.log.Info("start push image", "image", image)
 	var opts dtypes.ImagePushOptions
 	authConfig := dtypes.AuthConfig{
@@ -822,6 +931,13 @@ func (p *pkg) imagePush(image string) error {
 	}
 	authConfig.Username = p.cluster.Spec.ImageHub.Username
 	authConfig.Password = p.cluster.Spec.ImageHub.Password
+	if ref := p.cluster.Spec.ImageHub.PasswordSecretRef; ref != nil {
+		password, err := k8sutil.GetSecretKey(p.ctx, p.client, p.cluster.Namespace, ref)
+		if err != nil {
+			return fmt.Errorf("get password of image hub: %v", err)
+		}
+		authConfig.Password = password
+	}
 
 	registryAuth, err := encodeAuthToBase64(authConfig)
 	if err != nil {
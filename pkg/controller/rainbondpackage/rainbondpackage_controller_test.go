@@ -11,12 +11,13 @@ import (
 
 	"github.com/docker/docker/client"
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"k8s.io/client-go/tools/record"
 )
 
 var pkgHandle *pkg
 
 func init() {
-	pkgHandle, _ = newpkg(context.Background(), nil, &rainbondv1alpha1.RainbondPackage{
+	pkgHandle, _ = newpkg(context.Background(), nil, record.NewFakeRecorder(100), &rainbondv1alpha1.RainbondPackage{
 		Spec: rainbondv1alpha1.RainbondPackageSpec{
 			PkgPath: "/tmp/rainbond.tar",
 		},
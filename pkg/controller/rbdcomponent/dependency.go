@@ -0,0 +1,92 @@
+package rbdcomponent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+// waitingDependencies returns the components in DependsOn of the RbdComponent that are not available yet.
+func (r *ReconcileRbdComponent) waitingDependencies(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent) ([]string, error) {
+	if len(cpt.Spec.DependsOn) == 0 {
+		return nil, nil
+	}
+
+	list := &rainbondv1alpha1.RbdComponentList{}
+	if err := r.client.List(ctx, list, client.InNamespace(cpt.Namespace)); err != nil {
+		return nil, fmt.Errorf("list rbdcomponents: %v", err)
+	}
+	components := make(map[string]*rainbondv1alpha1.RbdComponent)
+	for i := range list.Items {
+		if list.Items[i].RainbondClusterName() == cpt.RainbondClusterName() {
+			components[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+	if cycle := dependencyCycle(components, cpt.Name); len(cycle) > 0 {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	var waiting []string
+	for _, name := range cpt.Spec.DependsOn {
+		if dep, ok := components[name]; !ok || !isAvailable(dep) {
+			waiting = append(waiting, name)
+		}
+	}
+	return waiting, nil
+}
+
+// dependencyCycle returns the path from the component with the given name back to itself, nil if there is none.
+func dependencyCycle(components map[string]*rainbondv1alpha1.RbdComponent, name string) []string {
+	visited := make(map[string]bool)
+	var visit func(path []string) []string
+	visit = func(path []string) []string {
+		cpt, ok := components[path[len(path)-1]]
+		if !ok {
+			return nil
+		}
+		for _, dep := range cpt.Spec.DependsOn {
+			if dep == name {
+				return append(path, dep)
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if cycle := visit(append(path[:len(path):len(path)], dep)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit([]string{name})
+}
+
+func isAvailable(cpt *rainbondv1alpha1.RbdComponent) bool {
+	if cpt.DeletionTimestamp != nil || cpt.Status == nil {
+		return false
+	}
+	condition := cpt.Status.GetCondition(rainbondv1alpha1.RbdComponentAvailable)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// updateWaitingForDependency records the components that the RbdComponent is waiting for in its status.
+func (r *ReconcileRbdComponent) updateWaitingForDependency(cpt *rainbondv1alpha1.RbdComponent, waiting []string) error {
+	status := cpt.Status.DeepCopy()
+	if status == nil {
+		status = &rainbondv1alpha1.RbdComponentStatus{
+			ControllerType: rainbondv1alpha1.ControllerTypeUnknown,
+			ControllerName: cpt.Name,
+		}
+	}
+	status.Reason = rainbondv1alpha1.RbdComponentWaitingForDependency
+	status.Message = fmt.Sprintf("waiting for %s to be available", strings.Join(waiting, ", "))
+	status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentProgressing, true, status.Reason, status.Message))
+	cpt.Status = status
+	return k8sutil.UpdateCRStatus(r.client, cpt)
+}
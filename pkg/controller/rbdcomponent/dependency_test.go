@@ -0,0 +1,77 @@
+package rbdcomponent
+
+import (
+	"reflect"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestDependencyCycle(t *testing.T) {
+	newComponents := func(deps map[string][]string) map[string]*rainbondv1alpha1.RbdComponent {
+		components := make(map[string]*rainbondv1alpha1.RbdComponent)
+		for name, dependsOn := range deps {
+			cpt := &rainbondv1alpha1.RbdComponent{Spec: rainbondv1alpha1.RbdComponentSpec{DependsOn: dependsOn}}
+			cpt.Name = name
+			components[name] = cpt
+		}
+		return components
+	}
+
+	tests := []struct {
+		name string
+		deps map[string][]string
+		want []string
+	}{
+		{
+			name: "no cycle",
+			deps: map[string][]string{
+				"rbd-api":  {"rbd-db", "rbd-etcd"},
+				"rbd-db":   nil,
+				"rbd-etcd": nil,
+			},
+		},
+		{
+			name: "missing dependency",
+			deps: map[string][]string{
+				"rbd-api": {"rbd-db"},
+			},
+		},
+		{
+			name: "direct cycle",
+			deps: map[string][]string{
+				"rbd-api": {"rbd-db"},
+				"rbd-db":  {"rbd-api"},
+			},
+			want: []string{"rbd-api", "rbd-db", "rbd-api"},
+		},
+		{
+			name: "indirect cycle",
+			deps: map[string][]string{
+				"rbd-api":    {"rbd-etcd", "rbd-worker"},
+				"rbd-etcd":   nil,
+				"rbd-worker": {"rbd-db"},
+				"rbd-db":     {"rbd-api"},
+			},
+			want: []string{"rbd-api", "rbd-worker", "rbd-db", "rbd-api"},
+		},
+		{
+			name: "cycle not through the component",
+			deps: map[string][]string{
+				"rbd-api":    {"rbd-worker"},
+				"rbd-worker": {"rbd-db"},
+				"rbd-db":     {"rbd-worker"},
+			},
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			got := dependencyCycle(newComponents(tc.deps), "rbd-api")
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
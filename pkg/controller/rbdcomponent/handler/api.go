@@ -2,13 +2,15 @@ package handler
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/http"
 	"strings"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
 	"github.com/goodrain/rainbond-operator/pkg/util/constants"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +27,9 @@ var apiServerSecretName = "rbd-api-server-cert"
 var apiCASecretName = "rbd-api-ca-cert"
 var apiClientSecretName = "rbd-api-client-cert"
 
+// apiConfigKeys are the command line flags of rbd-api that can be set through RbdComponentSpec.Configs.
+var apiConfigKeys = []string{"api-addr", "websocket-addr", "enable-feature", "license-path", "prometheus-endpoint"}
+
 type api struct {
 	ctx                      context.Context
 	client                   client.Client
@@ -66,7 +71,8 @@ func (a *api) Before() error {
 
 func (a *api) Resources() []interface{} {
 	resources := a.secretForAPI()
-	resources = append(resources, a.daemonSetForAPI())
+	resources = append(resources, a.workloadForAPI())
+	resources = append(resources, pdbForComponent(a.component, APIName, a.labels, a.component.Replicas()))
 	resources = append(resources, a.createService()...)
 	resources = append(resources, a.ingressForAPI())
 	resources = append(resources, a.ingressForWebsocket())
@@ -77,7 +83,36 @@ func (a *api) After() error {
 	return nil
 }
 
-func (a *api) daemonSetForAPI() interface{} {
+// CheckHealth requests the health endpoint of rbd-api over HTTPS with the client certificate.
+func (a *api) CheckHealth() error {
+	secret, err := a.getSecret(apiClientSecretName)
+	if err != nil {
+		return fmt.Errorf("get secret %s: %v", apiClientSecretName, err)
+	}
+	cert, err := tls.X509KeyPair(secret.Data["client.pem"], secret.Data["client.key.pem"])
+	if err != nil {
+		return fmt.Errorf("load client certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(secret.Data["ca.pem"]) {
+		return fmt.Errorf("no ca certificate in secret %s", apiClientSecretName)
+	}
+	// the server certificate is issued for the service rbd-api-api
+	name := APIName + "-api"
+	cli := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   name,
+	}}}
+	defer cli.CloseIdleConnections()
+	return checkHTTP(a.ctx, cli, "https://"+serviceHost(name, a.component.Namespace)+":8443/v2/health", http.StatusOK)
+}
+
+func (a *api) ConfigKeys() []string {
+	return apiConfigKeys
+}
+
+func (a *api) workloadForAPI() interface{} {
 	volumeMounts := []corev1.VolumeMount{
 		{
 			Name:      "grdata",
@@ -98,7 +133,7 @@ func (a *api) daemonSetForAPI() interface{} {
 		"--api-addr=127.0.0.1:8888",
 		"--enable-feature=privileged",
 		fmt.Sprintf("--log-level=%s", a.component.LogLevel()),
-		a.db.RegionDataSource(),
+		"--mysql=" + regionDataSource(a.db),
 		"--etcd=" + strings.Join(etcdEndpoints(a.cluster), ","),
 	}
 	if a.etcdSecret != nil {
@@ -118,61 +153,44 @@ func (a *api) daemonSetForAPI() interface{} {
 			"--client-ca-file=/etc/goodrain/region.goodrain.me/ssl/ca.pem",
 		)
 	}
+	args = withConfigArgs(args, a.component.Spec.Configs, apiConfigKeys)
 	a.labels["name"] = APIName
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      APIName,
-			Namespace: a.component.Namespace,
-			Labels:    a.labels,
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: a.labels,
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: commonutil.Int64(0),
+		Tolerations: []corev1.Toleration{
+			{
+				Key:    a.cluster.Status.MasterRoleLabel,
+				Effect: corev1.TaintEffectNoSchedule,
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   APIName,
-					Labels: a.labels,
-				},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: commonutil.Int64(0),
-					NodeSelector:                  a.cluster.Status.FirstMasterNodeLabel(),
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    a.cluster.Status.MasterRoleLabel,
-							Effect: corev1.TaintEffectNoSchedule,
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:            APIName,
-							Image:           a.component.Spec.Image,
-							ImagePullPolicy: a.component.ImagePullPolicy(),
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
-								{
-									Name:  "EX_DOMAIN",
-									Value: a.cluster.Spec.SuffixHTTPHost,
-								},
+		},
+		Containers: []corev1.Container{
+			{
+				Name:            APIName,
+				Image:           a.component.Spec.Image,
+				ImagePullPolicy: a.component.ImagePullPolicy(),
+				Env: []corev1.EnvVar{
+					dbPasswordEnv(dbPasswordEnvName, a.db),
+					{
+						Name: "POD_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIP",
 							},
-							Args:         args,
-							VolumeMounts: volumeMounts,
 						},
 					},
-					Volumes: volumes,
+					{
+						Name:  "EX_DOMAIN",
+						Value: a.cluster.Spec.SuffixHTTPHost,
+					},
 				},
+				Args:         args,
+				VolumeMounts: volumeMounts,
 			},
 		},
+		Volumes: volumes,
 	}
 
-	return ds
+	return workloadForComponent(a.component, a.cluster, APIName, a.labels, podSpec)
 }
 
 func (a *api) createService() []interface{} {
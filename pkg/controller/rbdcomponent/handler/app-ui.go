@@ -46,7 +46,7 @@ func (a *appui) Before() error {
 	}
 	a.db = db
 
-	return isUIDBReady(a.ctx, a.client, a.cluster)
+	return nil
 }
 
 func (a *appui) Resources() []interface{} {
@@ -70,7 +70,7 @@ func (a *appui) deploymentForAppUI() interface{} {
 			Labels:    cpt.GetLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: commonutil.Int32(1),
+			Replicas: commonutil.Int32(cpt.Replicas()),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: cpt.GetLabels(),
 			},
@@ -99,10 +99,7 @@ func (a *appui) deploymentForAppUI() interface{} {
 									Name:  "MYSQL_USER",
 									Value: a.db.Username,
 								},
-								{
-									Name:  "MYSQL_PASS",
-									Value: a.db.Password,
-								},
+								dbPasswordEnv("MYSQL_PASS", a.db),
 								{
 									Name:  "MYSQL_DB",
 									Value: "console",
@@ -147,6 +144,10 @@ func (a *appui) deploymentForAppUI() interface{} {
 		},
 	}
 
+	if cpt.Replicas() > 1 {
+		deploy.Spec.Template.Spec.Affinity = antiAffinityForComponent(cpt.GetLabels(), false)
+	}
+
 	return deploy
 }
 
@@ -12,9 +12,7 @@ import (
 	"github.com/goodrain/rainbond-operator/pkg/util/constants"
 	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -60,7 +58,8 @@ func (c *chaos) Before() error {
 
 func (c *chaos) Resources() []interface{} {
 	return []interface{}{
-		c.daemonSetForChaos(),
+		c.workloadForChaos(),
+		pdbForComponent(c.component, ChaosName, c.labels, c.component.Replicas()),
 	}
 }
 
@@ -68,7 +67,7 @@ func (c *chaos) After() error {
 	return nil
 }
 
-func (c *chaos) daemonSetForChaos() interface{} {
+func (c *chaos) workloadForChaos() interface{} {
 	volumeMounts := []corev1.VolumeMount{
 		{
 			Name:      "grdata",
@@ -112,7 +111,7 @@ func (c *chaos) daemonSetForChaos() interface{} {
 	args := []string{
 		"--hostIP=$(POD_IP)",
 		fmt.Sprintf("--log-level=%s", c.component.LogLevel()),
-		c.db.RegionDataSource(),
+		"--mysql=" + regionDataSource(c.db),
 		"--etcd-endpoints=" + strings.Join(etcdEndpoints(c.cluster), ","),
 	}
 
@@ -123,69 +122,51 @@ func (c *chaos) daemonSetForChaos() interface{} {
 		args = append(args, etcdSSLArgs()...)
 	}
 
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      ChaosName,
-			Namespace: c.component.Namespace,
-			Labels:    c.labels,
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: commonutil.Int64(0),
+		Tolerations: []corev1.Toleration{
+			{
+				Key:    c.cluster.Status.MasterRoleLabel,
+				Effect: corev1.TaintEffectNoSchedule,
+			},
 		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: c.labels,
+		ServiceAccountName: "rainbond-operator",
+		HostAliases: []corev1.HostAlias{
+			{
+				IP:        c.cluster.GatewayIngressIP(),
+				Hostnames: []string{rbdutil.GetImageRepository(c.cluster)},
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   ChaosName,
-					Labels: c.labels,
-				},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: commonutil.Int64(0),
-					NodeSelector:                  c.cluster.Status.FirstMasterNodeLabel(),
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    c.cluster.Status.MasterRoleLabel,
-							Effect: corev1.TaintEffectNoSchedule,
+		},
+		Containers: []corev1.Container{
+			{
+				Name:            ChaosName,
+				Image:           c.component.Spec.Image,
+				ImagePullPolicy: c.component.ImagePullPolicy(),
+				Env: []corev1.EnvVar{
+					dbPasswordEnv(dbPasswordEnvName, c.db),
+					{
+						Name: "POD_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIP",
+							},
 						},
 					},
-					ServiceAccountName: "rainbond-operator",
-					HostAliases: []corev1.HostAlias{
-						{
-							IP:        c.cluster.GatewayIngressIP(),
-							Hostnames: []string{rbdutil.GetImageRepository(c.cluster)},
-						},
+					{
+						Name:  "SOURCE_DIR",
+						Value: "/cache/source",
 					},
-					Containers: []corev1.Container{
-						{
-							Name:            ChaosName,
-							Image:           c.component.Spec.Image,
-							ImagePullPolicy: c.component.ImagePullPolicy(),
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
-								{
-									Name:  "SOURCE_DIR",
-									Value: "/cache/source",
-								},
-								{
-									Name:  "CACHE_DIR",
-									Value: "/cache",
-								},
-							},
-							Args:         args,
-							VolumeMounts: volumeMounts,
-						},
+					{
+						Name:  "CACHE_DIR",
+						Value: "/cache",
 					},
-					Volumes: volumes,
 				},
+				Args:         args,
+				VolumeMounts: volumeMounts,
 			},
 		},
+		Volumes: volumes,
 	}
 
-	return ds
+	return workloadForComponent(c.component, c.cluster, ChaosName, c.labels, podSpec)
 }
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	"github.com/goodrain/rainbond-operator/pkg/util/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// storageComponents are the components that the other components keep their data in.
+var storageComponents = map[string]bool{
+	NFSName:  true,
+	DBName:   true,
+	EtcdName: true,
+}
+
+// IsStorageComponent checks if the component with the given name keeps the data of other components,
+// so it has to be deleted after them.
+func IsStorageComponent(name string) bool {
+	return storageComponents[name]
+}
+
+// hostPathFor returns the hostpath directory of the data of the component, e.g. /opt/rainbond/<namespace>/data/db,
+// so that the regions sharing nodes do not share, or purge, the data of each other.
+func hostPathFor(namespace, name string) string {
+	if namespace == constants.LegacyNamespace {
+		return path.Join("/opt/rainbond/data", name)
+	}
+	return path.Join("/opt/rainbond", namespace, "data", name)
+}
+
+// purgeNodesAnnotation records the nodes where the pods of a component were running,
+// because the pods are gone when the hostpath data is purged.
+const purgeNodesAnnotation = "rainbond.io/purge-nodes"
+
+// purgeHostPath wipes the hostpath data of the component on the nodes its pods were running on, if the cluster
+// asks for it. The workload of the component is deleted first, then a Job on each node removes the data.
+func purgeHostPath(ctx context.Context, cli client.Client, component *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, hostPath string) error {
+	if !cluster.Spec.PurgeData {
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := cli.List(ctx, pods, client.InNamespace(component.Namespace), client.MatchingLabels(component.GetLabels())); err != nil {
+		return fmt.Errorf("list pods: %v", err)
+	}
+	if len(pods.Items) > 0 {
+		if err := recordPurgeNodes(ctx, cli, component, pods.Items); err != nil {
+			return err
+		}
+		// the data can't be removed while it is still in use
+		if err := deleteWorkload(ctx, cli, component); err != nil {
+			return err
+		}
+		return ErrCleanupInProgress
+	}
+
+	var nodes []string
+	if value := component.Annotations[purgeNodesAnnotation]; value != "" {
+		nodes = strings.Split(value, ",")
+	}
+	var done int
+	for _, node := range nodes {
+		job := purgeJob(component, node, hostPath)
+		old := &batchv1.Job{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: job.Name}, old); err != nil {
+			if !k8sErrors.IsNotFound(err) {
+				return fmt.Errorf("get job %s: %v", job.Name, err)
+			}
+			if err := cli.Create(ctx, job); err != nil {
+				return fmt.Errorf("create job %s: %v", job.Name, err)
+			}
+			continue
+		}
+		if old.Status.Succeeded > 0 {
+			done++
+			continue
+		}
+		for _, cond := range old.Status.Conditions {
+			if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+				return fmt.Errorf("purge %s on node %s: %s", hostPath, node, cond.Message)
+			}
+		}
+	}
+	if done < len(nodes) {
+		return ErrCleanupInProgress
+	}
+	return nil
+}
+
+func recordPurgeNodes(ctx context.Context, cli client.Client, component *rainbondv1alpha1.RbdComponent, pods []corev1.Pod) error {
+	nodes := make(map[string]bool)
+	if value := component.Annotations[purgeNodesAnnotation]; value != "" {
+		for _, node := range strings.Split(value, ",") {
+			nodes[node] = true
+		}
+	}
+	changed := false
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" && !nodes[pod.Spec.NodeName] {
+			nodes[pod.Spec.NodeName] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var names []string
+	for node := range nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+	if component.Annotations == nil {
+		component.Annotations = make(map[string]string)
+	}
+	component.Annotations[purgeNodesAnnotation] = strings.Join(names, ",")
+	if err := cli.Update(ctx, component); err != nil {
+		return fmt.Errorf("record nodes of rbdcomponent: %v", err)
+	}
+	return nil
+}
+
+// deleteWorkload deletes the DaemonSet, StatefulSet or Deployment of the component.
+func deleteWorkload(ctx context.Context, cli client.Client, component *rainbondv1alpha1.RbdComponent) error {
+	objectMeta := metav1.ObjectMeta{Namespace: component.Namespace, Name: component.Name}
+	for _, obj := range []runtime.Object{
+		&appsv1.DaemonSet{ObjectMeta: objectMeta},
+		&appsv1.StatefulSet{ObjectMeta: objectMeta},
+		&appsv1.Deployment{ObjectMeta: objectMeta},
+	} {
+		if err := cli.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("delete workload %s: %v", component.Name, err)
+		}
+	}
+	return nil
+}
+
+func purgeJob(component *rainbondv1alpha1.RbdComponent, node, hostPath string) *batchv1.Job {
+	h := fnv.New32a()
+	h.Write([]byte(node))
+	name := fmt.Sprintf("%s-purge-%x", component.Name, h.Sum32())
+	labels := copyLabels(component.GetLabels())
+	labels["name"] = name
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: component.Namespace,
+			Labels:    labels,
+			// garbage collected with the rbdcomponent
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(component, rainbondv1alpha1.SchemeGroupVersion.WithKind("RbdComponent")),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: commonutil.Int32(3),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					NodeName:      node,
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Tolerations: []corev1.Toleration{
+						{Operator: corev1.TolerationOpExists},
+					},
+					Containers: []corev1.Container{
+						{
+							Name: "purge",
+							// the image is already on the node
+							Image:           component.Spec.Image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"rm", "-rf", path.Join("/purge", path.Base(hostPath))},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "purge",
+									MountPath: "/purge",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "purge",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: path.Dir(hostPath),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPurgeJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespace   string
+		wantHostDir string
+		wantCommand string
+	}{
+		{name: "legacy namespace", namespace: "rbd-system", wantHostDir: "/opt/rainbond/data", wantCommand: "/purge/db"},
+		{name: "other namespace", namespace: "rbd-staging", wantHostDir: "/opt/rainbond/rbd-staging/data", wantCommand: "/purge/db"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{ObjectMeta: metav1.ObjectMeta{Namespace: tc.namespace, Name: DBName}}
+			job := purgeJob(cpt, "node1", hostPathFor(tc.namespace, "db"))
+
+			if got := job.Spec.Template.Spec.Volumes[0].HostPath.Path; got != tc.wantHostDir {
+				t.Errorf("Expected %s, but got %s", tc.wantHostDir, got)
+			}
+			if got := job.Spec.Template.Spec.Containers[0].Command[2]; got != tc.wantCommand {
+				t.Errorf("Expected %s, but got %s", tc.wantCommand, got)
+			}
+			if got := cpt.GetLabels()["name"]; got != DBName {
+				t.Errorf("Expected the labels of the component to be left alone, but got name %s", got)
+			}
+		})
+	}
+}
@@ -2,45 +2,35 @@ package handler
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"path"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var ErrNoDBEndpoints = errors.New("no ready endpoints for DB were found")
-
 const (
 	EtcdSSLPath = "/run/ssl/etcd"
+
+	// dbPasswordEnvName is the environment variable that holds the password of the region database.
+	dbPasswordEnvName = "DB_PASSWORD"
 )
 
-func isUIDBReady(ctx context.Context, cli client.Client, cluster *rainbondv1alpha1.RainbondCluster) error {
-	if cluster.Spec.UIDatabase != nil {
-		return nil
-	}
-	eps := &corev1.EndpointsList{}
-	listOpts := []client.ListOption{
-		client.MatchingLabels(map[string]string{
-			"name":     DBName,
-			"belongTo": "RainbondOperator", // TODO: DO NOT HARD CODE
-		}),
-	}
-	if err := cli.List(ctx, eps, listOpts...); err != nil {
-		return err
-	}
-	for _, ep := range eps.Items {
-		for _, subset := range ep.Subsets {
-			if len(subset.Addresses) > 0 {
-				return nil
-			}
-		}
+// copyLabels returns a copy of labels, which can be changed without changing the labels of others.
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
 	}
-	return ErrNoDBEndpoints
+	return copied
 }
 
 func getDefaultDBInfo(ctx context.Context, cli client.Client, in *rainbondv1alpha1.Database, namespace, name string) (*rainbondv1alpha1.Database, error) {
@@ -57,16 +47,44 @@ func getDefaultDBInfo(ctx context.Context, cli client.Client, in *rainbondv1alph
 		return nil, NewIgnoreError(fmt.Sprintf("secret %s/%s not fount: %v", name, namespace, err))
 	}
 	user := string(secret.Data[mysqlUserKey])
-	pass := string(secret.Data[mysqlPasswordKey])
 
 	return &rainbondv1alpha1.Database{
 		Host:     DBName,
 		Port:     3306,
 		Username: user,
-		Password: pass,
+		PasswordSecretRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: name,
+			},
+			Key: mysqlPasswordKey,
+		},
 	}, nil
 }
 
+// dbPasswordEnv returns the environment variable with the given name that holds the password of the database.
+// The password is read from the secret referenced by the database, unless only the deprecated plaintext one is given.
+func dbPasswordEnv(name string, db *rainbondv1alpha1.Database) corev1.EnvVar {
+	if db.PasswordSecretRef != nil {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: db.PasswordSecretRef,
+			},
+		}
+	}
+	return corev1.EnvVar{
+		Name:  name,
+		Value: db.Password,
+	}
+}
+
+// regionDataSource returns the data source name of the region database.
+// The password refers to the environment variable returned by dbPasswordEnv(dbPasswordEnvName, db),
+// so that it doesn't show up in the command line of the workload.
+func regionDataSource(db *rainbondv1alpha1.Database) string {
+	return fmt.Sprintf("%s:$(%s)@tcp(%s:%d)/region", db.Username, dbPasswordEnvName, db.Host, db.Port)
+}
+
 func etcdSecret(ctx context.Context, cli client.Client, cluster *rainbondv1alpha1.RainbondCluster) (*corev1.Secret, error) {
 	if cluster.Spec.EtcdConfig == nil || cluster.Spec.EtcdConfig.SecretName == "" {
 		// SecretName is empty, not using TLS.
@@ -130,3 +148,98 @@ func etcdSSLArgs() []string {
 		"--etcd-key=" + path.Join(EtcdSSLPath, "key-file"),
 	}
 }
+
+// workloadForComponent wraps the pod spec into the controller that matches the replicas of the component.
+// Without replicas, or with exactly one, it is a DaemonSet pinned to the first master node.
+// Otherwise it is a Deployment whose pods are spread across the master nodes.
+func workloadForComponent(component *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, name string, labels map[string]string, podSpec corev1.PodSpec) interface{} {
+	objectMeta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: component.Namespace,
+		Labels:    labels,
+	}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: podSpec,
+	}
+
+	if component.Spec.Replicas == nil || *component.Spec.Replicas == 1 {
+		template.Spec.NodeSelector = cluster.Status.FirstMasterNodeLabel()
+		return &appsv1.DaemonSet{
+			ObjectMeta: objectMeta,
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: labels,
+				},
+				Template: template,
+			},
+		}
+	}
+
+	template.Spec.NodeSelector = cluster.Status.MasterNodeLabel()
+	template.Spec.Affinity = antiAffinityForComponent(labels, podSpec.HostNetwork)
+	return &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: commonutil.Int32(component.Replicas()),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: template,
+		},
+	}
+}
+
+// antiAffinityForComponent keeps the pods of a component away from each other.
+// Pods using the host network can't share a node, so the rule is required for them.
+func antiAffinityForComponent(labels map[string]string, hostNetwork bool) *corev1.Affinity {
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: labels,
+		},
+		TopologyKey: "kubernetes.io/hostname",
+	}
+	if hostNetwork {
+		return &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+			},
+		}
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight:          100,
+					PodAffinityTerm: term,
+				},
+			},
+		},
+	}
+}
+
+// pdbForComponent returns a PodDisruptionBudget that lets only one of the pods of the component be evicted at a time,
+// or nil if the component runs less than two pods.
+// minAvailable is used instead of maxUnavailable, which does not work for the pods of DaemonSets.
+func pdbForComponent(component *rainbondv1alpha1.RbdComponent, name string, labels map[string]string, pods int32) interface{} {
+	if pods < 2 {
+		return nil
+	}
+	minAvailable := intstr.FromInt(int(pods - 1))
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: component.Namespace,
+			Labels:    labels,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}
+}
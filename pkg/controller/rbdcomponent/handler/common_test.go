@@ -2,12 +2,18 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
-	"testing"
 )
 
 func TestGetDefaultInfo(t *testing.T) {
@@ -24,9 +30,9 @@ func TestGetDefaultInfo(t *testing.T) {
 			Name:      DBName,
 			Namespace: "rbd-system",
 		},
-		StringData: map[string]string{
-			mysqlPasswordKey: "foobar",
-			mysqlUserKey:     "write",
+		Data: map[string][]byte{
+			mysqlPasswordKey: []byte("foobar"),
+			mysqlUserKey:     []byte("write"),
 		},
 	}
 	clientset := fake.NewFakeClientWithScheme(scheme, secret)
@@ -37,6 +43,143 @@ func TestGetDefaultInfo(t *testing.T) {
 		t.FailNow()
 	}
 	assert.NotNil(t, dbInfo)
-	assert.Equal(t, dbInfo.Password, "foobar")
+	assert.Empty(t, dbInfo.Password)
+	assert.Equal(t, dbInfo.PasswordSecretRef.Name, DBName)
+	assert.Equal(t, dbInfo.PasswordSecretRef.Key, mysqlPasswordKey)
 	assert.Equal(t, dbInfo.Username, "write")
 }
+
+func TestWorkloadForComponent(t *testing.T) {
+	cluster := &rainbondv1alpha1.RainbondCluster{
+		Status: &rainbondv1alpha1.RainbondClusterStatus{
+			MasterRoleLabel: rainbondv1alpha1.LabelNodeRolePrefix + "master",
+			MasterNodeNames: []string{"node1", "node2"},
+		},
+	}
+	tests := []struct {
+		name     string
+		replicas *int32
+		want     string
+	}{
+		{name: "replicas not specified", want: "*v1.DaemonSet"},
+		{name: "one replica", replicas: commonutil.Int32(1), want: "*v1.DaemonSet"},
+		{name: "three replicas", replicas: commonutil.Int32(3), want: "*v1.Deployment"},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Name: APIName, Namespace: "rbd-system"},
+				Spec:       rainbondv1alpha1.RbdComponentSpec{Replicas: tc.replicas},
+			}
+			workload := workloadForComponent(cpt, cluster, APIName, cpt.GetLabels(), corev1.PodSpec{})
+			if got := fmt.Sprintf("%T", workload); got != tc.want {
+				t.Errorf("Expected %s, but got %s", tc.want, got)
+			}
+			if deploy, ok := workload.(*appsv1.Deployment); ok {
+				if *deploy.Spec.Replicas != *tc.replicas {
+					t.Errorf("Expected %d replicas, but got %d", *tc.replicas, *deploy.Spec.Replicas)
+				}
+				if deploy.Spec.Template.Spec.Affinity == nil || deploy.Spec.Template.Spec.Affinity.PodAntiAffinity == nil {
+					t.Errorf("Expected pod anti-affinity for more than one replica")
+				}
+			}
+		})
+	}
+}
+
+func TestPDBForComponent(t *testing.T) {
+	cpt := &rainbondv1alpha1.RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Name: APIName, Namespace: "rbd-system"},
+	}
+	tests := []struct {
+		name             string
+		pods             int32
+		wantMinAvailable int
+	}{
+		{name: "one pod", pods: 1},
+		{name: "two pods", pods: 2, wantMinAvailable: 1},
+		{name: "three pods", pods: 3, wantMinAvailable: 2},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			res := pdbForComponent(cpt, APIName, cpt.GetLabels(), tc.pods)
+			if tc.pods < 2 {
+				if res != nil {
+					t.Errorf("Expected no pod disruption budget, but got %v", res)
+				}
+				return
+			}
+			pdb, ok := res.(*policyv1beta1.PodDisruptionBudget)
+			if !ok {
+				t.Fatalf("Expected *v1beta1.PodDisruptionBudget, but got %T", res)
+			}
+			if got := pdb.Spec.MinAvailable.IntValue(); got != tc.wantMinAvailable {
+				t.Errorf("Expected minAvailable %d, but got %d", tc.wantMinAvailable, got)
+			}
+			if pdb.Spec.Selector == nil || pdb.Spec.Selector.MatchLabels["name"] != APIName {
+				t.Errorf("Expected selector of %s, but got %v", APIName, pdb.Spec.Selector)
+			}
+		})
+	}
+}
+
+func TestEtcdMembers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &rainbondv1alpha1.RainbondCluster{
+		Status: &rainbondv1alpha1.RainbondClusterStatus{
+			MasterRoleLabel: rainbondv1alpha1.LabelNodeRolePrefix + "master",
+			MasterNodeNames: []string{"node1", "node2", "node3"},
+		},
+	}
+	tests := []struct {
+		name         string
+		replicas     *int32
+		liveReplicas *int32
+		wantCluster  string
+		wantErr      error
+	}{
+		{name: "replicas not specified", wantCluster: "rbd-etcd=http://rbd-etcd:2380"},
+		{name: "three replicas", replicas: commonutil.Int32(3), wantCluster: "rbd-etcd-0=http://rbd-etcd-0.rbd-etcd:2380,rbd-etcd-1=http://rbd-etcd-1.rbd-etcd:2380,rbd-etcd-2=http://rbd-etcd-2.rbd-etcd:2380"},
+		{name: "unchanged", replicas: commonutil.Int32(3), liveReplicas: commonutil.Int32(3), wantCluster: "rbd-etcd-0=http://rbd-etcd-0.rbd-etcd:2380,rbd-etcd-1=http://rbd-etcd-1.rbd-etcd:2380,rbd-etcd-2=http://rbd-etcd-2.rbd-etcd:2380"},
+		{name: "scaled out", replicas: commonutil.Int32(3), liveReplicas: commonutil.Int32(1), wantCluster: "rbd-etcd-0=http://rbd-etcd-0.rbd-etcd:2380,rbd-etcd-1=http://rbd-etcd-1.rbd-etcd:2380,rbd-etcd-2=http://rbd-etcd-2.rbd-etcd:2380", wantErr: ErrEtcdMembersChanged},
+		{name: "scaled in", replicas: commonutil.Int32(3), liveReplicas: commonutil.Int32(5), wantCluster: "rbd-etcd-0=http://rbd-etcd-0.rbd-etcd:2380,rbd-etcd-1=http://rbd-etcd-1.rbd-etcd:2380,rbd-etcd-2=http://rbd-etcd-2.rbd-etcd:2380", wantErr: ErrEtcdMembersChanged},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Name: EtcdName, Namespace: "rbd-system"},
+				Spec:       rainbondv1alpha1.RbdComponentSpec{Replicas: tc.replicas},
+			}
+			var objs []runtime.Object
+			if tc.liveReplicas != nil {
+				live := cpt.DeepCopy()
+				live.Spec.Replicas = tc.liveReplicas
+				objs = append(objs, NewETCD(context.Background(), nil, live, cluster, nil).(*etcd).statefulsetForEtcd().(*appsv1.StatefulSet))
+			}
+			e := NewETCD(context.Background(), fake.NewFakeClientWithScheme(scheme, objs...), cpt, cluster, nil).(*etcd)
+			if err := e.Before(); err != tc.wantErr {
+				t.Errorf("Expected %v, but got %v", tc.wantErr, err)
+			}
+
+			sts := e.statefulsetForEtcd().(*appsv1.StatefulSet)
+			if got := initialCluster(sts.Spec.Template.Spec.Containers[0].Command); got != tc.wantCluster {
+				t.Errorf("Expected %s, but got %s", tc.wantCluster, got)
+			}
+			if *sts.Spec.Replicas != cpt.Replicas() {
+				t.Errorf("Expected %d replicas, but got %d", cpt.Replicas(), *sts.Spec.Replicas)
+			}
+			if cpt.Replicas() > 1 && (sts.Spec.Template.Spec.Affinity == nil || sts.Spec.Template.Spec.Affinity.PodAntiAffinity == nil) {
+				t.Errorf("Expected pod anti-affinity for more than one replica")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+// ValidateConfigs makes sure every key of RbdComponentSpec.Configs is supported by the handler.
+func ValidateConfigs(hdl ComponentHandler, component *rainbondv1alpha1.RbdComponent) error {
+	if len(component.Spec.Configs) == 0 {
+		return nil
+	}
+
+	supported := map[string]bool{}
+	if c, ok := hdl.(ConfigurableHandler); ok {
+		for _, key := range c.ConfigKeys() {
+			supported[key] = true
+		}
+	}
+	var unknown []string
+	for key := range component.Spec.Configs {
+		if !supported[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unsupported configs for %s: %s", component.Name, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// argsFromConfigs renders the given keys of configs into command line flags, ordered by key.
+func argsFromConfigs(configs map[string]string, keys []string) []string {
+	var args []string
+	for _, key := range keys {
+		value, ok := configs[key]
+		if !ok {
+			continue
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", key, value))
+	}
+	sort.Strings(args)
+	return args
+}
+
+// withConfigArgs appends the flags rendered from configs to args. A config replaces the flag of args with the same name,
+// so that the user can change the defaults of the handler.
+func withConfigArgs(args []string, configs map[string]string, keys []string) []string {
+	overrides := argsFromConfigs(configs, keys)
+	overridden := make(map[string]bool, len(overrides))
+	for _, arg := range overrides {
+		overridden[flagName(arg)] = true
+	}
+	var merged []string
+	for _, arg := range args {
+		if !overridden[flagName(arg)] {
+			merged = append(merged, arg)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// flagName returns the name of the command line flag arg, e.g. --api-addr of --api-addr=127.0.0.1:8888.
+func flagName(arg string) string {
+	return strings.SplitN(arg, "=", 2)[0]
+}
+
+// configHashAnnotation is the pod template annotation that holds the hash of the config files mounted into the pods.
+// Files mounted with a subPath are not updated in the running pods, changing the hash rolls them instead.
+const configHashAnnotation = "rainbond.io/config-hash"
+
+// configHash returns the hash of the rendered config files.
+func configHash(files ...string) string {
+	h := sha256.New()
+	for _, file := range files {
+		h.Write([]byte(file))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		hdl     ComponentHandler
+		configs map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no configs",
+			hdl:  &repo{},
+		},
+		{
+			name:    "supported configs",
+			hdl:     &gateway{},
+			configs: map[string]string{"worker-processes": "4", "keepalive-timeout": "30"},
+		},
+		{
+			name:    "unknown config",
+			hdl:     &gateway{},
+			configs: map[string]string{"worker-processes": "4", "foo": "bar"},
+			wantErr: true,
+		},
+		{
+			name:    "handler without configs",
+			hdl:     &repo{},
+			configs: map[string]string{"foo": "bar"},
+			wantErr: true,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Name: "foobar"},
+				Spec:       rainbondv1alpha1.RbdComponentSpec{Configs: tc.configs},
+			}
+			err := ValidateConfigs(tc.hdl, cpt)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestArgsFromConfigs(t *testing.T) {
+	configs := map[string]string{
+		"worker-processes": "4",
+		"error-log":        "/dev/stderr warn",
+		"foo":              "bar",
+	}
+	want := []string{"--error-log=/dev/stderr warn", "--worker-processes=4"}
+	if got := argsFromConfigs(configs, gatewayConfigKeys); !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+}
+
+func TestWithConfigArgs(t *testing.T) {
+	args := []string{"--api-addr=127.0.0.1:8888", "--enable-feature=privileged", "--log-level=info"}
+	tests := []struct {
+		name    string
+		configs map[string]string
+		want    []string
+	}{
+		{name: "defaults", want: args},
+		{
+			name:    "override",
+			configs: map[string]string{"api-addr": "0.0.0.0:8888", "license-path": "/opt/license"},
+			want:    []string{"--enable-feature=privileged", "--log-level=info", "--api-addr=0.0.0.0:8888", "--license-path=/opt/license"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := withConfigArgs(args, tc.configs, apiConfigKeys); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
@@ -23,6 +26,10 @@ var mysqlUser = "write"
 var mysqlUserKey = "mysql-user"
 var mysqlPasswordKey = "mysql-password"
 
+// dbMysqlConfKey is the key of RbdComponentSpec.Configs whose value is appended to mysql.cnf.
+var dbMysqlConfKey = "mysql.cnf"
+var dbConfName = "rbd-db-conf"
+
 type db struct {
 	ctx                      context.Context
 	client                   client.Client
@@ -32,6 +39,8 @@ type db struct {
 	labels                   map[string]string
 	secret                   *corev1.Secret
 	mysqlUser, mysqlPassword string
+	// primary is the pod of the primary in the highly available mode.
+	primary string
 }
 
 //NewDB new db
@@ -45,6 +54,7 @@ func NewDB(ctx context.Context, client client.Client, component *rainbondv1alpha
 		labels:        component.GetLabels(),
 		mysqlUser:     mysqlUser,
 		mysqlPassword: string(uuid.NewUUID())[0:8],
+		primary:       DBName + "-0",
 	}
 }
 
@@ -62,6 +72,27 @@ func (d *db) Before() error {
 	}
 	d.secret = secret
 
+	sts := &appsv1.StatefulSet{}
+	if err := d.client.Get(d.ctx, types.NamespacedName{Namespace: d.component.Namespace, Name: DBName}, sts); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get statefulset %s/%s: %v", DBName, d.component.Namespace, err)
+		}
+	} else if err := checkDBMode(sts, d.ha()); err != nil {
+		return err
+	}
+
+	if d.ha() {
+		// the rbd-db Service selects the primary, which changes on failover
+		svc := &corev1.Service{}
+		if err := d.client.Get(d.ctx, types.NamespacedName{Namespace: d.component.Namespace, Name: DBName}, svc); err != nil {
+			if !k8sErrors.IsNotFound(err) {
+				return fmt.Errorf("get service %s/%s: %v", DBName, d.component.Namespace, err)
+			}
+		} else if primary := svc.Spec.Selector[dbPodNameLabel]; primary != "" {
+			d.primary = primary
+		}
+	}
+
 	return nil
 }
 
@@ -70,17 +101,78 @@ func (d *db) Resources() []interface{} {
 		d.secretForDB(),
 		d.statefulsetForDB(),
 		d.serviceForDB(),
+		d.serviceForDBRead(),
 		d.serviceForExporter(),
 		d.configMapForDB(),
 		d.initdbCMForDB(),
+		pdbForComponent(d.component, DBName, d.labels, d.component.Replicas()),
 	}
 }
 
 func (d *db) After() error {
+	if d.ha() {
+		return d.reconcileReplication()
+	}
+	return nil
+}
+
+// CheckHealth runs SELECT 1 against rbd-db with the generated credentials.
+func (d *db) CheckHealth() error {
+	addr := serviceHost(DBName, d.component.Namespace)
+	conn, err := d.openDB(addr, healthCheckTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(d.ctx, healthCheckTimeout)
+	defer cancel()
+	var one int
+	if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("select 1 from %s: %v", addr, err)
+	}
 	return nil
 }
 
+// credentials returns the generated user and password of rbd-db.
+func (d *db) credentials() (string, string, error) {
+	secret, err := getSecret(d.ctx, d.client, d.component.Namespace, DBName)
+	if err != nil {
+		return "", "", fmt.Errorf("get secret %s: %v", DBName, err)
+	}
+	return string(secret.Data[mysqlUserKey]), string(secret.Data[mysqlPasswordKey]), nil
+}
+
+// openDB opens the database on the host with the generated credentials.
+func (d *db) openDB(host string, timeout time.Duration) (*sql.DB, error) {
+	user, password, err := d.credentials()
+	if err != nil {
+		return nil, err
+	}
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = host + ":3306"
+	cfg.Timeout = timeout
+	// the statements of replication can't be prepared
+	cfg.InterpolateParams = true
+	return sql.Open("mysql", cfg.FormatDSN())
+}
+
+func (d *db) Cleanup() error {
+	return purgeHostPath(d.ctx, d.client, d.component, d.cluster, hostPathFor(d.component.Namespace, "db"))
+}
+
+func (d *db) ConfigKeys() []string {
+	return []string{dbMysqlConfKey}
+}
+
+// statefulsetForDB runs a single MySQL on the first master node, or a primary and replicas spread across the master nodes
+// if the component has more than one replica. Switching an existing rbd-db to the highly available mode is not supported,
+// the replicas are only initialized along with a new primary.
 func (d *db) statefulsetForDB() interface{} {
+	replicas := d.component.Replicas()
 	sts := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      DBName,
@@ -88,7 +180,7 @@ func (d *db) statefulsetForDB() interface{} {
 			Labels:    d.labels,
 		},
 		Spec: appsv1.StatefulSetSpec{
-			Replicas: commonutil.Int32(1),
+			Replicas: commonutil.Int32(replicas),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: d.labels,
 			},
@@ -96,6 +188,9 @@ func (d *db) statefulsetForDB() interface{} {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:   DBName,
 					Labels: d.labels,
+					Annotations: map[string]string{
+						configHashAnnotation: configHash(d.mysqlConf()),
+					},
 				},
 				Spec: corev1.PodSpec{
 					NodeSelector: d.cluster.Status.FirstMasterNodeLabel(),
@@ -154,6 +249,11 @@ func (d *db) statefulsetForDB() interface{} {
 									Name:      "initdb",
 									MountPath: "/docker-entrypoint-initdb.d",
 								},
+								{
+									Name:      "conf",
+									MountPath: "/etc/mysql/conf.d/mysql.cnf",
+									SubPath:   dbMysqlConfKey,
+								},
 							},
 							LivenessProbe: &corev1.Probe{
 								Handler: corev1.Handler{
@@ -177,9 +277,31 @@ func (d *db) statefulsetForDB() interface{} {
 							Image:           "goodrain.me/mysqld-exporter",
 							ImagePullPolicy: d.component.ImagePullPolicy(),
 							Env: []corev1.EnvVar{
+								{
+									Name: "MYSQL_USER",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: DBName,
+											},
+											Key: mysqlUserKey,
+										},
+									},
+								},
+								{
+									Name: "MYSQL_PASSWORD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: DBName,
+											},
+											Key: mysqlPasswordKey,
+										},
+									},
+								},
 								{
 									Name:  "DATA_SOURCE_NAME",
-									Value: fmt.Sprintf("%s:%s@tcp(127.0.0.1:3306)/", d.mysqlUser, d.mysqlPassword),
+									Value: "$(MYSQL_USER):$(MYSQL_PASSWORD)@tcp(127.0.0.1:3306)/",
 								},
 							},
 						},
@@ -189,7 +311,7 @@ func (d *db) statefulsetForDB() interface{} {
 							Name: "rbd-db-data",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/opt/rainbond/data/db",
+									Path: hostPathFor(d.component.Namespace, "db"),
 									Type: k8sutil.HostPath(corev1.HostPathDirectoryOrCreate),
 								},
 							},
@@ -204,12 +326,31 @@ func (d *db) statefulsetForDB() interface{} {
 								},
 							},
 						},
+						{
+							Name: "conf",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: dbConfName,
+									},
+								},
+							},
+						},
 					},
 				},
 			},
 		},
 	}
 
+	if replicas > 1 {
+		spec := &sts.Spec.Template.Spec
+		spec.NodeSelector = d.cluster.Status.MasterNodeLabel()
+		// The data of the members is on the host path, so they can't share a node.
+		spec.Affinity = antiAffinityForComponent(d.labels, true)
+		spec.Containers[0].Command = []string{"sh", "-c", dbHACommand}
+		spec.Containers[0].Env = d.envForDBHA()
+	}
+
 	return sts
 }
 
@@ -231,9 +372,41 @@ func (d *db) serviceForDB() interface{} {
 			Selector: d.labels,
 		},
 	}
+	if d.ha() {
+		// only the primary takes writes
+		selector := make(map[string]string, len(d.labels)+1)
+		for k, v := range d.labels {
+			selector[k] = v
+		}
+		selector[dbPodNameLabel] = d.primary
+		mysqlSvc.Spec.Selector = selector
+	}
 	return mysqlSvc
 }
 
+// serviceForDBRead balances the reads across the primary and the replicas in the highly available mode.
+func (d *db) serviceForDBRead() interface{} {
+	if !d.ha() {
+		return nil
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DBName + "-read",
+			Namespace: d.component.Namespace,
+			Labels:    d.labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "main",
+					Port: 3306,
+				},
+			},
+			Selector: d.labels,
+		},
+	}
+}
+
 func (d *db) serviceForExporter() interface{} {
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -257,11 +430,20 @@ func (d *db) serviceForExporter() interface{} {
 func (d *db) configMapForDB() interface{} {
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "rbd-db-conf",
+			Name:      dbConfName,
 			Namespace: d.component.Namespace,
 		},
 		Data: map[string]string{
-			"mysql.cnf": `
+			dbMysqlConfKey: d.mysqlConf(),
+		},
+	}
+
+	return cm
+}
+
+// mysqlConf renders mysql.cnf, which is mounted with a subPath and only reaches the pods by rolling them.
+func (d *db) mysqlConf() string {
+	mysqlConf := `
 [client]
 # Default is Latin1, if you need UTF-8 set this (also in server section)
 default-character-set = utf8
@@ -275,14 +457,28 @@ default-character-set = utf8
 character-set-server  = utf8
 collation-server      = utf8_general_ci
 character_set_server   = utf8
-collation_server       = utf8_general_ci`,
-		},
+collation_server       = utf8_general_ci`
+	if d.ha() {
+		mysqlConf += "\n\n" + dbHAConf
 	}
-
-	return cm
+	if extra := d.component.Spec.Configs[dbMysqlConfKey]; extra != "" {
+		mysqlConf += "\n\n" + extra
+	}
+	return mysqlConf
 }
 
 func (d *db) initdbCMForDB() interface{} {
+	if d.ha() {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rbd-db-initdb",
+				Namespace: d.component.Namespace,
+			},
+			Data: map[string]string{
+				"initdb.sh": dbHAInitdb,
+			},
+		}
+	}
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "rbd-db-initdb",
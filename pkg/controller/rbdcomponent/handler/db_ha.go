@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+)
+
+// DBFailoverTimeout is how long the primary of rbd-db may stay unready in the highly available mode,
+// before the most up-to-date replica is promoted.
+var DBFailoverTimeout = time.Minute
+
+// dbPodNameLabel is set on the pods by the StatefulSet controller, the rbd-db Service selects the primary with it.
+const dbPodNameLabel = "statefulset.kubernetes.io/pod-name"
+
+// dbReplicationTimeout is how long the statements of replication may take on a member.
+const dbReplicationTimeout = 30 * time.Second
+
+// dbCatchUpTimeout is how long a replica may take to apply its relay log before it is promoted.
+const dbCatchUpTimeout = 10
+
+// dbHACommand gives every member its own server id from the ordinal of its pod.
+// $$ keeps kubernetes from expanding $(( as a variable reference.
+const dbHACommand = "exec docker-entrypoint.sh mysqld --server-id=$$((100 + ${HOSTNAME##*-})) --report-host=${HOSTNAME}"
+
+// dbHAConf enables the semi-synchronous replication with GTIDs.
+// The primary waits rpl_semi_sync_master_timeout milliseconds for a replica to acknowledge a transaction,
+// then falls back to the asynchronous replication until a replica catches up again. The transactions committed meanwhile
+// are lost if the primary fails before they are replicated; set rpl_semi_sync_master_timeout in the configs
+// of rbd-db to a larger value to trade the availability of writes for them.
+const dbHAConf = `# replication of the highly available mode
+log-bin                      = mysql-bin
+binlog_format                = ROW
+gtid_mode                    = ON
+enforce_gtid_consistency     = ON
+log_slave_updates            = ON
+relay_log                    = relay-bin
+relay_log_recovery           = ON
+master_info_repository       = TABLE
+relay_log_info_repository    = TABLE
+plugin-load                  = "rpl_semi_sync_master=semisync_master.so;rpl_semi_sync_slave=semisync_slave.so"
+rpl_semi_sync_master_enabled = ON
+rpl_semi_sync_slave_enabled  = ON
+rpl_semi_sync_master_timeout = 10000`
+
+// dbHAInitdb initializes every member the same way. It is not written to the binary log,
+// so that the replicas only receive the changes made on the primary.
+const dbHAInitdb = `mysql --protocol=socket -uroot <<EOF
+SET SQL_LOG_BIN = 0;
+CREATE DATABASE IF NOT EXISTS region;
+CREATE DATABASE IF NOT EXISTS console;
+CREATE USER IF NOT EXISTS '${RBD_DB_USER}'@'%' IDENTIFIED BY '${RBD_DB_PASSWORD}';
+GRANT ALL ON *.* TO '${RBD_DB_USER}'@'%';
+FLUSH PRIVILEGES;
+EOF
+`
+
+// ha returns whether rbd-db runs a primary and replicas.
+func (d *db) ha() bool {
+	return d.component.Replicas() > 1
+}
+
+// ErrDBModeChanged is returned if the replicas of an existing rbd-db cross between one and more than one.
+// The replicas of the highly available mode start with empty data dirs and only replicate what is written after them,
+// so an existing single instance has to be dumped and restored into a new highly available rbd-db instead.
+var ErrDBModeChanged = errors.New("rbd-db can not be switched between a single instance and the highly available mode in place")
+
+// checkDBMode returns ErrDBModeChanged if the live StatefulSet of rbd-db runs in another mode than wanted.
+// The members of the highly available mode are started with dbHACommand.
+func checkDBMode(sts *appsv1.StatefulSet, ha bool) error {
+	containers := sts.Spec.Template.Spec.Containers
+	if (len(containers) > 0 && len(containers[0].Command) > 0) != ha {
+		return ErrDBModeChanged
+	}
+	return nil
+}
+
+// envForDBHA leaves the creation of the databases and the user to dbHAInitdb.
+func (d *db) envForDBHA() []corev1.EnvVar {
+	secretEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: DBName,
+					},
+					Key:      key,
+					Optional: commonutil.Bool(true),
+				},
+			},
+		}
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  "MYSQL_ALLOW_EMPTY_PASSWORD",
+			Value: "yes",
+		},
+		{
+			// the time zone tables would be written to the binary log
+			Name:  "MYSQL_INITDB_SKIP_TZINFO",
+			Value: "yes",
+		},
+		secretEnv("RBD_DB_USER", mysqlUserKey),
+		secretEnv("RBD_DB_PASSWORD", mysqlPasswordKey),
+	}
+}
+
+// reconcileReplication makes the ready replicas follow the primary, and promotes a replica if the primary is lost.
+func (d *db) reconcileReplication() error {
+	pods := &corev1.PodList{}
+	if err := d.client.List(d.ctx, pods, client.InNamespace(d.component.Namespace), client.MatchingLabels(d.labels)); err != nil {
+		return fmt.Errorf("list pods: %v", err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	var primary *corev1.Pod
+	var replicas []*corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Name == d.primary {
+			primary = pod
+			continue
+		}
+		if isPodReady(pod) && pod.Status.PodIP != "" {
+			replicas = append(replicas, pod)
+		}
+	}
+
+	if primary == nil || !isPodReady(primary) {
+		if !primaryLost(primary, d.primary, d.component.Replicas(), time.Now()) {
+			// wait for the primary, the component is checked again by the health check
+			return nil
+		}
+		if len(replicas) == 0 {
+			log.Info("no ready replica of rbd-db to promote", "Primary", d.primary)
+			return nil
+		}
+		promoted, err := d.failover(primary, replicas)
+		if err != nil {
+			return fmt.Errorf("failover: %v", err)
+		}
+		primary = replicas[promoted]
+		replicas = append(replicas[:promoted], replicas[promoted+1:]...)
+	}
+
+	if err := d.writable(primary); err != nil {
+		return err
+	}
+	var errs []string
+	for _, replica := range replicas {
+		if err := d.follow(replica, primary); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// primaryLost returns whether the primary has to be replaced: it has been unready for longer than DBFailoverTimeout,
+// or it is gone for good because the number of replicas has been decreased.
+func primaryLost(primary *corev1.Pod, name string, replicas int32, now time.Time) bool {
+	if primary == nil {
+		ordinal, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+		return err != nil || int32(ordinal) >= replicas
+	}
+	if isPodReady(primary) {
+		return false
+	}
+	return now.Sub(unreadySince(primary)) >= DBFailoverTimeout
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// unreadySince returns when the pod became unready, or was created if it has never been ready.
+func unreadySince(pod *corev1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status != corev1.ConditionTrue && !condition.LastTransitionTime.IsZero() {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// failover promotes the replica that has executed the most transactions, and points the rbd-db Service to it.
+// It returns the index of the promoted replica.
+// The old primary is fenced before: its pod is deleted, so that the clients still connected to it are cut off
+// and it comes back as a replica. A primary that can not be reached, e.g. on a partitioned node, is not fenced.
+func (d *db) failover(primary *corev1.Pod, replicas []*corev1.Pod) (int, error) {
+	if primary != nil && primary.DeletionTimestamp == nil {
+		if err := d.client.Delete(d.ctx, primary); err != nil && !k8sErrors.IsNotFound(err) {
+			return 0, fmt.Errorf("fence primary %s: %v", primary.Name, err)
+		}
+		log.Info("Fenced primary of rbd-db", "Primary", primary.Name)
+	}
+
+	promoted := -1
+	var promotedExecuted string
+	for i, replica := range replicas {
+		executed, newer, err := d.catchUp(replica, promotedExecuted)
+		if err != nil {
+			log.Info("skip replica of rbd-db", "Replica", replica.Name, "err", err)
+			continue
+		}
+		if promoted < 0 || newer {
+			promoted, promotedExecuted = i, executed
+		}
+	}
+	if promoted < 0 {
+		return 0, fmt.Errorf("no replica can be promoted")
+	}
+
+	replica := replicas[promoted]
+	conn, err := d.openDB(replica.Status.PodIP, healthCheckTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(d.ctx, dbReplicationTimeout)
+	defer cancel()
+	if err := execAll(ctx, conn, "STOP SLAVE", "RESET SLAVE ALL", "SET GLOBAL super_read_only = 0", "SET GLOBAL read_only = 0"); err != nil {
+		return 0, fmt.Errorf("promote %s: %v", replica.Name, err)
+	}
+
+	svc := &corev1.Service{}
+	if err := d.client.Get(d.ctx, types.NamespacedName{Namespace: d.component.Namespace, Name: DBName}, svc); err != nil {
+		return 0, fmt.Errorf("get service %s: %v", DBName, err)
+	}
+	selector := make(map[string]string, len(svc.Spec.Selector)+1)
+	for k, v := range svc.Spec.Selector {
+		selector[k] = v
+	}
+	selector[dbPodNameLabel] = replica.Name
+	svc.Spec.Selector = selector
+	if err := d.client.Update(d.ctx, svc); err != nil {
+		return 0, fmt.Errorf("update service %s: %v", DBName, err)
+	}
+
+	log.Info("Promoted replica of rbd-db", "Old", d.primary, "New", replica.Name, "Executed", promotedExecuted)
+	d.primary = replica.Name
+	return promoted, nil
+}
+
+// catchUp stops the replica from receiving transactions, and waits for it to apply those it has received.
+// It returns the transactions the replica has executed, and whether they are a superset of the given ones.
+func (d *db) catchUp(replica *corev1.Pod, than string) (string, bool, error) {
+	conn, err := d.openDB(replica.Status.PodIP, healthCheckTimeout)
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(d.ctx, dbReplicationTimeout)
+	defer cancel()
+
+	if err := execAll(ctx, conn, "STOP SLAVE IO_THREAD"); err != nil {
+		return "", false, err
+	}
+	status, err := slaveStatus(ctx, conn)
+	if err != nil {
+		return "", false, err
+	}
+	if retrieved := status["Retrieved_Gtid_Set"]; retrieved != "" {
+		var timedOut int
+		if err := conn.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", retrieved, dbCatchUpTimeout).Scan(&timedOut); err != nil {
+			return "", false, err
+		}
+		if timedOut != 0 {
+			return "", false, fmt.Errorf("the relay log is not applied in %ds", dbCatchUpTimeout)
+		}
+	}
+	var executed string
+	if err := conn.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+		return "", false, err
+	}
+	var superset bool
+	if err := conn.QueryRowContext(ctx, "SELECT GTID_SUBSET(?, ?) AND NOT GTID_SUBSET(?, ?)", than, executed, executed, than).Scan(&superset); err != nil {
+		return "", false, err
+	}
+	return executed, superset, nil
+}
+
+// writable makes sure the primary takes writes, it may have been a replica.
+func (d *db) writable(primary *corev1.Pod) error {
+	conn, err := d.openDB(primary.Status.PodIP, healthCheckTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(d.ctx, dbReplicationTimeout)
+	defer cancel()
+	if err := execAll(ctx, conn, "SET GLOBAL super_read_only = 0", "SET GLOBAL read_only = 0"); err != nil {
+		return fmt.Errorf("primary %s: %v", primary.Name, err)
+	}
+	return nil
+}
+
+// follow makes the replica read-only and replicate from the primary.
+func (d *db) follow(replica, primary *corev1.Pod) error {
+	conn, err := d.openDB(replica.Status.PodIP, healthCheckTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(d.ctx, dbReplicationTimeout)
+	defer cancel()
+
+	user, password, err := d.credentials()
+	if err != nil {
+		return err
+	}
+	if err := execAll(ctx, conn, "SET GLOBAL super_read_only = 1"); err != nil {
+		return fmt.Errorf("replica %s: %v", replica.Name, err)
+	}
+	status, err := slaveStatus(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("replica %s: %v", replica.Name, err)
+	}
+	switch {
+	case status == nil || status["Master_Host"] != primary.Status.PodIP:
+		// a new replica, a former primary, or the primary has a new address
+		if err := execAll(ctx, conn, "STOP SLAVE"); err != nil {
+			return fmt.Errorf("replica %s: %v", replica.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, "CHANGE MASTER TO MASTER_HOST = ?, MASTER_PORT = 3306, MASTER_USER = ?, MASTER_PASSWORD = ?, MASTER_AUTO_POSITION = 1",
+			primary.Status.PodIP, user, password); err != nil {
+			return fmt.Errorf("replica %s: change master: %v", replica.Name, err)
+		}
+		if err := execAll(ctx, conn, "START SLAVE"); err != nil {
+			return fmt.Errorf("replica %s: %v", replica.Name, err)
+		}
+		log.Info("Replica of rbd-db follows the primary", "Replica", replica.Name, "Primary", primary.Name)
+	case status["Slave_IO_Running"] == "No" && status["Last_IO_Error"] != "":
+		return fmt.Errorf("replica %s: %s", replica.Name, status["Last_IO_Error"])
+	case status["Slave_SQL_Running"] == "No" && status["Last_SQL_Error"] != "":
+		return fmt.Errorf("replica %s: %s", replica.Name, status["Last_SQL_Error"])
+	case status["Slave_IO_Running"] == "No" || status["Slave_SQL_Running"] == "No":
+		if err := execAll(ctx, conn, "START SLAVE"); err != nil {
+			return fmt.Errorf("replica %s: %v", replica.Name, err)
+		}
+	}
+	return nil
+}
+
+func execAll(ctx context.Context, conn *sql.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %v", stmt, err)
+		}
+	}
+	return nil
+}
+
+// slaveStatus returns the columns of SHOW SLAVE STATUS, nil if the member does not replicate.
+func slaveStatus(ctx context.Context, conn *sql.DB) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	status := make(map[string]string, len(columns))
+	for i, column := range columns {
+		status[column] = values[i].String
+	}
+	return status, nil
+}
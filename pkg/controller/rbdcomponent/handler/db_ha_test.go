@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDBHighAvailability(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &rainbondv1alpha1.RainbondCluster{
+		Status: &rainbondv1alpha1.RainbondClusterStatus{
+			MasterRoleLabel: rainbondv1alpha1.LabelNodeRolePrefix + "master",
+			MasterNodeNames: []string{"node1", "node2", "node3"},
+		},
+	}
+	failedOver := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: DBName},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"name": DBName, dbPodNameLabel: DBName + "-1"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		replicas    *int32
+		objs        []runtime.Object
+		wantPrimary string
+		wantRead    bool
+	}{
+		{name: "replicas not specified"},
+		{name: "three replicas", replicas: commonutil.Int32(3), wantPrimary: DBName + "-0", wantRead: true},
+		{name: "after failover", replicas: commonutil.Int32(3), objs: []runtime.Object{failedOver}, wantPrimary: DBName + "-1", wantRead: true},
+	}
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Name: DBName, Namespace: "rbd-system", Labels: map[string]string{"name": DBName}},
+				Spec:       rainbondv1alpha1.RbdComponentSpec{Replicas: tc.replicas},
+			}
+			d := NewDB(context.Background(), fake.NewFakeClientWithScheme(scheme, tc.objs...), cpt, cluster, nil).(*db)
+			if err := d.Before(); err != nil {
+				t.Fatal(err)
+			}
+
+			svc := d.serviceForDB().(*corev1.Service)
+			if got := svc.Spec.Selector[dbPodNameLabel]; got != tc.wantPrimary {
+				t.Errorf("Expected primary %q, but got %q", tc.wantPrimary, got)
+			}
+			if got := d.serviceForDBRead() != nil; got != tc.wantRead {
+				t.Errorf("Expected read service %v, but got %v", tc.wantRead, got)
+			}
+			sts := d.statefulsetForDB().(*appsv1.StatefulSet)
+			if *sts.Spec.Replicas != cpt.Replicas() {
+				t.Errorf("Expected %d replicas, but got %d", cpt.Replicas(), *sts.Spec.Replicas)
+			}
+			if got := len(sts.Spec.Template.Spec.Containers[0].Command) > 0; got != d.ha() {
+				t.Errorf("Expected server id command %v, but got %v", d.ha(), got)
+			}
+			conf := d.configMapForDB().(*corev1.ConfigMap).Data[dbMysqlConfKey]
+			if got := strings.Contains(conf, "gtid_mode"); got != d.ha() {
+				t.Errorf("Expected replication config %v, but got %v", d.ha(), got)
+			}
+			if want, got := configHash(conf), sts.Spec.Template.Annotations[configHashAnnotation]; want != got {
+				t.Errorf("Expected config hash %s, but got %s", want, got)
+			}
+			if got := d.initdbCMForDB().(*corev1.ConfigMap).Data["initdb.sh"] != ""; got != d.ha() {
+				t.Errorf("Expected initdb script %v, but got %v", d.ha(), got)
+			}
+		})
+	}
+}
+
+func TestPrimaryLost(t *testing.T) {
+	now := time.Now()
+	pod := func(ready corev1.ConditionStatus, since time.Duration) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: DBName + "-0", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodReady, Status: ready, LastTransitionTime: metav1.NewTime(now.Add(-since))},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		primary  *corev1.Pod
+		pname    string
+		replicas int32
+		want     bool
+	}{
+		{name: "ready", primary: pod(corev1.ConditionTrue, time.Hour), pname: DBName + "-0", replicas: 3},
+		{name: "unready for a moment", primary: pod(corev1.ConditionFalse, time.Second), pname: DBName + "-0", replicas: 3},
+		{name: "unready for too long", primary: pod(corev1.ConditionFalse, 2*DBFailoverTimeout), pname: DBName + "-0", replicas: 3, want: true},
+		{name: "being recreated", pname: DBName + "-1", replicas: 3},
+		{name: "scaled down", pname: DBName + "-2", replicas: 2, want: true},
+	}
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			if got := primaryLost(tc.primary, tc.pname, tc.replicas, now); got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckDBMode(t *testing.T) {
+	sts := func(command ...string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			Spec: appsv1.StatefulSetSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: DBName, Command: command}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		live *appsv1.StatefulSet
+		ha   bool
+		want error
+	}{
+		{name: "single instance", live: sts()},
+		{name: "highly available", live: sts("/bin/sh", "-c", dbHACommand), ha: true},
+		{name: "scaled out", live: sts(), ha: true, want: ErrDBModeChanged},
+		{name: "scaled in", live: sts("/bin/sh", "-c", dbHACommand), want: ErrDBModeChanged},
+	}
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			if got := checkDBMode(tc.live, tc.ha); got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -15,6 +15,10 @@ import (
 
 var DNSName = "rbd-dns"
 
+// dnsNameserversKey is the key of RbdComponentSpec.Configs which replaces the upstream nameservers of rbd-dns.
+var dnsNameserversKey = "nameservers"
+var defaultNameservers = "202.106.0.22,1.2.4.8"
+
 type dns struct {
 	component *rainbondv1alpha1.RbdComponent
 	cluster   *rainbondv1alpha1.RainbondCluster
@@ -46,6 +50,17 @@ func (d *dns) After() error {
 	return nil
 }
 
+func (d *dns) ConfigKeys() []string {
+	return []string{dnsNameserversKey}
+}
+
+func (d *dns) nameservers() string {
+	if nameservers := d.component.Spec.Configs[dnsNameserversKey]; nameservers != "" {
+		return nameservers
+	}
+	return defaultNameservers
+}
+
 func (d *dns) daemonSetForDNS() interface{} {
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -105,7 +120,7 @@ func (d *dns) daemonSetForDNS() interface{} {
 								"--v=2",
 								"--healthz-port=8089",
 								"--dns-bind-address=$(POD_IP)",
-								"--nameservers=202.106.0.22,1.2.4.8",
+								"--nameservers=" + d.nameservers(),
 								"--recoders=goodrain.me=$(HOST_IP),*.goodrain.me=$(HOST_IP),rainbond.kubernetes.apiserver=$(HOST_IP)", // TODO: goodrain.me
 							},
 						},
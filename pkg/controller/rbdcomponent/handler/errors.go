@@ -1,5 +1,10 @@
 package handler
 
+import "errors"
+
+// ErrCleanupInProgress means the cleanup of a component has been started, but is not done yet.
+var ErrCleanupInProgress = errors.New("cleanup in progress")
+
 type IgnoreError struct {
 	msg string
 }
@@ -2,19 +2,27 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	"github.com/goodrain/rainbond-operator/pkg/util/etcdutil"
 	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var EtcdName = "rbd-etcd"
 
 type etcd struct {
+	ctx       context.Context
+	client    client.Client
 	component *rainbondv1alpha1.RbdComponent
 	cluster   *rainbondv1alpha1.RainbondCluster
 	pkg       *rainbondv1alpha1.RainbondPackage
@@ -25,6 +33,8 @@ func NewETCD(ctx context.Context, client client.Client, component *rainbondv1alp
 	labels := component.GetLabels()
 	labels["etcd_node"] = EtcdName
 	return &etcd{
+		ctx:       ctx,
+		client:    client,
 		component: component,
 		cluster:   cluster,
 		pkg:       pkg,
@@ -37,13 +47,50 @@ func (e *etcd) Before() error {
 		return NewIgnoreError(fmt.Sprintf("specified etcd configuration"))
 	}
 
+	sts := &appsv1.StatefulSet{}
+	if err := e.client.Get(e.ctx, types.NamespacedName{Namespace: e.component.Namespace, Name: EtcdName}, sts); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get statefulset %s/%s: %v", EtcdName, e.component.Namespace, err)
+		}
+	} else if err := checkEtcdMembers(sts, e.etcdCommand(e.component.Replicas())); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ErrEtcdMembersChanged is returned if the replicas of an existing rbd-etcd are changed.
+// The members are only started with --initial-cluster-state new, and the data of the old ones is kept on the host path,
+// so a changed number of members would form another cluster next to the old data.
+var ErrEtcdMembersChanged = errors.New("the replicas of an existing rbd-etcd can not be changed, add or remove its members with etcdctl instead")
+
+// checkEtcdMembers returns ErrEtcdMembersChanged if the live StatefulSet of rbd-etcd starts other members than command.
+func checkEtcdMembers(sts *appsv1.StatefulSet, command []string) error {
+	containers := sts.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return nil
+	}
+	if initialCluster(containers[0].Command) != initialCluster(command) {
+		return ErrEtcdMembersChanged
+	}
 	return nil
 }
 
+// initialCluster returns the value of --initial-cluster in the command of etcd.
+func initialCluster(command []string) string {
+	for i, arg := range command {
+		if arg == "--initial-cluster" && i+1 < len(command) {
+			return command[i+1]
+		}
+	}
+	return ""
+}
+
 func (e *etcd) Resources() []interface{} {
 	return []interface{}{
 		e.statefulsetForEtcd(),
 		e.serviceForEtcd(),
+		pdbForComponent(e.component, EtcdName, e.labels, e.component.Replicas()),
 	}
 }
 
@@ -51,7 +98,35 @@ func (e *etcd) After() error {
 	return nil
 }
 
+// CheckHealth gets the status of the etcd endpoint.
+func (e *etcd) CheckHealth() error {
+	endpoint := fmt.Sprintf("http://%s:2379", serviceHost(EtcdName, e.component.Namespace))
+	cli, err := etcdutil.NewClient([]string{endpoint})
+	if err != nil {
+		return fmt.Errorf("create etcd client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(e.ctx, healthCheckTimeout)
+	defer cancel()
+	if _, err := cli.Status(ctx, endpoint); err != nil {
+		return fmt.Errorf("status of %s: %v", endpoint, err)
+	}
+	return nil
+}
+
+func (e *etcd) Cleanup() error {
+	if e.cluster.Spec.EtcdConfig != nil {
+		return nil
+	}
+	return purgeHostPath(e.ctx, e.client, e.component, e.cluster, hostPathFor(e.component.Namespace, "etcd"))
+}
+
+// statefulsetForEtcd runs a single member named rbd-etcd on the first master node, or, with more than one replica,
+// a member per pod spread across the master nodes. The number of members only takes effect on a new etcd cluster,
+// Before rejects changing it, and so is the PodManagementPolicy, which can't be updated.
 func (e *etcd) statefulsetForEtcd() interface{} {
+	replicas := e.component.Replicas()
 	sts := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      EtcdName,
@@ -59,7 +134,7 @@ func (e *etcd) statefulsetForEtcd() interface{} {
 			Labels:    e.labels,
 		},
 		Spec: appsv1.StatefulSetSpec{
-			Replicas:    commonutil.Int32(1),
+			Replicas:    commonutil.Int32(replicas),
 			ServiceName: EtcdName,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: e.labels,
@@ -84,23 +159,7 @@ func (e *etcd) statefulsetForEtcd() interface{} {
 							Name:            EtcdName,
 							Image:           e.component.Spec.Image,
 							ImagePullPolicy: e.component.ImagePullPolicy(),
-							Command: []string{
-								"/usr/local/bin/etcd",
-								"--name",
-								EtcdName,
-								"--initial-advertise-peer-urls",
-								fmt.Sprintf("http://%s:2380", EtcdName),
-								"--listen-peer-urls",
-								"http://0.0.0.0:2380",
-								"--listen-client-urls",
-								"http://0.0.0.0:2379",
-								"--advertise-client-urls",
-								fmt.Sprintf("http://%s:2379", EtcdName),
-								"--initial-cluster",
-								fmt.Sprintf("%s=http://%s:2380", EtcdName, EtcdName),
-								"--initial-cluster-state",
-								"new",
-							},
+							Command:         e.etcdCommand(replicas),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "client",
@@ -124,7 +183,7 @@ func (e *etcd) statefulsetForEtcd() interface{} {
 							Name: "etcd-data",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/opt/rainbond/data/etcd",
+									Path: hostPathFor(e.component.Namespace, "etcd"),
 									Type: k8sutil.HostPath(corev1.HostPathDirectoryOrCreate),
 								},
 							},
@@ -134,9 +193,73 @@ func (e *etcd) statefulsetForEtcd() interface{} {
 			},
 		},
 	}
+
+	if replicas > 1 {
+		// The members have to be up together to form the cluster.
+		sts.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+		sts.Spec.Template.Spec.NodeSelector = e.cluster.Status.MasterNodeLabel()
+		// The data of the members is on the host path, so they can't share a node.
+		sts.Spec.Template.Spec.Affinity = antiAffinityForComponent(e.labels, true)
+		sts.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+			{
+				Name: "POD_NAME",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{
+						FieldPath: "metadata.name",
+					},
+				},
+			},
+		}
+	}
 	return sts
 }
 
+func (e *etcd) etcdCommand(replicas int32) []string {
+	if replicas <= 1 {
+		return []string{
+			"/usr/local/bin/etcd",
+			"--name",
+			EtcdName,
+			"--initial-advertise-peer-urls",
+			fmt.Sprintf("http://%s:2380", EtcdName),
+			"--listen-peer-urls",
+			"http://0.0.0.0:2380",
+			"--listen-client-urls",
+			"http://0.0.0.0:2379",
+			"--advertise-client-urls",
+			fmt.Sprintf("http://%s:2379", EtcdName),
+			"--initial-cluster",
+			fmt.Sprintf("%s=http://%s:2380", EtcdName, EtcdName),
+			"--initial-cluster-state",
+			"new",
+		}
+	}
+
+	// The members find each other through the headless service.
+	var members []string
+	for i := int32(0); i < replicas; i++ {
+		member := fmt.Sprintf("%s-%d", EtcdName, i)
+		members = append(members, fmt.Sprintf("%s=http://%s.%s:2380", member, member, EtcdName))
+	}
+	return []string{
+		"/usr/local/bin/etcd",
+		"--name",
+		"$(POD_NAME)",
+		"--initial-advertise-peer-urls",
+		fmt.Sprintf("http://$(POD_NAME).%s:2380", EtcdName),
+		"--listen-peer-urls",
+		"http://0.0.0.0:2380",
+		"--listen-client-urls",
+		"http://0.0.0.0:2379",
+		"--advertise-client-urls",
+		fmt.Sprintf("http://$(POD_NAME).%s:2379", EtcdName),
+		"--initial-cluster",
+		strings.Join(members, ","),
+		"--initial-cluster-state",
+		"new",
+	}
+}
+
 func (e *etcd) serviceForEtcd() interface{} {
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -146,6 +269,8 @@ func (e *etcd) serviceForEtcd() interface{} {
 		},
 		Spec: corev1.ServiceSpec{
 			ClusterIP: "None",
+			// The members of etcd resolve each other before they are ready.
+			PublishNotReadyAddresses: true,
 			Ports: []corev1.ServicePort{
 				{
 					Name: "client",
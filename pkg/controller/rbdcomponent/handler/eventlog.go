@@ -9,9 +9,7 @@ import (
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/util/constants"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -57,7 +55,8 @@ func (e *eventlog) Before() error {
 
 func (e *eventlog) Resources() []interface{} {
 	return []interface{}{
-		e.daemonSetForEventLog(),
+		e.workloadForEventLog(),
+		pdbForComponent(e.component, EventLogName, e.labels, e.component.Replicas()),
 	}
 }
 
@@ -65,13 +64,13 @@ func (e *eventlog) After() error {
 	return nil
 }
 
-func (e *eventlog) daemonSetForEventLog() interface{} {
+func (e *eventlog) workloadForEventLog() interface{} {
 	args := []string{
 		"--cluster.bind.ip=$(POD_IP)",
 		"--cluster.instance.ip=$(POD_IP)",
 		"--eventlog.bind.ip=$(POD_IP)",
 		"--websocket.bind.ip=$(POD_IP)",
-		"--db.url=" + strings.Replace(e.db.RegionDataSource(), "--mysql=", "", 1),
+		"--db.url=" + regionDataSource(e.db),
 		"--discover.etcd.addr=" + strings.Join(etcdEndpoints(e.cluster), ","),
 	}
 	volumeMounts := []corev1.VolumeMount{
@@ -97,64 +96,46 @@ func (e *eventlog) daemonSetForEventLog() interface{} {
 		args = append(args, etcdSSLArgs()...)
 	}
 
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      EventLogName,
-			Namespace: e.component.Namespace,
-			Labels:    e.labels,
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: e.labels,
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: commonutil.Int64(0),
+		HostNetwork:                   true,
+		DNSPolicy:                     corev1.DNSClusterFirstWithHostNet,
+		Tolerations: []corev1.Toleration{
+			{
+				Key:    e.cluster.Status.MasterRoleLabel,
+				Effect: corev1.TaintEffectNoSchedule,
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   EventLogName,
-					Labels: e.labels,
-				},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: commonutil.Int64(0),
-					HostNetwork:                   true,
-					DNSPolicy:                     corev1.DNSClusterFirstWithHostNet,
-					NodeSelector:                  e.cluster.Status.FirstMasterNodeLabel(),
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    e.cluster.Status.MasterRoleLabel,
-							Effect: corev1.TaintEffectNoSchedule,
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:            EventLogName,
-							Image:           e.component.Spec.Image,
-							ImagePullPolicy: e.component.ImagePullPolicy(),
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
-								{
-									Name:  "K8S_MASTER",
-									Value: "kubernetes",
-								},
-								{
-									Name:  "DOCKER_LOG_SAVE_DAY",
-									Value: "7",
-								},
+		},
+		Containers: []corev1.Container{
+			{
+				Name:            EventLogName,
+				Image:           e.component.Spec.Image,
+				ImagePullPolicy: e.component.ImagePullPolicy(),
+				Env: []corev1.EnvVar{
+					dbPasswordEnv(dbPasswordEnvName, e.db),
+					{
+						Name: "POD_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIP",
 							},
-							Args:         args,
-							VolumeMounts: volumeMounts,
 						},
 					},
-					Volumes: volumes,
+					{
+						Name:  "K8S_MASTER",
+						Value: "kubernetes",
+					},
+					{
+						Name:  "DOCKER_LOG_SAVE_DAY",
+						Value: "7",
+					},
 				},
+				Args:         args,
+				VolumeMounts: volumeMounts,
 			},
 		},
+		Volumes: volumes,
 	}
 
-	return ds
+	return workloadForComponent(e.component, e.cluster, EventLogName, e.labels, podSpec)
 }
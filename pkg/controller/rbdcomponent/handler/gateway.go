@@ -9,16 +9,32 @@ import (
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var GatewayName = "rbd-gateway"
 
+// gatewayConfigKeys are the command line flags of rbd-gateway that can be set through RbdComponentSpec.Configs.
+var gatewayConfigKeys = []string{
+	"worker-processes",
+	"worker-connections",
+	"worker-rlimit-nofile",
+	"keepalive-timeout",
+	"keepalive-requests",
+	"enable-epoll",
+	"enable-multi-accept",
+	"error-log",
+}
+
 type gateway struct {
 	ctx        context.Context
 	client     client.Client
 	etcdSecret *corev1.Secret
+	// pods is the number of nodes the live DaemonSet of rbd-gateway should run on.
+	pods int32
 
 	component *rainbondv1alpha1.RbdComponent
 	cluster   *rainbondv1alpha1.RainbondCluster
@@ -42,12 +58,24 @@ func (g *gateway) Before() error {
 	}
 	g.etcdSecret = secret
 
+	ds := &appsv1.DaemonSet{}
+	if err := g.client.Get(g.ctx, types.NamespacedName{Namespace: g.component.Namespace, Name: GatewayName}, ds); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get daemonset %s: %v", GatewayName, err)
+		}
+	} else {
+		g.pods = ds.Status.DesiredNumberScheduled
+	}
+
 	return nil
 }
 
 func (g *gateway) Resources() []interface{} {
 	return []interface{}{
 		g.daemonSetForGateway(),
+		// The budget of a DaemonSet must be absolute. It is sized by the nodes rbd-gateway is scheduled to,
+		// some master nodes may not run it and would block every eviction.
+		pdbForComponent(g.component, GatewayName, g.component.GetLabels(), g.pods),
 	}
 }
 
@@ -55,6 +83,10 @@ func (g *gateway) After() error {
 	return nil
 }
 
+func (g *gateway) ConfigKeys() []string {
+	return gatewayConfigKeys
+}
+
 func (g *gateway) daemonSetForGateway() interface{} {
 	args := []string{
 		fmt.Sprintf("--log-level=%s", g.component.LogLevel()),
@@ -70,6 +102,7 @@ func (g *gateway) daemonSetForGateway() interface{} {
 		volumes = append(volumes, volume)
 		args = append(args, etcdSSLArgs()...)
 	}
+	args = withConfigArgs(args, g.component.Spec.Configs, gatewayConfigKeys)
 
 	labels := g.component.GetLabels()
 	ds := &appsv1.DaemonSet{
@@ -7,4 +7,24 @@ type ComponentHandler interface {
 	After() error
 }
 
+// ConfigurableHandler is implemented by the ComponentHandler which renders RbdComponentSpec.Configs
+// into command line flags or configuration files.
+type ConfigurableHandler interface {
+	// ConfigKeys returns the keys of RbdComponentSpec.Configs supported by the handler.
+	ConfigKeys() []string
+}
+
+// CleanupHandler is implemented by the ComponentHandler which leaves something behind that is not garbage collected
+// with the RbdComponent, such as cluster scoped resources or hostpath data.
+type CleanupHandler interface {
+	// Cleanup is called when the RbdComponent is being deleted. It is called again until it returns nil,
+	// ErrCleanupInProgress can be returned to wait for the cleanup without reporting an error.
+	Cleanup() error
+}
 
+// HealthChecker is implemented by the ComponentHandler which can check the component at the application level,
+// such as whether the database answers queries, which ready pods do not tell.
+type HealthChecker interface {
+	// CheckHealth returns nil if the component is healthy. It is called periodically once the component is available.
+	CheckHealth() error
+}
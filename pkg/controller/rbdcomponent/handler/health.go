@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// healthCheckTimeout is how long a single health check may take.
+var healthCheckTimeout = 5 * time.Second
+
+// serviceHost returns the address of the service in the cluster.
+func serviceHost(name, namespace string) string {
+	return fmt.Sprintf("%s.%s", name, namespace)
+}
+
+// checkHTTP sends a GET request to the url, and fails if the status code is none of the expected ones.
+func checkHTTP(ctx context.Context, cli *http.Client, url string, expected ...int) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cli.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		wantErr bool
+	}{
+		{name: "ok", code: http.StatusOK},
+		{name: "unauthorized", code: http.StatusUnauthorized},
+		{name: "internal server error", code: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.code)
+			}))
+			defer server.Close()
+
+			err := checkHTTP(context.Background(), server.Client(), server.URL+"/v2/", http.StatusOK, http.StatusUnauthorized)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
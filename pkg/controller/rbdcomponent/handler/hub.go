@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"net/http"
 
 	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
 
@@ -59,6 +60,12 @@ func (h *hub) After() error {
 	return nil
 }
 
+// CheckHealth requests the /v2/ endpoint of the registry, which answers 401 if authentication is required.
+func (h *hub) CheckHealth() error {
+	url := "http://" + serviceHost(HubName, h.component.Namespace) + ":5000/v2/"
+	return checkHTTP(h.ctx, http.DefaultClient, url, http.StatusOK, http.StatusUnauthorized)
+}
+
 func (h *hub) daemonSetForHub() interface{} {
 	labels := h.component.GetLabels()
 	ds := &appsv1.DaemonSet{
@@ -52,13 +52,32 @@ func (m *metricsServer) Before() error {
 		}
 		return nil
 	}
-	createdByRainbond := apiservce.Spec.Service.Namespace == m.component.Namespace && apiservce.Spec.Service.Name == MetricsServerName
+	// the metrics API is cluster wide, the metrics-server of the first region serves it for the others
+	createdByRainbond := apiservce.Spec.Service != nil && apiservce.Spec.Service.Namespace == m.component.Namespace && apiservce.Spec.Service.Name == MetricsServerName
 	if !createdByRainbond {
 		return V1beta1MetricsExists
 	}
 	return nil
 }
 
+// Cleanup deletes the APIService created by After, the APIService is cluster scoped and is not garbage collected.
+func (m *metricsServer) Cleanup() error {
+	apiservce := &kubeaggregatorv1beta1.APIService{}
+	if err := m.client.Get(m.ctx, types.NamespacedName{Name: metricsGroupAPI}, apiservce); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get apiservice(%s/%s): %v", MetricsServerName, m.cluster.Namespace, err)
+		}
+		return nil
+	}
+	if apiservce.Spec.Service == nil || apiservce.Spec.Service.Namespace != m.component.Namespace || apiservce.Spec.Service.Name != MetricsServerName {
+		return nil
+	}
+	if err := m.client.Delete(m.ctx, apiservce); err != nil && !k8sErrors.IsNotFound(err) {
+		return fmt.Errorf("delete apiservice %s: %v", metricsGroupAPI, err)
+	}
+	return nil
+}
+
 func (m *metricsServer) Resources() []interface{} {
 	return []interface{}{
 		m.deploySetForMetricsServer(),
@@ -7,9 +7,7 @@ import (
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
 	"strings"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -47,7 +45,8 @@ func (m *mq) Before() error {
 
 func (m *mq) Resources() []interface{} {
 	return []interface{}{
-		m.daemonSetForMQ(),
+		m.workloadForMQ(),
+		pdbForComponent(m.component, MQName, m.labels, m.component.Replicas()),
 	}
 }
 
@@ -55,7 +54,7 @@ func (m *mq) After() error {
 	return nil
 }
 
-func (m *mq) daemonSetForMQ() interface{} {
+func (m *mq) workloadForMQ() interface{} {
 	args := []string{
 		"--log-level=" + string(m.component.Spec.LogLevel),
 		"--etcd-endpoints=" + strings.Join(etcdEndpoints(m.cluster), ","),
@@ -70,54 +69,35 @@ func (m *mq) daemonSetForMQ() interface{} {
 		args = append(args, etcdSSLArgs()...)
 	}
 
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      MQName,
-			Namespace: m.component.Namespace,
-			Labels:    m.labels,
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: m.labels,
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: commonutil.Int64(0),
+		Tolerations: []corev1.Toleration{
+			{
+				Key:    m.cluster.Status.MasterRoleLabel,
+				Effect: corev1.TaintEffectNoSchedule,
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   MQName,
-					Labels: m.labels,
-				},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: commonutil.Int64(0),
-					NodeSelector:                  m.cluster.Status.FirstMasterNodeLabel(),
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    m.cluster.Status.MasterRoleLabel,
-							Effect: corev1.TaintEffectNoSchedule,
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:            MQName,
-							Image:           m.component.Spec.Image,
-							ImagePullPolicy: m.component.ImagePullPolicy(),
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
+		},
+		Containers: []corev1.Container{
+			{
+				Name:            MQName,
+				Image:           m.component.Spec.Image,
+				ImagePullPolicy: m.component.ImagePullPolicy(),
+				Env: []corev1.EnvVar{
+					{
+						Name: "POD_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIP",
 							},
-							Args:         args,
-							VolumeMounts: volumeMounts,
 						},
 					},
-					Volumes: volumes,
 				},
+				Args:         args,
+				VolumeMounts: volumeMounts,
 			},
 		},
+		Volumes: volumes,
 	}
 
-	return ds
+	return workloadForComponent(m.component, m.cluster, MQName, m.labels, podSpec)
 }
@@ -2,11 +2,13 @@ package handler
 
 import (
 	"context"
+	"fmt"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
 	"github.com/goodrain/rainbond-operator/pkg/util/constants"
 	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -19,7 +21,15 @@ import (
 
 //NFSName nfs provider name
 var NFSName = constants.DefStorageClass
-var nfsProvisionerName = "rainbond.io/nfs"
+
+// nfsProvisionerName returns the name of the provisioner of rbd-nfs in the namespace,
+// which tells the storage classes of the regions apart.
+func nfsProvisionerName(namespace string) string {
+	if namespace == constants.LegacyNamespace {
+		return "rainbond.io/nfs"
+	}
+	return "rainbond.io/nfs-" + namespace
+}
 
 type nfsProvisioner struct {
 	ctx       context.Context
@@ -65,6 +75,21 @@ func (n *nfsProvisioner) After() error {
 	return nil
 }
 
+func (n *nfsProvisioner) Cleanup() error {
+	name := rbdutil.DefaultStorageClass(n.component.Namespace)
+	class := &storagev1.StorageClass{}
+	if err := n.client.Get(n.ctx, types.NamespacedName{Name: name}, class); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+	} else if class.Provisioner == nfsProvisionerName(n.component.Namespace) {
+		if err := n.client.Delete(n.ctx, class); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete storage class %s: %v", name, err)
+		}
+	}
+	return purgeHostPath(n.ctx, n.client, n.component, n.cluster, hostPathFor(n.component.Namespace, "nfs"))
+}
+
 func (n *nfsProvisioner) statefulsetForNFSProvisioner() interface{} {
 	labels := n.component.GetLabels()
 	sts := &appsv1.StatefulSet{
@@ -141,7 +166,7 @@ func (n *nfsProvisioner) statefulsetForNFSProvisioner() interface{} {
 								},
 							},
 							Args: []string{
-								"-provisioner=" + nfsProvisionerName,
+								"-provisioner=" + nfsProvisionerName(n.component.Namespace),
 							},
 							SecurityContext: &corev1.SecurityContext{
 								Capabilities: &corev1.Capabilities{
@@ -164,7 +189,7 @@ func (n *nfsProvisioner) statefulsetForNFSProvisioner() interface{} {
 							Name: "export-volume",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/opt/rainbond/data/nfs",
+									Path: hostPathFor(n.component.Namespace, "nfs"),
 									Type: k8sutil.HostPath(corev1.HostPathDirectoryOrCreate),
 								},
 							},
@@ -217,10 +242,10 @@ func (n *nfsProvisioner) serviceForNFSProvisioner() interface{} {
 func (n *nfsProvisioner) storageClassForNFSProvisioner() *storagev1.StorageClass {
 	sc := &storagev1.StorageClass{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   NFSName,
+			Name:   rbdutil.DefaultStorageClass(n.component.Namespace),
 			Labels: n.component.GetLabels(),
 		},
-		Provisioner: nfsProvisionerName,
+		Provisioner: nfsProvisionerName(n.component.Namespace),
 		MountOptions: []string{
 			"vers=4.1",
 		},
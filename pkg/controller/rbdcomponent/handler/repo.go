@@ -16,6 +16,8 @@ import (
 var RepoName = "rbd-repo"
 
 type repo struct {
+	ctx       context.Context
+	client    client.Client
 	component *rainbondv1alpha1.RbdComponent
 	cluster   *rainbondv1alpha1.RainbondCluster
 	pkg       *rainbondv1alpha1.RainbondPackage
@@ -24,6 +26,8 @@ type repo struct {
 
 func NewRepo(ctx context.Context, client client.Client, component *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, pkg *rainbondv1alpha1.RainbondPackage) ComponentHandler {
 	return &repo{
+		ctx:       ctx,
+		client:    client,
 		component: component,
 		cluster:   cluster,
 		labels:    component.GetLabels(),
@@ -46,6 +50,10 @@ func (r *repo) After() error {
 	return nil
 }
 
+func (r *repo) Cleanup() error {
+	return purgeHostPath(r.ctx, r.client, r.component, r.cluster, hostPathFor(r.component.Namespace, "repo"))
+}
+
 func (r *repo) daemonSetForRepo() interface{} {
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -89,7 +97,7 @@ func (r *repo) daemonSetForRepo() interface{} {
 							Name: "repo-data",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/opt/rainbond/data/repo",
+									Path: hostPathFor(r.component.Namespace, "repo"),
 									Type: k8sutil.HostPath(corev1.HostPathDirectoryOrCreate),
 								},
 							},
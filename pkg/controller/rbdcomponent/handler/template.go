@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+// TemplateData is what the manifests of a template component are rendered with.
+type TemplateData struct {
+	Component *rainbondv1alpha1.RbdComponent
+	Cluster   rainbondv1alpha1.RainbondClusterSpec
+	// Labels are the labels of the resources of the component.
+	Labels          map[string]string
+	ImageRepository string
+	EtcdEndpoints   []string
+	// EtcdSecretName is the name of the secret that holds the etcd TLS certificates, empty if TLS is not used.
+	EtcdSecretName string
+	RegionDatabase *rainbondv1alpha1.Database
+	UIDatabase     *rainbondv1alpha1.Database
+}
+
+// RESTMapper maps the kinds rendered by template components to their resources, set by the manager.
+// The manifests of template components are rejected without it, since their scope can not be checked.
+var RESTMapper meta.RESTMapper
+
+// templateKinds are the kinds that template components may create, which are namespaced and watched by
+// the rbdcomponent controller. The manifests are editable by anyone who can edit the ConfigMap, while the
+// resources are created by the operator, so RBAC, cluster-scoped and custom kinds are not allowed.
+var templateKinds = map[schema.GroupKind]bool{
+	{Group: corev1.GroupName, Kind: "ConfigMap"}:                  true,
+	{Group: corev1.GroupName, Kind: "Secret"}:                     true,
+	{Group: corev1.GroupName, Kind: "Service"}:                    true,
+	{Group: corev1.GroupName, Kind: "PersistentVolumeClaim"}:      true,
+	{Group: appsv1.GroupName, Kind: "Deployment"}:                 true,
+	{Group: appsv1.GroupName, Kind: "StatefulSet"}:                true,
+	{Group: appsv1.GroupName, Kind: "DaemonSet"}:                  true,
+	{Group: batchv1.GroupName, Kind: "Job"}:                       true,
+	{Group: extensionsv1beta1.GroupName, Kind: "Ingress"}:         true,
+	{Group: networkingv1beta1.GroupName, Kind: "Ingress"}:         true,
+	{Group: policyv1beta1.GroupName, Kind: "PodDisruptionBudget"}: true,
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+type templateComponent struct {
+	ctx       context.Context
+	client    client.Client
+	component *rainbondv1alpha1.RbdComponent
+	cluster   *rainbondv1alpha1.RainbondCluster
+
+	resources []interface{}
+}
+
+// NewTemplate creates a handler for the components of type template, which are rendered from the manifests
+// in the ConfigMap referenced by RbdComponentSpec.Template.
+func NewTemplate(ctx context.Context, client client.Client, component *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, pkg *rainbondv1alpha1.RainbondPackage) ComponentHandler {
+	return &templateComponent{
+		ctx:       ctx,
+		client:    client,
+		component: component,
+		cluster:   cluster,
+	}
+}
+
+func (t *templateComponent) Before() error {
+	if t.component.Spec.Template == nil || t.component.Spec.Template.ConfigMapName == "" {
+		return fmt.Errorf("spec.template.configMapName is required for components of type template")
+	}
+	cm := &corev1.ConfigMap{}
+	if err := t.client.Get(t.ctx, types.NamespacedName{Namespace: t.component.Namespace, Name: t.component.Spec.Template.ConfigMapName}, cm); err != nil {
+		return fmt.Errorf("get configmap %s: %v", t.component.Spec.Template.ConfigMapName, err)
+	}
+
+	data, err := t.templateData()
+	if err != nil {
+		return err
+	}
+	resources, err := RenderTemplates(cm.Data, data)
+	if err != nil {
+		return err
+	}
+	for _, res := range resources {
+		if err := checkNamespaced(res); err != nil {
+			return err
+		}
+		obj := res.(metav1.Object)
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(t.component.Namespace)
+		}
+		if obj.GetNamespace() != t.component.Namespace {
+			return fmt.Errorf("%T %s: namespace must be %s", res, obj.GetName(), t.component.Namespace)
+		}
+		if podSpec := podSpecOf(res); podSpec != nil {
+			if err := checkPodSpec(podSpec); err != nil {
+				return fmt.Errorf("%T %s: %v", res, obj.GetName(), err)
+			}
+		}
+	}
+	t.resources = resources
+	return nil
+}
+
+func (t *templateComponent) Resources() []interface{} {
+	return t.resources
+}
+
+func (t *templateComponent) After() error {
+	return nil
+}
+
+func (t *templateComponent) templateData() (*TemplateData, error) {
+	regionDB, err := getDefaultDBInfo(t.ctx, t.client, t.cluster.Spec.RegionDatabase, t.component.Namespace, DBName)
+	if err != nil {
+		return nil, fmt.Errorf("get db info: %v", err)
+	}
+	uiDB, err := getDefaultDBInfo(t.ctx, t.client, t.cluster.Spec.UIDatabase, t.component.Namespace, DBName)
+	if err != nil {
+		return nil, fmt.Errorf("get db info: %v", err)
+	}
+	data := &TemplateData{
+		Component:       t.component,
+		Cluster:         t.cluster.Spec,
+		Labels:          copyLabels(t.component.GetLabels()),
+		ImageRepository: rbdutil.GetImageRepository(t.cluster),
+		EtcdEndpoints:   etcdEndpoints(t.cluster),
+		RegionDatabase:  regionDB,
+		UIDatabase:      uiDB,
+	}
+	if t.cluster.Spec.EtcdConfig != nil {
+		data.EtcdSecretName = t.cluster.Spec.EtcdConfig.SecretName
+	}
+	return data, nil
+}
+
+// checkNamespaced returns an error if the resource is not namespaced according to RESTMapper.
+func checkNamespaced(res interface{}) error {
+	obj := res.(metav1.Object)
+	if RESTMapper == nil {
+		return fmt.Errorf("%T %s: no RESTMapper to check the scope", res, obj.GetName())
+	}
+	gvk := res.(runtime.Object).GetObjectKind().GroupVersionKind()
+	mapping, err := RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("%T %s: %v", res, obj.GetName(), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%T %s: %s is not namespaced", res, obj.GetName(), gvk.Kind)
+	}
+	return nil
+}
+
+// podSpecOf returns the pod spec of the workloads in templateKinds, nil for other kinds.
+func podSpecOf(res interface{}) *corev1.PodSpec {
+	switch obj := res.(type) {
+	case *appsv1.Deployment:
+		return &obj.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &obj.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &obj.Spec.Template.Spec
+	case *batchv1.Job:
+		return &obj.Spec.Template.Spec
+	}
+	return nil
+}
+
+// checkPodSpec returns an error if the pod spec runs with a service account other than the default one,
+// or with access to the host: privileged containers, host namespaces or hostPath volumes.
+func checkPodSpec(podSpec *corev1.PodSpec) error {
+	if podSpec.ServiceAccountName != "" && podSpec.ServiceAccountName != "default" {
+		return fmt.Errorf("service account %s is not allowed, only the default one", podSpec.ServiceAccountName)
+	}
+	if podSpec.HostNetwork {
+		return fmt.Errorf("hostNetwork is not allowed")
+	}
+	if podSpec.HostPID {
+		return fmt.Errorf("hostPID is not allowed")
+	}
+	if podSpec.HostIPC {
+		return fmt.Errorf("hostIPC is not allowed")
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			return fmt.Errorf("volume %s: hostPath is not allowed", volume.Name)
+		}
+	}
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		if sc := container.SecurityContext; sc != nil && sc.Privileged != nil && *sc.Privileged {
+			return fmt.Errorf("container %s: privileged is not allowed", container.Name)
+		}
+	}
+	return nil
+}
+
+// RenderTemplates renders the templates, by the sorted keys, into the objects of templateKinds.
+func RenderTemplates(templates map[string]string, data interface{}) ([]interface{}, error) {
+	var keys []string
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	decoder := scheme.Codecs.UniversalDeserializer()
+	var objs []interface{}
+	for _, key := range keys {
+		tmpl, err := template.New(key).Funcs(templateFuncs).Option("missingkey=error").Parse(templates[key])
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %v", key, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("render template %s: %v", key, err)
+		}
+
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(&buf))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read manifests of %s: %v", key, err)
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			obj, gvk, err := decoder.Decode(doc, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("decode manifest of %s: %v", key, err)
+			}
+			if _, ok := obj.(metav1.Object); !ok || !templateKinds[gvk.GroupKind()] {
+				return nil, fmt.Errorf("decode manifest of %s: kind %s is not allowed", key, gvk.GroupKind())
+			}
+			obj.GetObjectKind().SetGroupVersionKind(*gvk)
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const logShipperTemplate = `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{ .Component.Name }}
+  labels:
+{{- range $k, $v := .Labels }}
+    {{ $k }}: {{ $v }}
+{{- end }}
+spec:
+  selector:
+    matchLabels:
+      name: {{ .Component.Name }}
+  template:
+    spec:
+      containers:
+      - name: {{ .Component.Name }}
+        image: {{ .ImageRepository }}/log-shipper:v1
+        args:
+        - --etcd-endpoints={{ join .EtcdEndpoints "," }}
+        - --db-host={{ .RegionDatabase.Host }}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Component.Name }}
+`
+
+func TestTemplateComponent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Service"), meta.RESTScopeNamespace)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("DaemonSet"), meta.RESTScopeNamespace)
+	RESTMapper = mapper
+	defer func() { RESTMapper = nil }()
+
+	tests := []struct {
+		name      string
+		templates map[string]string
+		wantErr   bool
+		wantNames []string
+	}{
+		{
+			name:      "ok",
+			templates: map[string]string{"log-shipper.yaml": logShipperTemplate},
+			wantNames: []string{"rbd-log-shipper", "rbd-log-shipper"},
+		},
+		{
+			name: "sorted by key",
+			templates: map[string]string{
+				"b.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+				"a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			},
+			wantNames: []string{"a", "b"},
+		},
+		{
+			name:      "missing key",
+			templates: map[string]string{"a.yaml": "{{ .Foobar }}"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown kind",
+			templates: map[string]string{"a.yaml": "apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: a\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "cluster-scoped kind",
+			templates: map[string]string{"a.yaml": "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRoleBinding\nmetadata:\n  name: a\nroleRef:\n  apiGroup: rbac.authorization.k8s.io\n  kind: ClusterRole\n  name: cluster-admin\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "namespaced kind not allowed",
+			templates: map[string]string{"a.yaml": "apiVersion: rbac.authorization.k8s.io/v1\nkind: RoleBinding\nmetadata:\n  name: a\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "service account",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      serviceAccountName: rainbond-operator\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "host network",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      hostNetwork: true\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "host pid",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      hostPID: true\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "host ipc",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      hostIPC: true\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "host path",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      volumes:\n      - name: root\n        hostPath:\n          path: /\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "privileged",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      containers:\n      - name: a\n        securityContext:\n          privileged: true\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "privileged init container",
+			templates: map[string]string{"a.yaml": "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      initContainers:\n      - name: a\n        securityContext:\n          privileged: true\n"},
+			wantErr:   true,
+		},
+		{
+			name:      "other namespace",
+			templates: map[string]string{"a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: kube-system\n"},
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "log-shipper"},
+				Data:       tc.templates,
+			}
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: DBName}}
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rbd-log-shipper"},
+				Spec: rainbondv1alpha1.RbdComponentSpec{
+					Type:     rainbondv1alpha1.TemplateComponentType,
+					Template: &rainbondv1alpha1.TemplateSource{ConfigMapName: cm.Name},
+				},
+			}
+			cluster := &rainbondv1alpha1.RainbondCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rainbondcluster"}}
+
+			hdl := NewTemplate(context.Background(), fake.NewFakeClientWithScheme(scheme, cm, secret), cpt, cluster, nil)
+			err := hdl.Before()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var names []string
+			for _, res := range hdl.Resources() {
+				meta := res.(metav1.Object)
+				if meta.GetNamespace() != "rbd-system" {
+					t.Errorf("Expected namespace rbd-system, but got %s", meta.GetNamespace())
+				}
+				names = append(names, meta.GetName())
+				if ds, ok := res.(*appsv1.DaemonSet); ok {
+					want := []string{"--etcd-endpoints=http://rbd-etcd:2379", "--db-host=" + DBName}
+					if got := ds.Spec.Template.Spec.Containers[0].Args; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+						t.Errorf("Expected %v, but got %v", want, got)
+					}
+					if ds.Labels["name"] != cpt.Name {
+						t.Errorf("Expected label name %s, but got %v", cpt.Name, ds.Labels)
+					}
+				}
+			}
+			if len(names) != len(tc.wantNames) {
+				t.Fatalf("Expected %v, but got %v", tc.wantNames, names)
+			}
+			for i := range names {
+				if names[i] != tc.wantNames[i] {
+					t.Errorf("Expected %v, but got %v", tc.wantNames, names)
+				}
+			}
+		})
+	}
+}
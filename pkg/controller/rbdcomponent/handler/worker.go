@@ -9,14 +9,15 @@ import (
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var WorkerName = "rbd-worker"
 
+// workerConfigKeys are the command line flags of rbd-worker that can be set through RbdComponentSpec.Configs.
+var workerConfigKeys = []string{"max-tasks", "listen", "leader-election", "mq-api"}
+
 type worker struct {
 	ctx        context.Context
 	client     client.Client
@@ -57,7 +58,8 @@ func (w *worker) Before() error {
 
 func (w *worker) Resources() []interface{} {
 	return []interface{}{
-		w.daemonSetForWorker(),
+		w.workloadForWorker(),
+		pdbForComponent(w.component, WorkerName, w.labels, w.component.Replicas()),
 	}
 }
 
@@ -65,7 +67,11 @@ func (w *worker) After() error {
 	return nil
 }
 
-func (w *worker) daemonSetForWorker() interface{} {
+func (w *worker) ConfigKeys() []string {
+	return workerConfigKeys
+}
+
+func (w *worker) workloadForWorker() interface{} {
 	volumeMounts := []corev1.VolumeMount{
 		{
 			Name:      "grdata",
@@ -86,7 +92,7 @@ func (w *worker) daemonSetForWorker() interface{} {
 		fmt.Sprintf("--log-level=%s", w.component.LogLevel()),
 		"--host-ip=$(POD_IP)",
 		"--node-name=$(HOST_IP)",
-		w.db.RegionDataSource(),
+		"--mysql=" + regionDataSource(w.db),
 		"--etcd-endpoints=" + strings.Join(etcdEndpoints(w.cluster), ","),
 	}
 	if w.etcdSecret != nil {
@@ -95,64 +101,47 @@ func (w *worker) daemonSetForWorker() interface{} {
 		volumes = append(volumes, volume)
 		args = append(args, etcdSSLArgs()...)
 	}
+	args = withConfigArgs(args, w.component.Spec.Configs, workerConfigKeys)
 
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      WorkerName,
-			Namespace: w.component.Namespace,
-			Labels:    w.labels,
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: w.labels,
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: commonutil.Int64(0),
+		ServiceAccountName:            "rainbond-operator",
+		Tolerations: []corev1.Toleration{
+			{
+				Key:    w.cluster.Status.MasterRoleLabel,
+				Effect: corev1.TaintEffectNoSchedule,
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   WorkerName,
-					Labels: w.labels,
-				},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: commonutil.Int64(0),
-					ServiceAccountName:            "rainbond-operator",
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    w.cluster.Status.MasterRoleLabel,
-							Effect: corev1.TaintEffectNoSchedule,
+		},
+		Containers: []corev1.Container{
+			{
+				Name:            WorkerName,
+				Image:           w.component.Spec.Image,
+				ImagePullPolicy: w.component.ImagePullPolicy(),
+				Env: []corev1.EnvVar{
+					dbPasswordEnv(dbPasswordEnvName, w.db),
+					{
+						Name: "POD_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIP",
+							},
 						},
 					},
-					NodeSelector: w.cluster.Status.FirstMasterNodeLabel(),
-					Containers: []corev1.Container{
-						{
-							Name:            WorkerName,
-							Image:           w.component.Spec.Image,
-							ImagePullPolicy: w.component.ImagePullPolicy(),
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
-								{
-									Name: "HOST_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.hostIP",
-										},
-									},
-								},
+					{
+						Name: "HOST_IP",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.hostIP",
 							},
-							Args:         args,
-							VolumeMounts: volumeMounts,
 						},
 					},
-					Volumes: volumes,
 				},
+				Args:         args,
+				VolumeMounts: volumeMounts,
 			},
 		},
+		Volumes: volumes,
 	}
 
-	return ds
+	return workloadForComponent(w.component, w.cluster, WorkerName, w.labels, podSpec)
 }
@@ -0,0 +1,40 @@
+package rbdcomponent
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+)
+
+// HealthCheckInterval is how often the components whose handler is a HealthChecker are checked.
+var HealthCheckInterval = 30 * time.Second
+
+// checkHealth sets the Healthy condition of the RbdComponent with the result of the health check,
+// which is only run once the component is available.
+func (r *ReconcileRbdComponent) checkHealth(cpt *rainbondv1alpha1.RbdComponent, checker chandler.HealthChecker) {
+	status := cpt.Status
+	if available := status.GetCondition(rainbondv1alpha1.RbdComponentAvailable); available == nil || available.Status != corev1.ConditionTrue {
+		status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentHealthy, false, "Unavailable", "no ready replicas"))
+		return
+	}
+
+	var wasFailing bool
+	if old := status.GetCondition(rainbondv1alpha1.RbdComponentHealthy); old != nil {
+		wasFailing = old.Status == corev1.ConditionFalse && old.Reason == "HealthCheckFailed"
+	}
+	if err := checker.CheckHealth(); err != nil {
+		log.Info("health check failed", "Namespace", cpt.Namespace, "Name", cpt.Name, "err", err)
+		if !wasFailing {
+			r.recorder.Eventf(cpt, corev1.EventTypeWarning, "Unhealthy", "Health check failed: %v", err)
+		}
+		status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentHealthy, false, "HealthCheckFailed", err.Error()))
+		return
+	}
+	if wasFailing {
+		r.recorder.Event(cpt, corev1.EventTypeNormal, "Healthy", "Health check passed")
+	}
+	status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentHealthy, true, "HealthCheckPassed", ""))
+}
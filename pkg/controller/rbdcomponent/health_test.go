@@ -0,0 +1,95 @@
+package rbdcomponent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeHealthChecker struct {
+	err error
+}
+
+func (f fakeHealthChecker) CheckHealth() error {
+	return f.err
+}
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		available  bool
+		oldHealthy *rainbondv1alpha1.RbdComponentCondition
+		err        error
+		wantStatus corev1.ConditionStatus
+		wantReason string
+		wantEvent  string
+	}{
+		{
+			name:       "unavailable",
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "Unavailable",
+		},
+		{
+			name:       "healthy",
+			available:  true,
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "HealthCheckPassed",
+		},
+		{
+			name:       "unhealthy",
+			available:  true,
+			err:        errors.New("connection refused"),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "HealthCheckFailed",
+			wantEvent:  "Warning Unhealthy",
+		},
+		{
+			name:       "still unhealthy",
+			available:  true,
+			oldHealthy: &rainbondv1alpha1.RbdComponentCondition{Type: rainbondv1alpha1.RbdComponentHealthy, Status: corev1.ConditionFalse, Reason: "HealthCheckFailed"},
+			err:        errors.New("connection refused"),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "HealthCheckFailed",
+		},
+		{
+			name:       "recovered",
+			available:  true,
+			oldHealthy: &rainbondv1alpha1.RbdComponentCondition{Type: rainbondv1alpha1.RbdComponentHealthy, Status: corev1.ConditionFalse, Reason: "HealthCheckFailed"},
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "HealthCheckPassed",
+			wantEvent:  "Normal Healthy",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{Status: &rainbondv1alpha1.RbdComponentStatus{}}
+			cpt.Status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentAvailable, tc.available, "", ""))
+			if tc.oldHealthy != nil {
+				cpt.Status.SetCondition(*tc.oldHealthy)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileRbdComponent{recorder: recorder}
+
+			r.checkHealth(cpt, fakeHealthChecker{err: tc.err})
+
+			condition := cpt.Status.GetCondition(rainbondv1alpha1.RbdComponentHealthy)
+			if condition == nil || condition.Status != tc.wantStatus || condition.Reason != tc.wantReason {
+				t.Errorf("Expected %s %s, but got %+v", tc.wantStatus, tc.wantReason, condition)
+			}
+			select {
+			case event := <-recorder.Events:
+				if tc.wantEvent == "" || !strings.HasPrefix(event, tc.wantEvent) {
+					t.Errorf("Expected event %q, but got %s", tc.wantEvent, event)
+				}
+			default:
+				if tc.wantEvent != "" {
+					t.Errorf("Expected event %s, but got none", tc.wantEvent)
+				}
+			}
+		})
+	}
+}
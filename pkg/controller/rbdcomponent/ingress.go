@@ -0,0 +1,181 @@
+package rbdcomponent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+// IngressVersion is the group version of the Ingresses created for the components.
+// It is detected from the API server at startup.
+var IngressVersion = k8sutil.IngressExtensionsV1beta1
+
+// IngressClass is the class of the Ingresses created for the components, the default class of the cluster if empty.
+var IngressClass string
+
+// ingressClassAnnotation selects the class of Ingresses older than networking.k8s.io/v1.
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// newIngress returns an empty Ingress of IngressVersion.
+// The Kubernetes API this operator is built with has no networking.k8s.io/v1 Ingress, which is unstructured.
+func newIngress() runtime.Object {
+	switch IngressVersion {
+	case k8sutil.IngressNetworkingV1:
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(IngressVersion.WithKind("Ingress"))
+		return u
+	case k8sutil.IngressNetworkingV1beta1:
+		return &networkingv1beta1.Ingress{}
+	}
+	return &extensions.Ingress{}
+}
+
+// newIngressList returns an empty list of Ingresses of IngressVersion.
+func newIngressList() runtime.Object {
+	switch IngressVersion {
+	case k8sutil.IngressNetworkingV1:
+		u := &unstructured.UnstructuredList{}
+		u.SetGroupVersionKind(IngressVersion.WithKind("IngressList"))
+		return u
+	case k8sutil.IngressNetworkingV1beta1:
+		return &networkingv1beta1.IngressList{}
+	}
+	return &extensions.IngressList{}
+}
+
+// convertIngresses converts the extensions/v1beta1 Ingresses, which the handlers build, to IngressVersion.
+func convertIngresses(resources []interface{}) ([]interface{}, error) {
+	converted := make([]interface{}, 0, len(resources))
+	for _, res := range resources {
+		ing, ok := res.(*extensions.Ingress)
+		if !ok {
+			converted = append(converted, res)
+			continue
+		}
+		obj, err := convertIngress(ing)
+		if err != nil {
+			return nil, fmt.Errorf("convert ingress %s: %v", ing.Name, err)
+		}
+		converted = append(converted, obj)
+	}
+	return converted, nil
+}
+
+func convertIngress(ing *extensions.Ingress) (runtime.Object, error) {
+	ing = ing.DeepCopy()
+	if IngressVersion != k8sutil.IngressNetworkingV1 && IngressClass != "" {
+		if ing.Annotations == nil {
+			ing.Annotations = make(map[string]string)
+		}
+		if _, ok := ing.Annotations[ingressClassAnnotation]; !ok {
+			ing.Annotations[ingressClassAnnotation] = IngressClass
+		}
+	}
+
+	switch IngressVersion {
+	case k8sutil.IngressNetworkingV1:
+		return ingressV1(ing)
+	case k8sutil.IngressNetworkingV1beta1:
+		// networking.k8s.io/v1beta1 has the same schema as extensions/v1beta1
+		data, err := json.Marshal(ing)
+		if err != nil {
+			return nil, err
+		}
+		out := &networkingv1beta1.Ingress{}
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, err
+		}
+		out.TypeMeta = ing.TypeMeta
+		out.SetGroupVersionKind(IngressVersion.WithKind("Ingress"))
+		return out, nil
+	}
+	return ing, nil
+}
+
+// ingressV1 builds the networking.k8s.io/v1 Ingress from the extensions/v1beta1 one.
+func ingressV1(ing *extensions.Ingress) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ing)
+	if err != nil {
+		return nil, err
+	}
+	delete(content, "status")
+
+	spec := make(map[string]interface{})
+	if IngressClass != "" {
+		spec["ingressClassName"] = IngressClass
+	}
+	if ing.Spec.Backend != nil {
+		spec["defaultBackend"] = ingressBackendV1(*ing.Spec.Backend)
+	}
+	var tls []interface{}
+	for _, t := range ing.Spec.TLS {
+		item := map[string]interface{}{}
+		if len(t.Hosts) > 0 {
+			var hosts []interface{}
+			for _, host := range t.Hosts {
+				hosts = append(hosts, host)
+			}
+			item["hosts"] = hosts
+		}
+		if t.SecretName != "" {
+			item["secretName"] = t.SecretName
+		}
+		tls = append(tls, item)
+	}
+	if len(tls) > 0 {
+		spec["tls"] = tls
+	}
+	var rules []interface{}
+	for _, r := range ing.Spec.Rules {
+		rule := map[string]interface{}{}
+		if r.Host != "" {
+			rule["host"] = r.Host
+		}
+		if r.HTTP != nil {
+			var paths []interface{}
+			for _, p := range r.HTTP.Paths {
+				path := map[string]interface{}{
+					// the paths of the older versions are matched by the ingress controller
+					"pathType": "ImplementationSpecific",
+					"backend":  ingressBackendV1(p.Backend),
+				}
+				if p.Path != "" {
+					path["path"] = p.Path
+				}
+				paths = append(paths, path)
+			}
+			rule["http"] = map[string]interface{}{"paths": paths}
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		spec["rules"] = rules
+	}
+	content["spec"] = spec
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(k8sutil.IngressNetworkingV1.WithKind("Ingress"))
+	return u, nil
+}
+
+func ingressBackendV1(backend extensions.IngressBackend) map[string]interface{} {
+	port := map[string]interface{}{}
+	if backend.ServicePort.Type == intstr.String {
+		port["name"] = backend.ServicePort.StrVal
+	} else {
+		port["number"] = int64(backend.ServicePort.IntVal)
+	}
+	return map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": backend.ServiceName,
+			"port": port,
+		},
+	}
+}
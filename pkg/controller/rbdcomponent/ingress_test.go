@@ -0,0 +1,128 @@
+package rbdcomponent
+
+import (
+	"testing"
+
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+func TestConvertIngress(t *testing.T) {
+	defer func(version schema.GroupVersion, class string) {
+		IngressVersion, IngressClass = version, class
+	}(IngressVersion, IngressClass)
+
+	l4 := &extensions.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rbd-api",
+			Namespace: "rbd-system",
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/l4-enable": "true",
+				"nginx.ingress.kubernetes.io/l4-port":   "8443",
+			},
+		},
+		Spec: extensions.IngressSpec{
+			Backend: &extensions.IngressBackend{ServiceName: "rbd-api-api", ServicePort: intstr.FromString("https")},
+		},
+	}
+	http := &extensions.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-hub", Namespace: "rbd-system"},
+		Spec: extensions.IngressSpec{
+			Rules: []extensions.IngressRule{
+				{
+					Host: "goodrain.me",
+					IngressRuleValue: extensions.IngressRuleValue{
+						HTTP: &extensions.HTTPIngressRuleValue{
+							Paths: []extensions.HTTPIngressPath{
+								{Path: "/v2/", Backend: extensions.IngressBackend{ServiceName: "rbd-hub", ServicePort: intstr.FromInt(5000)}},
+							},
+						},
+					},
+				},
+			},
+			TLS: []extensions.IngressTLS{{Hosts: []string{"goodrain.me"}, SecretName: "hub-image-repository"}},
+		},
+	}
+
+	t.Run("extensions/v1beta1", func(t *testing.T) {
+		IngressVersion, IngressClass = k8sutil.IngressExtensionsV1beta1, ""
+		obj, err := convertIngress(l4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := obj.(*extensions.Ingress); !ok {
+			t.Errorf("Expected *v1beta1.Ingress, but got %T", obj)
+		}
+	})
+
+	t.Run("networking.k8s.io/v1beta1", func(t *testing.T) {
+		IngressVersion, IngressClass = k8sutil.IngressNetworkingV1beta1, "rbd"
+		obj, err := convertIngress(l4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ing, ok := obj.(*networkingv1beta1.Ingress)
+		if !ok {
+			t.Fatalf("Expected *v1beta1.Ingress, but got %T", obj)
+		}
+		if ing.Annotations["nginx.ingress.kubernetes.io/l4-port"] != "8443" || ing.Annotations[ingressClassAnnotation] != "rbd" {
+			t.Errorf("Expected the l4 and class annotations, but got %v", ing.Annotations)
+		}
+		if ing.Spec.Backend == nil || ing.Spec.Backend.ServiceName != "rbd-api-api" {
+			t.Errorf("Expected backend rbd-api-api, but got %v", ing.Spec.Backend)
+		}
+		if _, ok := l4.Annotations[ingressClassAnnotation]; ok {
+			t.Errorf("Expected the original ingress to be left unchanged")
+		}
+	})
+
+	t.Run("networking.k8s.io/v1", func(t *testing.T) {
+		IngressVersion, IngressClass = k8sutil.IngressNetworkingV1, "rbd"
+		obj, err := convertIngress(l4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := obj.(*unstructured.Unstructured)
+		if u.GetAPIVersion() != "networking.k8s.io/v1" || u.GetKind() != "Ingress" {
+			t.Errorf("Expected networking.k8s.io/v1 Ingress, but got %s %s", u.GetAPIVersion(), u.GetKind())
+		}
+		if u.GetAnnotations()["nginx.ingress.kubernetes.io/l4-enable"] != "true" {
+			t.Errorf("Expected the l4 annotations, but got %v", u.GetAnnotations())
+		}
+		if class, _, _ := unstructured.NestedString(u.Object, "spec", "ingressClassName"); class != "rbd" {
+			t.Errorf("Expected ingressClassName rbd, but got %q", class)
+		}
+		if port, _, _ := unstructured.NestedString(u.Object, "spec", "defaultBackend", "service", "port", "name"); port != "https" {
+			t.Errorf("Expected port https, but got %q", port)
+		}
+
+		obj, err = convertIngress(http)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u = obj.(*unstructured.Unstructured)
+		rules, _, _ := unstructured.NestedSlice(u.Object, "spec", "rules")
+		if len(rules) != 1 {
+			t.Fatalf("Expected 1 rule, but got %v", rules)
+		}
+		paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+		if len(paths) != 1 {
+			t.Fatalf("Expected 1 path, but got %v", paths)
+		}
+		path := paths[0].(map[string]interface{})
+		if path["pathType"] != "ImplementationSpecific" || path["path"] != "/v2/" {
+			t.Errorf("Expected ImplementationSpecific /v2/, but got %v", path)
+		}
+		if port, _, _ := unstructured.NestedInt64(path, "backend", "service", "port", "number"); port != 5000 {
+			t.Errorf("Expected port 5000, but got %d", port)
+		}
+		// DeepCopy panics on values that are not JSON compatible, such as int
+		_ = u.DeepCopy()
+	})
+}
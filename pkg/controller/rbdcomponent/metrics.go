@@ -0,0 +1,72 @@
+package rbdcomponent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rainbond_operator_component_reconcile_duration_seconds",
+		Help:    "Time spent reconciling a RbdComponent.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"namespace", "component"})
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rainbond_operator_component_reconcile_errors_total",
+		Help: "Number of reconciliations of a RbdComponent that returned an error.",
+	}, []string{"namespace", "component"})
+	prerequisitesWaiting = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rainbond_operator_component_prerequisites_waiting_seconds",
+		Help: "How long the prerequisites of a RbdComponent have not been met, 0 if they are met.",
+	}, []string{"namespace", "component"})
+)
+
+func init() {
+	// Served with the other controller-runtime metrics on the metrics port of the manager.
+	metrics.Registry.MustRegister(reconcileDuration, reconcileErrors, prerequisitesWaiting)
+}
+
+// waitingSince is when the components started waiting for their prerequisites.
+var waitingSince = struct {
+	sync.Mutex
+	m map[types.NamespacedName]time.Time
+}{m: make(map[types.NamespacedName]time.Time)}
+
+// observeReconcile records the duration and the result of a reconciliation of the component.
+func observeReconcile(component types.NamespacedName, duration time.Duration, err error) {
+	reconcileDuration.WithLabelValues(component.Namespace, component.Name).Observe(duration.Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(component.Namespace, component.Name).Inc()
+	}
+}
+
+// observePrerequisites records whether Before() of the handler of the component is still returning IgnoreErrors.
+func observePrerequisites(component types.NamespacedName, waiting bool) {
+	waitingSince.Lock()
+	defer waitingSince.Unlock()
+	if !waiting {
+		delete(waitingSince.m, component)
+		prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name).Set(0)
+		return
+	}
+	since, ok := waitingSince.m[component]
+	if !ok {
+		since = time.Now()
+		waitingSince.m[component] = since
+	}
+	prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name).Set(time.Since(since).Seconds())
+}
+
+// forgetComponent drops the metrics of a deleted component.
+func forgetComponent(component types.NamespacedName) {
+	waitingSince.Lock()
+	defer waitingSince.Unlock()
+	delete(waitingSince.m, component)
+	reconcileDuration.DeleteLabelValues(component.Namespace, component.Name)
+	reconcileErrors.DeleteLabelValues(component.Namespace, component.Name)
+	prerequisitesWaiting.DeleteLabelValues(component.Namespace, component.Name)
+}
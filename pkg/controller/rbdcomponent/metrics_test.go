@@ -0,0 +1,55 @@
+package rbdcomponent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestObservePrerequisites(t *testing.T) {
+	component := types.NamespacedName{Namespace: "rbd-system", Name: "rbd-test-metrics"}
+	other := types.NamespacedName{Namespace: "rbd-other", Name: component.Name}
+	defer forgetComponent(component)
+	defer forgetComponent(other)
+
+	observePrerequisites(component, true)
+	time.Sleep(10 * time.Millisecond)
+	observePrerequisites(component, true)
+	if got := testutil.ToFloat64(prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name)); got < 0.01 {
+		t.Errorf("Expected waiting for at least 0.01 seconds, but got %v", got)
+	}
+
+	observePrerequisites(other, false)
+	if got := testutil.ToFloat64(prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name)); got < 0.01 {
+		t.Errorf("Expected the component in another namespace to be kept apart, but got %v", got)
+	}
+
+	observePrerequisites(component, false)
+	if got := testutil.ToFloat64(prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name)); got != 0 {
+		t.Errorf("Expected 0, but got %v", got)
+	}
+	observePrerequisites(component, true)
+	if got := testutil.ToFloat64(prerequisitesWaiting.WithLabelValues(component.Namespace, component.Name)); got >= 0.01 {
+		t.Errorf("Expected the waiting time to be reset, but got %v", got)
+	}
+}
+
+func TestForgetComponent(t *testing.T) {
+	component := types.NamespacedName{Namespace: "rbd-system", Name: "rbd-test-forget"}
+	observeReconcile(component, time.Second, fmt.Errorf("foobar"))
+	observePrerequisites(component, true)
+
+	forgetComponent(component)
+	if reconcileDuration.DeleteLabelValues(component.Namespace, component.Name) {
+		t.Errorf("Expected the reconcile duration to be dropped")
+	}
+	if reconcileErrors.DeleteLabelValues(component.Namespace, component.Name) {
+		t.Errorf("Expected the reconcile errors to be dropped")
+	}
+	if prerequisitesWaiting.DeleteLabelValues(component.Namespace, component.Name) {
+		t.Errorf("Expected the prerequisites waiting time to be dropped")
+	}
+}
@@ -0,0 +1,167 @@
+package rbdcomponent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+// maxDriftedFields is the number of drifted fields reported for a resource.
+const maxDriftedFields = 5
+
+// pausedBy returns the object whose PausedAnnotation pauses the reconciliation of the component, empty if not paused.
+func pausedBy(cpt *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster) string {
+	if rainbondv1alpha1.IsPaused(cpt) {
+		return "rbdcomponent/" + cpt.Name
+	}
+	if cluster != nil && rainbondv1alpha1.IsPaused(cluster) {
+		return "rainbondcluster/" + cluster.Name
+	}
+	return ""
+}
+
+// isPausedCondition returns whether the Paused condition of the component is true,
+// which means the reconciliation is resuming if the component is no longer paused.
+func isPausedCondition(cpt *rainbondv1alpha1.RbdComponent) bool {
+	if cpt.Status == nil {
+		return false
+	}
+	condition := cpt.Status.GetCondition(rainbondv1alpha1.RbdComponentPaused)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// pause sets the Paused condition of the component, the rest of the status is left as it is.
+func (r *ReconcileRbdComponent) pause(cpt *rainbondv1alpha1.RbdComponent, by string) error {
+	if isPausedCondition(cpt) {
+		return nil
+	}
+	status := cpt.Status.DeepCopy()
+	if status == nil {
+		status = &rainbondv1alpha1.RbdComponentStatus{
+			ControllerType: rainbondv1alpha1.ControllerTypeUnknown,
+			ControllerName: cpt.Name,
+		}
+	}
+	message := fmt.Sprintf("paused by the %s annotation of %s", rainbondv1alpha1.PausedAnnotation, by)
+	status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentPaused, true, "PausedByAnnotation", message))
+	r.recorder.Eventf(cpt, corev1.EventTypeNormal, "Paused", "Reconciliation %s", message)
+	cpt.Status = status
+	return k8sutil.UpdateCRStatus(r.client, cpt)
+}
+
+// drift returns the fields of the live resource that differ from the desired one,
+// which are reverted when the reconciliation resumes. A missing resource is reported as deleted.
+func (r *ReconcileRbdComponent) drift(ctx context.Context, res interface{}) ([]string, error) {
+	meta := res.(metav1.Object)
+	live := res.(runtime.Object).DeepCopyObject()
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()}, live); err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return []string{"deleted"}, nil
+		}
+		return nil, err
+	}
+
+	desiredContent, err := toUnstructuredContent(res.(runtime.Object))
+	if err != nil {
+		return nil, err
+	}
+	liveContent, err := toUnstructuredContent(live)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for _, key := range sortedKeys(desiredContent) {
+		switch key {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			desiredMeta, _ := desiredContent[key].(map[string]interface{})
+			liveMeta, _ := liveContent[key].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				fields = append(fields, driftedFields("metadata."+field, desiredMeta[field], liveMeta[field])...)
+			}
+		default:
+			fields = append(fields, driftedFields(key, desiredContent[key], liveContent[key])...)
+		}
+	}
+	return fields, nil
+}
+
+func toUnstructuredContent(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.UnstructuredContent(), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// driftedFields returns the paths of the fields set in desired whose values in live are different.
+// The fields only set in live are defaulted or added by others, and are left alone.
+func driftedFields(path string, desired, live interface{}) []string {
+	switch d := desired.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if len(d) == 0 {
+			return nil
+		}
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		var fields []string
+		for _, key := range sortedKeys(d) {
+			if path == "metadata.annotations" && key == k8sutil.SpecHashAnnotation {
+				continue
+			}
+			fields = append(fields, driftedFields(path+"."+key, d[key], l[key])...)
+		}
+		return fields
+	case []interface{}:
+		if len(d) == 0 {
+			return nil
+		}
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return []string{path}
+		}
+		var fields []string
+		for i := range d {
+			fields = append(fields, driftedFields(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return fields
+	}
+	if !reflect.DeepEqual(desired, live) {
+		return []string{path}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// driftSummary formats the drifted fields of a resource for the logs and events.
+func driftSummary(res interface{}, fields []string) string {
+	key := resourceKey(res, res.(metav1.Object).GetName())
+	if len(fields) > maxDriftedFields {
+		return fmt.Sprintf("%s: %s and %d more", key, strings.Join(fields[:maxDriftedFields], ", "), len(fields)-maxDriftedFields)
+	}
+	return fmt.Sprintf("%s: %s", key, strings.Join(fields, ", "))
+}
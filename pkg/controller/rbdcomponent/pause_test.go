@@ -0,0 +1,149 @@
+package rbdcomponent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	paused := map[string]string{rainbondv1alpha1.PausedAnnotation: "true"}
+
+	tests := []struct {
+		name               string
+		cptAnnotations     map[string]string
+		clusterAnnotations map[string]string
+		wantMessage        string
+	}{
+		{
+			name:           "paused by the component",
+			cptAnnotations: paused,
+			wantMessage:    "rbdcomponent/" + handler.APIName,
+		},
+		{
+			name:               "paused by the cluster",
+			clusterAnnotations: paused,
+			wantMessage:        "rainbondcluster/" + rainbondv1alpha1.DefaultRainbondClusterName,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: handler.APIName, Annotations: tc.cptAnnotations}}
+			cluster := &rainbondv1alpha1.RainbondCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: rainbondv1alpha1.DefaultRainbondClusterName, Annotations: tc.clusterAnnotations}}
+			cli := fake.NewFakeClientWithScheme(scheme, cpt, cluster)
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileRbdComponent{client: cli, scheme: scheme, recorder: recorder}
+
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}}
+			if _, err := r.Reconcile(request); err != nil {
+				t.Fatal(err)
+			}
+
+			got := &rainbondv1alpha1.RbdComponent{}
+			if err := cli.Get(context.Background(), request.NamespacedName, got); err != nil {
+				t.Fatal(err)
+			}
+			if !isPausedCondition(got) {
+				t.Fatalf("Expected the Paused condition to be true, but got %+v", got.Status)
+			}
+			if message := got.Status.GetCondition(rainbondv1alpha1.RbdComponentPaused).Message; !strings.HasSuffix(message, tc.wantMessage) {
+				t.Errorf("Expected %s, but got %s", tc.wantMessage, message)
+			}
+			select {
+			case event := <-recorder.Events:
+				want := "Normal Paused"
+				if !strings.HasPrefix(event, want) {
+					t.Errorf("Expected %s, but got %s", want, event)
+				}
+			default:
+				t.Errorf("Expected an event, but got none")
+			}
+		})
+	}
+}
+
+func TestDrift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := appv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	deployment := func(image string, replicas int32) *appv1.Deployment {
+		return &appv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rbd-api", Labels: map[string]string{"name": "rbd-api"}},
+			Spec: appv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "rbd-api", Image: image}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		live *appv1.Deployment
+		want []string
+	}{
+		{
+			name: "unchanged",
+			live: deployment("rbd-api:v5.2", 1),
+		},
+		{
+			name: "defaulted fields",
+			live: func() *appv1.Deployment {
+				d := deployment("rbd-api:v5.2", 1)
+				d.Annotations = map[string]string{"deployment.kubernetes.io/revision": "2"}
+				d.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+				return d
+			}(),
+		},
+		{
+			name: "changed by hand",
+			live: func() *appv1.Deployment {
+				d := deployment("rbd-api:debug", 3)
+				d.Labels["name"] = "foo"
+				return d
+			}(),
+			want: []string{"metadata.labels.name", "spec.replicas", "spec.template.spec.containers[0].image"},
+		},
+		{
+			name: "deleted",
+			want: []string{"deleted"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if tc.live != nil {
+				objs = append(objs, tc.live)
+			}
+			r := &ReconcileRbdComponent{client: fake.NewFakeClientWithScheme(scheme, objs...), scheme: scheme}
+
+			got, err := r.drift(context.Background(), deployment("rbd-api:v5.2", 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package rbdcomponent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+// podTemplateOf returns the pod template of the given Deployment, StatefulSet or DaemonSet, nil otherwise.
+func podTemplateOf(obj interface{}) *corev1.PodTemplateSpec {
+	switch ctrl := obj.(type) {
+	case *appv1.Deployment:
+		return &ctrl.Spec.Template
+	case *appv1.StatefulSet:
+		return &ctrl.Spec.Template
+	case *appv1.DaemonSet:
+		return &ctrl.Spec.Template
+	}
+	return nil
+}
+
+// applyClusterNodeSelector adds the node selector of the cluster to the pod template, which keeps the pods
+// on the nodes of the cluster whatever the component or its patch selects.
+func applyClusterNodeSelector(cluster *rainbondv1alpha1.RainbondCluster, template *corev1.PodTemplateSpec) {
+	if len(cluster.Spec.NodeSelector) == 0 {
+		return
+	}
+	selector := make(map[string]string, len(template.Spec.NodeSelector)+len(cluster.Spec.NodeSelector))
+	for k, v := range template.Spec.NodeSelector {
+		selector[k] = v
+	}
+	for k, v := range cluster.Spec.NodeSelector {
+		selector[k] = v
+	}
+	template.Spec.NodeSelector = selector
+}
+
+// applyComponentSpec merges the scheduling and resource settings of the RbdComponent into the pod template
+// that the handler built.
+func applyComponentSpec(cpt *rainbondv1alpha1.RbdComponent, template *corev1.PodTemplateSpec) {
+	spec := &template.Spec
+	if len(cpt.Spec.NodeSelector) > 0 {
+		spec.NodeSelector = cpt.Spec.NodeSelector
+	}
+	if cpt.Spec.Affinity != nil {
+		spec.Affinity = cpt.Spec.Affinity
+	}
+	if len(cpt.Spec.Tolerations) > 0 {
+		spec.Tolerations = append(spec.Tolerations, cpt.Spec.Tolerations...)
+	}
+	if cpt.Spec.PriorityClassName != "" {
+		spec.PriorityClassName = cpt.Spec.PriorityClassName
+	}
+
+	container := mainContainer(cpt, spec)
+	if container == nil {
+		return
+	}
+	if cpt.Spec.Resources != nil {
+		container.Resources = *cpt.Spec.Resources
+	}
+	container.Env = mergeEnv(container.Env, cpt.Spec.Env)
+}
+
+// mainContainer returns the container named after the component, or the first container if there is none.
+func mainContainer(cpt *rainbondv1alpha1.RbdComponent, spec *corev1.PodSpec) *corev1.Container {
+	if len(spec.Containers) == 0 {
+		return nil
+	}
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == cpt.Name {
+			return &spec.Containers[i]
+		}
+	}
+	return &spec.Containers[0]
+}
+
+// mergeEnv adds extra to env. Variables in extra replace the ones with the same name in env.
+func mergeEnv(env, extra []corev1.EnvVar) []corev1.EnvVar {
+	for _, e := range extra {
+		replaced := false
+		for i := range env {
+			if env[i].Name == e.Name {
+				env[i] = e
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// preparePodTemplates applies the spec and the pod template patch of the RbdComponent, and the node selector of the
+// cluster, to the pod templates of all the resources, so an invalid patch is found before any resource is applied.
+func preparePodTemplates(cpt *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, resources []interface{}) error {
+	for _, res := range resources {
+		template := podTemplateOf(res)
+		if template == nil {
+			continue
+		}
+		applyComponentSpec(cpt, template)
+		if err := applyPodTemplatePatch(cpt, template); err != nil {
+			return fmt.Errorf("%T %s: %v", res, res.(metav1.Object).GetName(), err)
+		}
+		applyClusterNodeSelector(cluster, template)
+	}
+	return nil
+}
+
+// applyPodTemplatePatch applies the strategic merge patch of the RbdComponent to the pod template.
+func applyPodTemplatePatch(cpt *rainbondv1alpha1.RbdComponent, template *corev1.PodTemplateSpec) error {
+	if cpt.Spec.PodTemplatePatch == nil || len(cpt.Spec.PodTemplatePatch.Raw) == 0 {
+		return nil
+	}
+
+	original, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("marshal pod template: %v", err)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, cpt.Spec.PodTemplatePatch.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return fmt.Errorf("apply strategic merge patch: %v", err)
+	}
+	newTemplate := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(patched, &newTemplate); err != nil {
+		return fmt.Errorf("unmarshal patched pod template: %v", err)
+	}
+	*template = newTemplate
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package rbdcomponent
+
+import (
+	"testing"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestApplyComponentSpec(t *testing.T) {
+	cpt := &rainbondv1alpha1.RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-chaos"},
+		Spec: rainbondv1alpha1.RbdComponentSpec{
+			NodeSelector: map[string]string{"node-role.rainbond.io/build": "true"},
+			Tolerations: []corev1.Toleration{
+				{Key: "build", Effect: corev1.TaintEffectNoSchedule},
+			},
+			PriorityClassName: "system-cluster-critical",
+			Resources: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+			Env: []corev1.EnvVar{
+				{Name: "CACHE_DIR", Value: "/data/cache"},
+				{Name: "FOO", Value: "bar"},
+			},
+		},
+	}
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/hostname": "node1"},
+			Tolerations: []corev1.Toleration{
+				{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+			},
+			Containers: []corev1.Container{
+				{Name: "sidecar"},
+				{
+					Name: "rbd-chaos",
+					Env: []corev1.EnvVar{
+						{Name: "SOURCE_DIR", Value: "/cache/source"},
+						{Name: "CACHE_DIR", Value: "/cache"},
+					},
+				},
+			},
+		},
+	}
+
+	applyComponentSpec(cpt, template)
+
+	spec := template.Spec
+	if spec.NodeSelector["node-role.rainbond.io/build"] != "true" || len(spec.NodeSelector) != 1 {
+		t.Errorf("Expected node selector %v, but got %v", cpt.Spec.NodeSelector, spec.NodeSelector)
+	}
+	if len(spec.Tolerations) != 2 {
+		t.Errorf("Expected 2 tolerations, but got %d", len(spec.Tolerations))
+	}
+	if spec.PriorityClassName != "system-cluster-critical" {
+		t.Errorf("Expected priority class name system-cluster-critical, but got %s", spec.PriorityClassName)
+	}
+	if len(spec.Containers[0].Env) != 0 || spec.Containers[0].Resources.Limits != nil {
+		t.Errorf("Expected sidecar to be left untouched")
+	}
+	main := spec.Containers[1]
+	if main.Resources.Limits.Memory().String() != "1Gi" {
+		t.Errorf("Expected memory limit 1Gi, but got %s", main.Resources.Limits.Memory().String())
+	}
+	want := map[string]string{"SOURCE_DIR": "/cache/source", "CACHE_DIR": "/data/cache", "FOO": "bar"}
+	if len(main.Env) != len(want) {
+		t.Errorf("Expected %d env, but got %d", len(want), len(main.Env))
+	}
+	for _, env := range main.Env {
+		if want[env.Name] != env.Value {
+			t.Errorf("Expected env %s=%s, but got %s", env.Name, want[env.Name], env.Value)
+		}
+	}
+}
+
+func TestApplyPodTemplatePatch(t *testing.T) {
+	cpt := &rainbondv1alpha1.RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-api"},
+		Spec: rainbondv1alpha1.RbdComponentSpec{
+			PodTemplatePatch: &runtime.RawExtension{
+				Raw: []byte(`{"spec":{"containers":[{"name":"rbd-api","env":[{"name":"FOO","value":"bar"}]},{"name":"proxy","image":"envoy"}]}}`),
+			},
+		},
+	}
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "rbd-api",
+					Image: "goodrain.me/rbd-api",
+					Env: []corev1.EnvVar{
+						{Name: "EX_DOMAIN", Value: "foo.bar"},
+					},
+				},
+			},
+		},
+	}
+
+	if err := applyPodTemplatePatch(cpt, template); err != nil {
+		t.Fatalf("apply pod template patch: %v", err)
+	}
+
+	containers := template.Spec.Containers
+	if len(containers) != 2 {
+		t.Fatalf("Expected 2 containers, but got %d", len(containers))
+	}
+	var api *corev1.Container
+	for i := range containers {
+		if containers[i].Name == "rbd-api" {
+			api = &containers[i]
+		}
+	}
+	if api == nil {
+		t.Fatalf("Expected container rbd-api to be kept")
+	}
+	if api.Image != "goodrain.me/rbd-api" {
+		t.Errorf("Expected image goodrain.me/rbd-api, but got %s", api.Image)
+	}
+	if len(api.Env) != 2 {
+		t.Errorf("Expected 2 env, but got %d", len(api.Env))
+	}
+
+	cpt.Spec.PodTemplatePatch.Raw = []byte(`{"spec":`)
+	if err := applyPodTemplatePatch(cpt, template); err == nil {
+		t.Errorf("Expected error for malformed patch")
+	}
+}
+
+func TestPreparePodTemplates(t *testing.T) {
+	newDeployment := func(name string) *appv1.Deployment {
+		deploy := &appv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "rbd-api", Image: "goodrain.me/rbd-api"}}
+		return deploy
+	}
+	cluster := &rainbondv1alpha1.RainbondCluster{}
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr bool
+	}{
+		{
+			name:  "patched",
+			patch: `{"spec":{"containers":[{"name":"rbd-api","env":[{"name":"FOO","value":"bar"}]}]}}`,
+		},
+		{
+			name:    "invalid patch",
+			patch:   `{"spec":{"containers":[{"name":"rbd-api","ports":[{"containerPort":"80"}]}]}}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cpt := &rainbondv1alpha1.RbdComponent{
+				ObjectMeta: metav1.ObjectMeta{Name: "rbd-api"},
+				Spec:       rainbondv1alpha1.RbdComponentSpec{PodTemplatePatch: &runtime.RawExtension{Raw: []byte(tc.patch)}},
+			}
+			resources := []interface{}{newDeployment("a"), nil, &corev1.Service{}, newDeployment("b")}
+			err := preparePodTemplates(cpt, cluster, resources)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			for _, res := range []*appv1.Deployment{resources[0].(*appv1.Deployment), resources[3].(*appv1.Deployment)} {
+				if env := res.Spec.Template.Spec.Containers[0].Env; len(env) == 0 || env[len(env)-1].Name != "FOO" {
+					t.Errorf("Expected deployment %s to be patched, but got %v", res.Name, env)
+				}
+			}
+		})
+	}
+}
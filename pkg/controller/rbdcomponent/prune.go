@@ -0,0 +1,80 @@
+package rbdcomponent
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+// PruneOrphans enables deleting the resources owned by a RbdComponent that its handler no longer produces.
+// If it is false, the orphans are only logged.
+var PruneOrphans bool
+
+// prunableResourceLists returns the lists of the owned resource types that are pruned.
+// Secrets are left out, because the handlers leave the generated secrets out of Resources() once they exist,
+// and so are persistent volume claims, which hold data.
+func prunableResourceLists() []runtime.Object {
+	return []runtime.Object{
+		&appv1.DaemonSetList{},
+		&appv1.StatefulSetList{},
+		&appv1.DeploymentList{},
+		&corev1.ServiceList{},
+		newIngressList(),
+		&corev1.ConfigMapList{},
+		&policyv1beta1.PodDisruptionBudgetList{},
+	}
+}
+
+// pruneOrphans deletes the resources controlled by the RbdComponent that are not in resources.
+func (r *ReconcileRbdComponent) pruneOrphans(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent, resources []interface{}) error {
+	desired := make(map[string]bool)
+	for _, res := range resources {
+		if obj, ok := res.(metav1.Object); ok {
+			desired[resourceKey(res, obj.GetName())] = true
+		}
+	}
+
+	for _, list := range prunableResourceLists() {
+		if err := r.client.List(ctx, list, client.InNamespace(cpt.Namespace)); err != nil {
+			return fmt.Errorf("list %T: %v", list, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return fmt.Errorf("extract %T: %v", list, err)
+		}
+		for _, item := range items {
+			obj := item.(metav1.Object)
+			if !metav1.IsControlledBy(obj, cpt) || obj.GetDeletionTimestamp() != nil || desired[resourceKey(item, obj.GetName())] {
+				continue
+			}
+			if !PruneOrphans {
+				log.Info("Orphaned resource, not pruned", "Kind", fmt.Sprintf("%T", item), "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+				continue
+			}
+			log.Info("Prune orphaned resource", "Kind", fmt.Sprintf("%T", item), "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			if err := r.client.Delete(ctx, item); err != nil && !k8sErrors.IsNotFound(err) {
+				return fmt.Errorf("delete %T %s: %v", item, obj.GetName(), err)
+			}
+			r.recorder.Eventf(cpt, corev1.EventTypeNormal, "Pruned", "Deleted %T %s, which is no longer desired", item, obj.GetName())
+		}
+	}
+	return nil
+}
+
+func resourceKey(obj interface{}, name string) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return fmt.Sprintf("%s/%s", u.GroupVersionKind(), name)
+	}
+	return fmt.Sprintf("%T/%s", obj, name)
+}
@@ -0,0 +1,88 @@
+package rbdcomponent
+
+import (
+	"context"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPruneOrphans(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, appv1.AddToScheme, extensions.AddToScheme, policyv1beta1.AddToScheme, rainbondv1alpha1.SchemeBuilder.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cpt := &rainbondv1alpha1.RbdComponent{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rbd-hub", UID: "uid"}}
+	owned := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace:       "rbd-system",
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cpt, rainbondv1alpha1.SchemeGroupVersion.WithKind("RbdComponent"))},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		prune     bool
+		wantExist map[string]bool
+	}{
+		{
+			name: "dry run",
+			wantExist: map[string]bool{
+				"rbd-hub": true, "rbd-hub-old": true, "unowned": true, "rbd-hub-secret": true,
+			},
+		},
+		{
+			name:  "prune",
+			prune: true,
+			wantExist: map[string]bool{
+				"rbd-hub": true, "rbd-hub-old": false, "unowned": true, "rbd-hub-secret": true,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &corev1.Service{ObjectMeta: owned("rbd-hub")}
+			cli := fake.NewFakeClientWithScheme(scheme,
+				cpt,
+				svc,
+				&extensions.Ingress{ObjectMeta: owned("rbd-hub-old")},
+				&extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "unowned"}},
+				&corev1.Secret{ObjectMeta: owned("rbd-hub-secret")},
+			)
+			r := &ReconcileRbdComponent{client: cli, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+
+			PruneOrphans = tc.prune
+			defer func() { PruneOrphans = false }()
+			// the secret is left out on purpose, as the handlers do once it exists
+			if err := r.pruneOrphans(context.Background(), cpt, []interface{}{svc, nil}); err != nil {
+				t.Fatal(err)
+			}
+
+			objs := map[string]runtime.Object{
+				"rbd-hub":        &corev1.Service{},
+				"rbd-hub-old":    &extensions.Ingress{},
+				"unowned":        &extensions.Ingress{},
+				"rbd-hub-secret": &corev1.Secret{},
+			}
+			for name, obj := range objs {
+				exist := cli.Get(context.Background(), types.NamespacedName{Namespace: "rbd-system", Name: name}, obj) == nil
+				if exist != tc.wantExist[name] {
+					t.Errorf("Expected %s exists %v, but got %v", name, tc.wantExist[name], exist)
+				}
+			}
+		})
+	}
+}
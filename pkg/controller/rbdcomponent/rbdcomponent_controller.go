@@ -4,30 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
 
-	"github.com/go-logr/logr"
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	extensions "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
-	"github.com/goodrain/rainbond-operator/pkg/util/constants"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
 )
 
 var log = logf.Log.WithName("controller_rbdcomponent")
@@ -44,6 +49,31 @@ func AddHandlerFunc(name string, fn handlerFunc) {
 	handlerFuncs[name] = fn
 }
 
+// IsSupported checks if there is a handler for the RbdComponent with the given name.
+func IsSupported(name string) bool {
+	_, ok := handlerFuncs[name]
+	return ok
+}
+
+// SupportedComponents returns the sorted names of all supported RbdComponents.
+func SupportedComponents() []string {
+	var names []string
+	for name := range handlerFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handlerFuncOf returns the handlerFunc of the RbdComponent, by its type for template components, by its name otherwise.
+func handlerFuncOf(cpt *rainbondv1alpha1.RbdComponent) (handlerFunc, bool) {
+	if cpt.Spec.Type == rainbondv1alpha1.TemplateComponentType {
+		return chandler.NewTemplate, true
+	}
+	fn, ok := handlerFuncs[cpt.Name]
+	return fn, ok
+}
+
 // Add creates a new RbdComponent Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
@@ -52,7 +82,11 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileRbdComponent{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileRbdComponent{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor("rbdcomponent-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -74,10 +108,12 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		&appv1.StatefulSet{},
 		&appv1.Deployment{},
 		&corev1.Service{},
-		&extensions.Ingress{},
+		newIngress(),
 		&corev1.Secret{},
 		&corev1.ConfigMap{},
 		&corev1.PersistentVolumeClaim{},
+		&batchv1.Job{},
+		&policyv1beta1.PodDisruptionBudget{},
 	}
 
 	for _, t := range secondaryResourceTypes {
@@ -90,9 +126,80 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		}
 	}
 
+	// Pods are not owned by RbdComponent directly, keep the status of RbdComponent live with them.
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: podToRbdComponent})
+	if err != nil {
+		return err
+	}
+
+	// The manifests of template components are in ConfigMaps that they do not own.
+	cli := mgr.GetClient()
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			cpts := &rainbondv1alpha1.RbdComponentList{}
+			if err := cli.List(context.Background(), cpts, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cpt := range cpts.Items {
+				if cpt.Spec.Type == rainbondv1alpha1.TemplateComponentType && cpt.Spec.Template != nil && cpt.Spec.Template.ConfigMapName == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}})
+				}
+			}
+			return requests
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Pausing a RainbondCluster pauses all its components.
+	err = c.Watch(&source.Kind{Type: &rainbondv1alpha1.RainbondCluster{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			cpts := &rainbondv1alpha1.RbdComponentList{}
+			if err := cli.List(context.Background(), cpts, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cpt := range cpts.Items {
+				if cpt.RainbondClusterName() == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}})
+				}
+			}
+			return requests
+		}),
+	}, predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if rainbondv1alpha1.IsPaused(e.MetaOld) != rainbondv1alpha1.IsPaused(e.MetaNew) {
+				return true
+			}
+			oldCluster, ok := e.ObjectOld.(*rainbondv1alpha1.RainbondCluster)
+			newCluster, ok2 := e.ObjectNew.(*rainbondv1alpha1.RainbondCluster)
+			return ok && ok2 && nodesConflict(oldCluster) != nodesConflict(newCluster)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// nodesConflict returns why the nodes of the cluster conflict with another cluster, empty if they do not.
+func nodesConflict(cluster *rainbondv1alpha1.RainbondCluster) string {
+	if cluster.Status == nil {
+		return ""
+	}
+	condition := cluster.Status.GetCondition(rainbondv1alpha1.RainbondClusterConditionConfigValid)
+	if condition == nil || condition.Reason != rainbondv1alpha1.RainbondClusterReasonNodesConflict {
+		return ""
+	}
+	return condition.Message
+}
+
 // blank assignment to verify that ReconcileRbdComponent implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileRbdComponent{}
 
@@ -100,8 +207,9 @@ var _ reconcile.Reconciler = &ReconcileRbdComponent{}
 type ReconcileRbdComponent struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a RbdComponent object and makes changes based on the state read
@@ -110,52 +218,82 @@ type ReconcileRbdComponent struct {
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileRbdComponent) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Namespace", request.Namespace, "Name", request.Name)
-
+	start := time.Now()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Fetch the RbdComponent cpt
 	cpt := &rainbondv1alpha1.RbdComponent{}
-	err := r.client.Get(ctx, request.NamespacedName, cpt)
-	if err != nil {
+	if err := r.client.Get(ctx, request.NamespacedName, cpt); err != nil {
 		if k8sErrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			forgetComponent(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
+		observeReconcile(request.NamespacedName, time.Since(start), err)
 		return reconcile.Result{Requeue: true}, err
 	}
 
-	fn, ok := handlerFuncs[cpt.Name]
+	result, err := r.doReconcile(ctx, cpt)
+	observeReconcile(request.NamespacedName, time.Since(start), err)
+	return result, err
+}
+
+func (r *ReconcileRbdComponent) doReconcile(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Namespace", cpt.Namespace, "Name", cpt.Name)
+
+	if cpt.DeletionTimestamp != nil {
+		return r.finalize(ctx, cpt)
+	}
+	if !k8sutil.HasFinalizer(cpt, rainbondv1alpha1.Finalizer) {
+		controllerutil.AddFinalizer(cpt, rainbondv1alpha1.Finalizer)
+		if err := r.client.Update(ctx, cpt); err != nil {
+			return reconcile.Result{Requeue: true}, err
+		}
+	}
+
+	fn, ok := handlerFuncOf(cpt)
 	if !ok {
-		// TODO: report status, events
 		reqLogger.Info("Unsupported RbdComponent.")
+		r.recorder.Event(cpt, corev1.EventTypeWarning, "Unsupported", fmt.Sprintf("Unsupported rbdcomponent %s, supported: %s", cpt.Name, strings.Join(SupportedComponents(), ", ")))
 		return reconcile.Result{}, nil
 	}
 
 	cluster := &rainbondv1alpha1.RainbondCluster{}
-	if err := r.client.Get(ctx, types.NamespacedName{Namespace: cpt.Namespace, Name: constants.RainbondClusterName}, cluster); err != nil {
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.RainbondClusterName()}, cluster); err != nil {
 		reqLogger.Error(err, "failed to get rainbondcluster.")
-		cpt.Status = &rainbondv1alpha1.RbdComponentStatus{
-			Message: fmt.Sprintf("failed to get rainbondcluster: %v", err),
-			Reason:  "ErrGetRainbondCluster",
-		}
-		if err := k8sutil.UpdateCRStatus(r.client, cpt); err != nil {
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ErrGetRainbondCluster", "Failed to get rainbondcluster %s: %v", cpt.RainbondClusterName(), err)
+		if err := r.updateReason(cpt, "ErrGetRainbondCluster", fmt.Sprintf("failed to get rainbondcluster: %v", err)); err != nil {
 			reqLogger.Error(err, "update rbdcomponent status")
 		}
 		return reconcile.Result{RequeueAfter: 3 * time.Second}, err
 	}
-	pkg := &rainbondv1alpha1.RainbondPackage{}
-	if err := r.client.Get(ctx, types.NamespacedName{Namespace: cpt.Namespace, Name: constants.RainbondPackageName}, pkg); err != nil {
-		reqLogger.Error(err, "failed to get rainbondpackage.")
-		cpt.Status = &rainbondv1alpha1.RbdComponentStatus{
-			Message: fmt.Sprintf("failed to get rainbondpackage: %v", err),
-			Reason:  "ErrGetRainbondPackage",
+	if by := pausedBy(cpt, cluster); by != "" {
+		reqLogger.Info("Reconciliation paused", "By", by)
+		if err := r.pause(cpt, by); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+			return reconcile.Result{Requeue: true}, err
 		}
-		if err := k8sutil.UpdateCRStatus(r.client, cpt); err != nil {
+		// the change of the annotation triggers the next reconciliation
+		return reconcile.Result{}, nil
+	}
+	if conflict := nodesConflict(cluster); conflict != "" {
+		// the resources would take the host ports of the components of the other cluster
+		reqLogger.Info("Nodes of rainbondcluster conflict with another rainbondcluster", "Message", conflict)
+		if err := r.updateReason(cpt, rainbondv1alpha1.RainbondClusterReasonNodesConflict, conflict); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
+		// the change of the rainbondcluster triggers the next reconciliation
+		return reconcile.Result{}, nil
+	}
+	pkg, err := rbdutil.GetRainbondPackage(ctx, r.client, cluster)
+	if err != nil {
+		reqLogger.Error(err, "failed to get rainbondpackage.")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ErrGetRainbondPackage", "Failed to get rainbondpackage: %v", err)
+		if err := r.updateReason(cpt, "ErrGetRainbondPackage", fmt.Sprintf("failed to get rainbondpackage: %v", err)); err != nil {
 			reqLogger.Error(err, "update rbdcomponent status")
 		}
 		return reconcile.Result{RequeueAfter: 3 * time.Second}, err
@@ -173,14 +311,44 @@ func (r *ReconcileRbdComponent) Reconcile(request reconcile.Request) (reconcile.
 		return true
 	}
 	if !checkPrerequisites() {
+		r.recorder.Event(cpt, corev1.EventTypeNormal, "WaitingForPackage", "Waiting for the rainbondpackage to be completed")
+		return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
+	}
+
+	waiting, err := r.waitingDependencies(ctx, cpt)
+	if err != nil {
+		reqLogger.Info("error checking the dependencies", "err", err)
+		r.recorder.Event(cpt, corev1.EventTypeWarning, "DependencyCycle", err.Error())
+		if err := r.updateLastError(cpt, err); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
+		return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
+	}
+	if len(waiting) > 0 {
+		reqLogger.Info("waiting for dependencies", "DependsOn", waiting)
+		r.recorder.Eventf(cpt, corev1.EventTypeNormal, rainbondv1alpha1.RbdComponentWaitingForDependency, "Waiting for %s to be available", strings.Join(waiting, ", "))
+		if err := r.updateWaitingForDependency(cpt, waiting); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
 		return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
 	}
 
 	hdl := fn(ctx, r.client, cpt, cluster, pkg)
-	if err := hdl.Before(); err != nil {
-		// TODO: report events
+	if err := chandler.ValidateConfigs(hdl, cpt); err != nil {
+		reqLogger.Info("invalid configs", "msg", err.Error())
+		r.recorder.Event(cpt, corev1.EventTypeWarning, "InvalidConfigs", err.Error())
+		if err := r.updateReason(cpt, "InvalidConfigs", err.Error()); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
+		// The configs are invalid, wait for the RbdComponent to be changed.
+		return reconcile.Result{}, nil
+	}
+	err = hdl.Before()
+	observePrerequisites(types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}, chandler.IsIgnoreError(err))
+	if err != nil {
 		if chandler.IsIgnoreError(err) {
 			reqLogger.Info("checking the prerequisites", "msg", err.Error())
+			r.recorder.Event(cpt, corev1.EventTypeNormal, "WaitingForPrerequisites", err.Error())
 			return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
 		}
 		isSetV1beta1MetricsFlag := cpt.Annotations != nil && cpt.Annotations["v1beta1.metrics.k8s.io.exists"] == "true"
@@ -190,10 +358,31 @@ func (r *ReconcileRbdComponent) Reconcile(request reconcile.Request) (reconcile.
 			}
 		}
 		reqLogger.Info("error checking the prerequisites", "err", err)
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "PrerequisitesFailed", "Failed to check the prerequisites: %v", err)
+		if err := r.updateLastError(cpt, err); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
 		return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
 	}
 
-	resources := hdl.Resources()
+	resources, err := convertIngresses(hdl.Resources())
+	if err != nil {
+		reqLogger.Error(err, "failed to convert ingresses")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ErrConvertIngress", "Failed to convert ingresses to %s: %v", IngressVersion, err)
+		return reconcile.Result{}, err
+	}
+	if err := preparePodTemplates(cpt, cluster, resources); err != nil {
+		reqLogger.Error(err, "failed to apply pod template patch")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ErrPodTemplatePatch", "Failed to apply pod template patch: %v", err)
+		if err := r.updateReason(cpt, "ErrPodTemplatePatch", fmt.Sprintf("failed to apply pod template patch: %v", err)); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
+		}
+		// The patch is invalid, wait for the RbdComponent to be changed. None of the resources is applied.
+		return reconcile.Result{}, nil
+	}
+
+	resuming := isPausedCondition(cpt)
+	var conflicts, reverted []string
 	for _, res := range resources {
 		if res == nil {
 			continue
@@ -204,51 +393,100 @@ func (r *ReconcileRbdComponent) Reconcile(request reconcile.Request) (reconcile.
 			return reconcile.Result{Requeue: true}, err
 		}
 
-		// Check if the resource already exists, if not create a new one
-		reconcileResult, err := r.updateOrCreateResource(reqLogger, res.(runtime.Object), res.(metav1.Object))
-		if err != nil {
-			return reconcileResult, err
+		var drifted bool
+		if resuming {
+			// report what was changed by hand while the reconciliation was paused, and revert it
+			fields, err := r.drift(ctx, res)
+			if err != nil {
+				reqLogger.Error(err, "failed to compare resource", "Kind", fmt.Sprintf("%T", res), "Name", res.(metav1.Object).GetName())
+				return reconcile.Result{Requeue: true}, err
+			}
+			if len(fields) > 0 {
+				drifted = true
+				reverted = append(reverted, driftSummary(res, fields))
+			}
+		}
+
+		if cpt.ApplyMode() == rainbondv1alpha1.ApplyModeServerSideApply {
+			if err := k8sutil.ApplyResource(ctx, r.client, r.scheme, res.(runtime.Object)); err != nil {
+				if !k8sErrors.IsConflict(err) {
+					reqLogger.Error(err, "failed to apply resource")
+					r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ApplyFailed", "Failed to apply %T %s: %v", res, res.(metav1.Object).GetName(), err)
+					return reconcile.Result{}, err
+				}
+				// leave the fields to their managers, and report the conflict
+				meta := res.(metav1.Object)
+				conflicts = append(conflicts, fmt.Sprintf("%T %s: %v", res, meta.GetName(), err))
+				r.recorder.Eventf(cpt, corev1.EventTypeWarning, "ApplyConflict", "Conflicts applying %T %s: %v", res, meta.GetName(), err)
+				// the status of the component comes from the live object
+				if err := r.client.Get(ctx, types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()}, res.(runtime.Object)); err != nil {
+					reqLogger.Error(err, "failed to get resource")
+				}
+			}
+			continue
+		}
+
+		// Create the resource if it does not exist, or update it if it has changed
+		updateOrCreate := k8sutil.UpdateOrCreateResource
+		if drifted {
+			updateOrCreate = k8sutil.ForceUpdateOrCreateResource
+		}
+		if err := updateOrCreate(ctx, r.client, reqLogger, res.(runtime.Object), res.(metav1.Object)); err != nil {
+			r.recorder.Eventf(cpt, corev1.EventTypeWarning, "UpdateOrCreateFailed", "Failed to create or update %T %s: %v", res, res.(metav1.Object).GetName(), err)
+			return reconcile.Result{}, err
 		}
 	}
 
-	if err := hdl.After(); err != nil {
-		reqLogger.Error(err, "failed to execute after process")
-		return reconcile.Result{Requeue: true}, err
+	if resuming {
+		message := "nothing to revert"
+		if len(reverted) > 0 {
+			message = "reverting " + strings.Join(reverted, "; ")
+		}
+		reqLogger.Info("Reconciliation resumed", "Reverted", reverted)
+		r.recorder.Eventf(cpt, corev1.EventTypeNormal, "Resumed", "Reconciliation resumed, %s", message)
 	}
 
-	cpt.Status = generateRainbondComponentStatus(cpt, cluster, resources)
-	if err := r.client.Status().Update(ctx, cpt); err != nil {
-		reqLogger.Error(err, "Update RbdComponent status", "Name", cpt.Name)
+	if err := r.deleteReplacedWorkload(ctx, cpt, resources); err != nil {
+		reqLogger.Error(err, "failed to delete replaced workload")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete replaced workload: %v", err)
+		return reconcile.Result{Requeue: true}, err
+	}
+	if err := r.pruneOrphans(ctx, cpt, resources); err != nil {
+		reqLogger.Error(err, "failed to prune orphaned resources")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "PruneFailed", "Failed to prune orphaned resources: %v", err)
 		return reconcile.Result{Requeue: true}, err
 	}
 
-	return reconcile.Result{}, nil
-}
-
-func (r *ReconcileRbdComponent) updateOrCreateResource(reqLogger logr.Logger, obj runtime.Object, meta metav1.Object) (reconcile.Result, error) {
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: meta.GetName(), Namespace: meta.GetNamespace()}, obj)
-	if err != nil && k8sErrors.IsNotFound(err) {
-		reqLogger.Info(fmt.Sprintf("Creating a new %s", obj.GetObjectKind().GroupVersionKind().Kind), "Namespace", meta.GetNamespace(), "Name", meta.GetName())
-		err = r.client.Create(context.TODO(), obj)
-		if err != nil {
-			reqLogger.Error(err, "Failed to create new", obj.GetObjectKind(), "Namespace", meta.GetNamespace(), "Name", meta.GetName())
-			return reconcile.Result{}, err
+	if err := hdl.After(); err != nil {
+		reqLogger.Error(err, "failed to execute after process")
+		r.recorder.Eventf(cpt, corev1.EventTypeWarning, "AfterFailed", "Failed to execute after process: %v", err)
+		if err := r.updateLastError(cpt, err); err != nil {
+			reqLogger.Error(err, "update rbdcomponent status")
 		}
-		// daemonset created successfully - return and requeue TODO: why?
-		return reconcile.Result{Requeue: true}, nil
-	} else if err != nil {
-		reqLogger.Error(err, fmt.Sprintf("Failed to get %s", obj.GetObjectKind()))
-		return reconcile.Result{}, err
+		return reconcile.Result{Requeue: true}, err
 	}
 
-	// obj exsits, update
-	reqLogger.Info("Object exists.", "Kind", obj.GetObjectKind().GroupVersionKind().Kind, "Namespace", meta.GetNamespace(), "Name", meta.GetName())
-	if err := r.client.Update(context.TODO(), obj); err != nil {
-		reqLogger.Error(err, "Failed to update", "Kind", obj.GetObjectKind())
-		return reconcile.Result{}, err
+	pods, err := r.listWorkloadPods(ctx, cpt, resources)
+	if err != nil {
+		reqLogger.Error(err, "failed to list pods of rbdcomponent")
+		return reconcile.Result{Requeue: true}, err
+	}
+	cpt.Status = generateRainbondComponentStatus(cpt, resources, pods)
+	cpt.Status.SetCondition(conflictedCondition(conflicts))
+	if resuming {
+		cpt.Status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentPaused, false, "Resumed", ""))
+	}
+	var requeueAfter time.Duration
+	if checker, ok := hdl.(chandler.HealthChecker); ok {
+		r.checkHealth(cpt, checker)
+		requeueAfter = HealthCheckInterval
+	}
+	if err := r.client.Status().Update(ctx, cpt); err != nil {
+		reqLogger.Error(err, "Update RbdComponent status", "Name", cpt.Name)
+		return reconcile.Result{Requeue: true}, err
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func detectControllerType(ctrl interface{}) rainbondv1alpha1.ControllerType {
@@ -264,7 +502,7 @@ func detectControllerType(ctrl interface{}) rainbondv1alpha1.ControllerType {
 	return rainbondv1alpha1.ControllerTypeUnknown
 }
 
-func generateRainbondComponentStatus(cpt *rainbondv1alpha1.RbdComponent, cluster *rainbondv1alpha1.RainbondCluster, resources []interface{}) *rainbondv1alpha1.RbdComponentStatus {
+func generateRainbondComponentStatus(cpt *rainbondv1alpha1.RbdComponent, resources []interface{}, pods []corev1.Pod) *rainbondv1alpha1.RbdComponentStatus {
 	controllerType := rainbondv1alpha1.ControllerTypeUnknown
 	for _, res := range resources {
 		if res == nil {
@@ -277,13 +515,123 @@ func generateRainbondComponentStatus(cpt *rainbondv1alpha1.RbdComponent, cluster
 	}
 
 	status := &rainbondv1alpha1.RbdComponentStatus{
-		ControllerType: controllerType,
-		ControllerName: cpt.Name,
+		ControllerType:     controllerType,
+		ControllerName:     cpt.Name,
+		ObservedGeneration: cpt.Generation,
 	}
+	for _, res := range resources {
+		if res == nil {
+			continue
+		}
+		if detectControllerType(res) != rainbondv1alpha1.ControllerTypeUnknown {
+			status.Replicas, status.ReadyReplicas = workloadReplicas(res)
+			// the updated replicas of a spec the controller has not seen yet are the ones of the old spec
+			if workloadObserved(res) {
+				status.UpdatedReplicas = workloadUpdatedReplicas(res)
+			}
+			break
+		}
+	}
+	status.Pods = podStatuses(pods)
+	status.Image = runningImage(cpt, status.Pods)
+	if cpt.Status != nil {
+		// keep the last transition time of conditions
+		status.Conditions = cpt.Status.DeepCopy().Conditions
+	}
+	setComponentConditions(status)
 
 	return status
 }
 
+// workloadReplicas returns the desired and ready replicas of the given Deployment, StatefulSet or DaemonSet.
+func workloadReplicas(ctrl interface{}) (int32, int32) {
+	switch obj := ctrl.(type) {
+	case *appv1.Deployment:
+		if obj.Spec.Replicas != nil {
+			return *obj.Spec.Replicas, obj.Status.ReadyReplicas
+		}
+		return obj.Status.Replicas, obj.Status.ReadyReplicas
+	case *appv1.StatefulSet:
+		if obj.Spec.Replicas != nil {
+			return *obj.Spec.Replicas, obj.Status.ReadyReplicas
+		}
+		return obj.Status.Replicas, obj.Status.ReadyReplicas
+	case *appv1.DaemonSet:
+		return obj.Status.DesiredNumberScheduled, obj.Status.NumberReady
+	}
+	return 0, 0
+}
+
+// deleteReplacedWorkload deletes the DaemonSet or Deployment left behind after the replicas of the component changed
+// and the handler switched from one kind of workload to the other.
+func (r *ReconcileRbdComponent) deleteReplacedWorkload(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent, resources []interface{}) error {
+	for _, res := range resources {
+		var old runtime.Object
+		switch res.(type) {
+		case *appv1.Deployment:
+			old = &appv1.DaemonSet{}
+		case *appv1.DaemonSet:
+			old = &appv1.Deployment{}
+		default:
+			continue
+		}
+		meta := res.(metav1.Object)
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()}, old); err != nil {
+			if k8sErrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(old.(metav1.Object), cpt) {
+			continue
+		}
+		log.Info("Delete replaced workload", "Kind", fmt.Sprintf("%T", old), "Namespace", meta.GetNamespace(), "Name", meta.GetName())
+		if err := r.client.Delete(ctx, old); err != nil && !k8sErrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// finalize runs the cleanup of the handler before the RbdComponent is deleted.
+func (r *ReconcileRbdComponent) finalize(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Namespace", cpt.Namespace, "Name", cpt.Name)
+	if !k8sutil.HasFinalizer(cpt, rainbondv1alpha1.Finalizer) {
+		return reconcile.Result{}, nil
+	}
+
+	cluster := &rainbondv1alpha1.RainbondCluster{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.RainbondClusterName()}, cluster)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return reconcile.Result{Requeue: true}, err
+	}
+	fn, ok := handlerFuncOf(cpt)
+	if ok && err == nil {
+		// the package is not needed to clean up
+		pkg, _ := rbdutil.GetRainbondPackage(ctx, r.client, cluster)
+		if hdl, ok := fn(ctx, r.client, cpt, cluster, pkg).(chandler.CleanupHandler); ok {
+			if err := hdl.Cleanup(); err != nil {
+				if err == chandler.ErrCleanupInProgress {
+					reqLogger.Info("waiting for the cleanup of rbdcomponent")
+					return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
+				}
+				reqLogger.Error(err, "failed to clean up rbdcomponent")
+				r.recorder.Eventf(cpt, corev1.EventTypeWarning, "CleanupFailed", "Failed to clean up: %v", err)
+				if err := r.updateLastError(cpt, err); err != nil {
+					reqLogger.Error(err, "update rbdcomponent status")
+				}
+				return reconcile.Result{RequeueAfter: 3 * time.Second}, nil
+			}
+		}
+	}
+
+	controllerutil.RemoveFinalizer(cpt, rainbondv1alpha1.Finalizer)
+	if err := r.client.Update(ctx, cpt); err != nil {
+		return reconcile.Result{Requeue: true}, err
+	}
+	return reconcile.Result{}, nil
+}
+
 func checkPackageStatus(pkg *rainbondv1alpha1.RainbondPackage) error {
 	var packageCompleted bool
 	if pkg.Status != nil {
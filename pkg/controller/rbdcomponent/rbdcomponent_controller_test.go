@@ -0,0 +1,79 @@
+package rbdcomponent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileUnsupportedComponent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cpt := &rainbondv1alpha1.RbdComponent{}
+	cpt.Namespace = "rbd-system"
+	cpt.Name = "rbd-foobar"
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileRbdComponent{client: fake.NewFakeClientWithScheme(scheme, cpt), scheme: scheme, recorder: recorder}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		want := "Warning Unsupported"
+		if !strings.HasPrefix(event, want) {
+			t.Errorf("Expected %s, but got %s", want, event)
+		}
+	default:
+		t.Errorf("Expected an event, but got none")
+	}
+}
+
+func TestReconcileKeepsStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cpt := &rainbondv1alpha1.RbdComponent{}
+	cpt.Namespace = "rbd-system"
+	cpt.Name = "rbd-api"
+	cpt.Status = &rainbondv1alpha1.RbdComponentStatus{
+		ControllerType: rainbondv1alpha1.ControllerTypeDeployment,
+		ControllerName: cpt.Name,
+		Replicas:       1,
+		ReadyReplicas:  1,
+		Pods:           []rainbondv1alpha1.RbdComponentPodStatus{{Name: "rbd-api-0", Phase: "Ready"}},
+		Conditions:     []rainbondv1alpha1.RbdComponentCondition{{Type: rainbondv1alpha1.RbdComponentAvailable, Status: corev1.ConditionTrue}},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, cpt)
+	r := &ReconcileRbdComponent{client: cli, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+
+	// the rainbondcluster does not exist
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cpt.Namespace, Name: cpt.Name}}
+	if _, err := r.Reconcile(request); err == nil {
+		t.Fatal("Expected an error, but got nil")
+	}
+
+	got := &rainbondv1alpha1.RbdComponent{}
+	if err := cli.Get(context.Background(), request.NamespacedName, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Reason != "ErrGetRainbondCluster" {
+		t.Errorf("Expected %s, but got %s", "ErrGetRainbondCluster", got.Status.Reason)
+	}
+	if len(got.Status.Pods) != 1 || len(got.Status.Conditions) != 1 || got.Status.ReadyReplicas != 1 {
+		t.Errorf("Expected the status to be kept, but got %+v", got.Status)
+	}
+}
@@ -0,0 +1,266 @@
+package rbdcomponent
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rainbondv1beta1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+// RenderResult is the resources that the operator creates for a rainbondcluster.
+type RenderResult struct {
+	// Resources in the order they are created.
+	Resources []runtime.Object
+	// Skipped holds the reason of each component that nothing is rendered for.
+	Skipped map[string]string
+}
+
+// Render runs the handlers of the components against a fake client seeded with objs, and returns the resources
+// they create along with the claims of the cluster. objs must hold exactly one RainbondCluster, the RainbondPackage
+// and RbdComponents are generated as the installation does if they are missing. The nodes are selected from the status of the RainbondCluster. Generated secrets, such as database passwords, are random unless they are in objs.
+func Render(ctx context.Context, scheme *runtime.Scheme, objs ...runtime.Object) (*RenderResult, error) {
+	var cluster *rainbondv1alpha1.RainbondCluster
+	var pkg *rainbondv1alpha1.RainbondPackage
+	var cpts []*rainbondv1alpha1.RbdComponent
+	var others []runtime.Object
+	for _, obj := range objs {
+		obj, err := toV1alpha1(obj)
+		if err != nil {
+			return nil, err
+		}
+		switch o := obj.(type) {
+		case *rainbondv1alpha1.RainbondCluster:
+			if cluster != nil {
+				return nil, fmt.Errorf("more than one rainbondcluster: %s and %s", cluster.Name, o.Name)
+			}
+			cluster = o
+		case *rainbondv1alpha1.RainbondPackage:
+			pkg = o
+		case *rainbondv1alpha1.RbdComponent:
+			cpts = append(cpts, o)
+		default:
+			others = append(others, obj)
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("no rainbondcluster found")
+	}
+	if cluster.Status == nil {
+		// the handlers select the nodes from the status, which is left to objs
+		cluster.Status = &rainbondv1alpha1.RainbondClusterStatus{}
+	}
+	if pkg == nil || pkg.RainbondClusterName() != cluster.Name {
+		pkg = &rainbondv1alpha1.RainbondPackage{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cluster.Namespace, Name: cluster.Name},
+			Spec:       rainbondv1alpha1.RainbondPackageSpec{ClusterName: cluster.Name},
+		}
+	}
+
+	result := &RenderResult{Skipped: map[string]string{}}
+	components := map[string]*rainbondv1alpha1.RbdComponent{}
+	for _, cpt := range cpts {
+		if cpt.RainbondClusterName() != cluster.Name {
+			continue
+		}
+		if _, ok := handlerFuncOf(cpt); !ok {
+			result.Skipped[cpt.Name] = "unsupported rbdcomponent"
+			continue
+		}
+		components[cpt.Name] = cpt
+	}
+	installed := map[string]*rainbondv1alpha1.RbdComponent{}
+	for _, cpt := range rbdutil.InstallComponents(cluster) {
+		installed[cpt.Name] = cpt
+	}
+	for _, name := range SupportedComponents() {
+		if _, ok := components[name]; ok {
+			continue
+		}
+		if cpt, ok := installed[name]; ok {
+			components[name] = cpt
+			continue
+		}
+		components[name] = defaultComponent(cluster, name)
+	}
+	var names []string
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seeds := append([]runtime.Object{cluster, pkg}, others...)
+	for _, cpt := range components {
+		seeds = append(seeds, cpt)
+	}
+	cli := &recordingClient{Client: fake.NewFakeClientWithScheme(scheme, seeds...), scheme: scheme, index: map[string]int{}}
+
+	// the claims shared by the components, which are created by the rainbondcluster controller
+	for _, claim := range rbdutil.ClusterClaims(cluster) {
+		if err := controllerutil.SetControllerReference(cluster, claim, scheme); err != nil {
+			return nil, err
+		}
+		if err := cli.Create(ctx, claim); err != nil && !k8sErrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("create claim %s: %v", claim.Name, err)
+		}
+	}
+
+	// The handlers wait for the secrets created by other components, so render in passes until there is no progress.
+	pending := names
+	errs := map[string]error{}
+	for progress := true; progress; {
+		progress = false
+		var waiting []string
+		for _, name := range pending {
+			err := renderComponent(ctx, cli, scheme, components[name], cluster, pkg)
+			if err == nil {
+				progress = true
+				continue
+			}
+			if _, ok := err.(*invalidError); ok {
+				result.Skipped[name] = err.Error()
+				continue
+			}
+			errs[name] = err
+			waiting = append(waiting, name)
+		}
+		pending = waiting
+	}
+	for _, name := range pending {
+		result.Skipped[name] = errs[name].Error()
+	}
+
+	result.Resources = cli.objects
+	return result, nil
+}
+
+// invalidError is returned by renderComponent for components that will never be rendered.
+type invalidError struct {
+	error
+}
+
+// renderComponent creates the resources of the component as Reconcile does.
+func renderComponent(ctx context.Context, cli client.Client, scheme *runtime.Scheme, cpt *rainbondv1alpha1.RbdComponent,
+	cluster *rainbondv1alpha1.RainbondCluster, pkg *rainbondv1alpha1.RainbondPackage) error {
+	fn, _ := handlerFuncOf(cpt)
+	hdl := fn(ctx, cli, cpt, cluster, pkg)
+	if err := chandler.ValidateConfigs(hdl, cpt); err != nil {
+		return &invalidError{err}
+	}
+	if err := hdl.Before(); err != nil {
+		return err
+	}
+
+	resources, err := convertIngresses(hdl.Resources())
+	if err != nil {
+		return err
+	}
+	for _, res := range resources {
+		if res == nil {
+			continue
+		}
+		if template := podTemplateOf(res); template != nil {
+			applyComponentSpec(cpt, template)
+			if err := applyPodTemplatePatch(cpt, template); err != nil {
+				return &invalidError{fmt.Errorf("apply pod template patch: %v", err)}
+			}
+		}
+		if err := controllerutil.SetControllerReference(cpt, res.(metav1.Object), scheme); err != nil {
+			return err
+		}
+		if err := cli.Create(ctx, res.(runtime.Object)); err != nil {
+			if !k8sErrors.IsAlreadyExists(err) {
+				return fmt.Errorf("create %T %s: %v", res, res.(metav1.Object).GetName(), err)
+			}
+			if err := cli.Update(ctx, res.(runtime.Object)); err != nil {
+				return fmt.Errorf("update %T %s: %v", res, res.(metav1.Object).GetName(), err)
+			}
+		}
+	}
+
+	return hdl.After()
+}
+
+// defaultComponent returns the RbdComponent for the component that is neither given to Render nor created by the installation.
+func defaultComponent(cluster *rainbondv1alpha1.RainbondCluster, name string) *rainbondv1alpha1.RbdComponent {
+	return &rainbondv1alpha1.RbdComponent{
+		ObjectMeta: metav1.ObjectMeta{Namespace: cluster.Namespace, Name: name},
+		Spec: rainbondv1alpha1.RbdComponentSpec{
+			ClusterName: cluster.Name,
+			Image:       path.Join(rbdutil.GetImageRepository(cluster), name) + ":" + rbdutil.GetInstallVersion(cluster),
+		},
+	}
+}
+
+// toV1alpha1 converts the rainbond.io/v1beta1 objects to v1alpha1, which the handlers work with.
+func toV1alpha1(obj runtime.Object) (runtime.Object, error) {
+	var dst conversion.Convertible
+	switch obj.(type) {
+	case *rainbondv1beta1.RainbondCluster:
+		dst = &rainbondv1alpha1.RainbondCluster{}
+	case *rainbondv1beta1.RainbondPackage:
+		dst = &rainbondv1alpha1.RainbondPackage{}
+	case *rainbondv1beta1.RbdComponent:
+		dst = &rainbondv1alpha1.RbdComponent{}
+	default:
+		return obj, nil
+	}
+	if err := dst.ConvertFrom(obj.(conversion.Hub)); err != nil {
+		return nil, fmt.Errorf("convert %T: %v", obj, err)
+	}
+	return dst, nil
+}
+
+// recordingClient records the objects created or updated through it, the last write of each object wins.
+type recordingClient struct {
+	client.Client
+	scheme  *runtime.Scheme
+	objects []runtime.Object
+	index   map[string]int
+}
+
+func (c *recordingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj.DeepCopyObject(), opts...); err != nil {
+		return err
+	}
+	return c.record(obj)
+}
+
+func (c *recordingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj.DeepCopyObject(), opts...); err != nil {
+		return err
+	}
+	return c.record(obj)
+}
+
+func (c *recordingClient) record(obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	obj = obj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.(metav1.Object).SetResourceVersion("")
+
+	key := fmt.Sprintf("%s/%s/%s", gvk.Kind, obj.(metav1.Object).GetNamespace(), obj.(metav1.Object).GetName())
+	if i, ok := c.index[key]; ok {
+		c.objects[i] = obj
+		return nil
+	}
+	c.index[key] = len(c.objects)
+	c.objects = append(c.objects, obj)
+	return nil
+}
@@ -0,0 +1,117 @@
+package rbdcomponent
+
+import (
+	"context"
+	"testing"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	chandler "github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	kubeaggregatorv1beta1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1beta1"
+)
+
+func TestRender(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, kubeaggregatorv1beta1.AddToScheme, rainbondv1alpha1.SchemeBuilder.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "rbd-system", Name: name}
+	}
+	newCluster := func() *rainbondv1alpha1.RainbondCluster {
+		return &rainbondv1alpha1.RainbondCluster{ObjectMeta: objectMeta(rainbondv1alpha1.DefaultRainbondClusterName)}
+	}
+
+	tests := []struct {
+		name        string
+		objs        []runtime.Object
+		wantErr     bool
+		wantSkipped []string
+		want        map[string]bool
+		wantImages  map[string]string
+	}{
+		{
+			name:    "no rainbondcluster",
+			wantErr: true,
+		},
+		{
+			name: "rainbondcluster only",
+			objs: []runtime.Object{newCluster()},
+			want: map[string]bool{
+				"Secret/rbd-db":       true,
+				"StatefulSet/rbd-db":  true,
+				"DaemonSet/rbd-grctl": true,
+				"Ingress/rbd-api":     true,
+
+				"PersistentVolumeClaim/grdata": true,
+				"PersistentVolumeClaim/cache":  true,
+			},
+			wantImages: map[string]string{
+				"StatefulSet/rbd-db":   "goodrain.me/rbd-db:v5.1.9",
+				"StatefulSet/rbd-etcd": "registry.cn-hangzhou.aliyuncs.com/goodrain/etcd:v3.3.18",
+			},
+		},
+		{
+			name: "existing secret",
+			objs: []runtime.Object{newCluster(), &corev1.Secret{ObjectMeta: objectMeta(chandler.DBName)}},
+			want: map[string]bool{
+				"Secret/rbd-db":      false,
+				"StatefulSet/rbd-db": true,
+			},
+		},
+		{
+			name: "unsupported component",
+			objs: []runtime.Object{
+				newCluster(),
+				&rainbondv1alpha1.RbdComponent{ObjectMeta: objectMeta("rbd-foobar")},
+			},
+			wantSkipped: []string{"rbd-foobar"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Render(context.Background(), scheme, tc.objs...)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(result.Skipped) != len(tc.wantSkipped) {
+				t.Errorf("Expected skipped %v, but got %v", tc.wantSkipped, result.Skipped)
+			}
+			for _, name := range tc.wantSkipped {
+				if _, ok := result.Skipped[name]; !ok {
+					t.Errorf("Expected %s to be skipped, but got %v", name, result.Skipped)
+				}
+			}
+
+			rendered := map[string]bool{}
+			images := map[string]string{}
+			for _, obj := range result.Resources {
+				key := obj.GetObjectKind().GroupVersionKind().Kind + "/" + obj.(metav1.Object).GetName()
+				rendered[key] = true
+				if template := podTemplateOf(obj); template != nil {
+					images[key] = template.Spec.Containers[0].Image
+				}
+			}
+			for key, want := range tc.want {
+				if rendered[key] != want {
+					t.Errorf("Expected %s rendered %v, but got %v", key, want, rendered[key])
+				}
+			}
+			for key, want := range tc.wantImages {
+				if images[key] != want {
+					t.Errorf("Expected image %s of %s, but got %s", want, key, images[key])
+				}
+			}
+		})
+	}
+}
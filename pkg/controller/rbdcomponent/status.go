@@ -0,0 +1,268 @@
+package rbdcomponent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/k8sutil"
+)
+
+// failedWaitingReasons are the reasons of waiting containers that will not recover by themselves.
+var failedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// podToRbdComponent maps the pods created by the operator to the RbdComponent they belong to.
+var podToRbdComponent = handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	if labels["belongTo"] != "RainbondOperator" || labels["name"] == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: labels["name"]}},
+	}
+})
+
+// workloadSelector returns the label selector of the given Deployment, StatefulSet or DaemonSet, nil otherwise.
+func workloadSelector(ctrl interface{}) *metav1.LabelSelector {
+	switch obj := ctrl.(type) {
+	case *appv1.Deployment:
+		return obj.Spec.Selector
+	case *appv1.StatefulSet:
+		return obj.Spec.Selector
+	case *appv1.DaemonSet:
+		return obj.Spec.Selector
+	}
+	return nil
+}
+
+// workloadUpdatedReplicas returns the number of pods that have the desired template spec.
+func workloadUpdatedReplicas(ctrl interface{}) int32 {
+	switch obj := ctrl.(type) {
+	case *appv1.Deployment:
+		return obj.Status.UpdatedReplicas
+	case *appv1.StatefulSet:
+		return obj.Status.UpdatedReplicas
+	case *appv1.DaemonSet:
+		return obj.Status.UpdatedNumberScheduled
+	}
+	return 0
+}
+
+// workloadObserved checks if the controller of the workload has observed its latest spec.
+func workloadObserved(ctrl interface{}) bool {
+	switch obj := ctrl.(type) {
+	case *appv1.Deployment:
+		return obj.Status.ObservedGeneration >= obj.Generation
+	case *appv1.StatefulSet:
+		return obj.Status.ObservedGeneration >= obj.Generation
+	case *appv1.DaemonSet:
+		return obj.Status.ObservedGeneration >= obj.Generation
+	}
+	return true
+}
+
+// listWorkloadPods lists the pods selected by the workload of the RbdComponent.
+func (r *ReconcileRbdComponent) listWorkloadPods(ctx context.Context, cpt *rainbondv1alpha1.RbdComponent, resources []interface{}) ([]corev1.Pod, error) {
+	for _, res := range resources {
+		if res == nil {
+			continue
+		}
+		selector := workloadSelector(res)
+		if selector == nil {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("convert label selector: %v", err)
+		}
+		pods := &corev1.PodList{}
+		if err := r.client.List(ctx, pods, client.InNamespace(cpt.Namespace), client.MatchingLabelsSelector{Selector: sel}); err != nil {
+			return nil, fmt.Errorf("list pods: %v", err)
+		}
+		return pods.Items, nil
+	}
+	return nil, nil
+}
+
+func podStatuses(pods []corev1.Pod) []rainbondv1alpha1.RbdComponentPodStatus {
+	var statuses []rainbondv1alpha1.RbdComponentPodStatus
+	for _, pod := range pods {
+		podStatus := rainbondv1alpha1.RbdComponentPodStatus{
+			Name:    pod.Name,
+			Phase:   "NotReady", // default phase NotReady, util PodReady condition is true
+			HostIP:  pod.Status.HostIP,
+			Reason:  pod.Status.Reason,
+			Message: pod.Status.Message,
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				podStatus.Phase = "Ready"
+				break
+			}
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			containerStatus := rainbondv1alpha1.RbdComponentPodContainerStatus{
+				Name:        cs.Name,
+				ContainerID: cs.ContainerID,
+				Image:       cs.Image,
+				Ready:       cs.Ready,
+			}
+			if cs.State.Running != nil {
+				containerStatus.State = "Running"
+			}
+			if cs.State.Waiting != nil {
+				containerStatus.State = "Waiting"
+				containerStatus.Reason = cs.State.Waiting.Reason
+				containerStatus.Message = cs.State.Waiting.Message
+			}
+			if cs.State.Terminated != nil {
+				containerStatus.State = "Terminated"
+				containerStatus.Reason = cs.State.Terminated.Reason
+				containerStatus.Message = cs.State.Terminated.Message
+			}
+			if podStatus.Phase == "NotReady" && podStatus.Reason == "" && containerStatus.State != "Running" {
+				podStatus.Reason = containerStatus.Reason
+				podStatus.Message = containerStatus.Message
+			}
+			podStatus.ContainerStatuses = append(podStatus.ContainerStatuses, containerStatus)
+		}
+		statuses = append(statuses, podStatus)
+	}
+	return statuses
+}
+
+// runningImage returns the image of the main container that is running, ready pods first.
+func runningImage(cpt *rainbondv1alpha1.RbdComponent, pods []rainbondv1alpha1.RbdComponentPodStatus) string {
+	mainImage := func(pod rainbondv1alpha1.RbdComponentPodStatus) string {
+		var image string
+		for _, cs := range pod.ContainerStatuses {
+			if cs.State != "Running" {
+				continue
+			}
+			if cs.Name == cpt.Name {
+				return cs.Image
+			}
+			if image == "" {
+				image = cs.Image
+			}
+		}
+		return image
+	}
+
+	var image string
+	for _, pod := range pods {
+		img := mainImage(pod)
+		if img == "" {
+			continue
+		}
+		if pod.Phase == "Ready" {
+			return img
+		}
+		if image == "" {
+			image = img
+		}
+	}
+	return image
+}
+
+func newComponentCondition(conditionType rainbondv1alpha1.RbdComponentConditionType, ok bool, reason, message string) rainbondv1alpha1.RbdComponentCondition {
+	status := corev1.ConditionFalse
+	if ok {
+		status = corev1.ConditionTrue
+	}
+	return rainbondv1alpha1.RbdComponentCondition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// setComponentConditions computes the Available, Progressing and Degraded conditions from the other fields of the status.
+func setComponentConditions(status *rainbondv1alpha1.RbdComponentStatus) {
+	if status.ControllerType == rainbondv1alpha1.ControllerTypeUnknown {
+		status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentAvailable, status.LastError == "", "NoWorkload", ""))
+		status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentProgressing, false, "NoWorkload", ""))
+	} else {
+		if status.ReadyReplicas > 0 {
+			status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentAvailable, true, "MinimumReplicasAvailable", ""))
+		} else {
+			status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentAvailable, false, "NoReadyReplicas", "no ready replicas"))
+		}
+
+		if status.UpdatedReplicas < status.Replicas || status.ReadyReplicas < status.Replicas {
+			message := fmt.Sprintf("%d of %d replicas updated, %d ready", status.UpdatedReplicas, status.Replicas, status.ReadyReplicas)
+			status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentProgressing, true, "ReplicasUpdating", message))
+		} else {
+			status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentProgressing, false, "ReplicasUpToDate", ""))
+		}
+	}
+
+	if status.LastError != "" {
+		status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentDegraded, true, "ReconcileError", status.LastError))
+		return
+	}
+	for _, pod := range status.Pods {
+		for _, cs := range pod.ContainerStatuses {
+			if cs.State == "Waiting" && failedWaitingReasons[cs.Reason] {
+				message := fmt.Sprintf("container %s of pod %s: %s", cs.Name, pod.Name, cs.Message)
+				status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentDegraded, true, cs.Reason, message))
+				return
+			}
+		}
+	}
+	status.SetCondition(newComponentCondition(rainbondv1alpha1.RbdComponentDegraded, false, "", ""))
+}
+
+// conflictedCondition reports the conflicts of server-side apply with other field managers.
+func conflictedCondition(conflicts []string) rainbondv1alpha1.RbdComponentCondition {
+	if len(conflicts) == 0 {
+		return newComponentCondition(rainbondv1alpha1.RbdComponentConflicted, false, "", "")
+	}
+	return newComponentCondition(rainbondv1alpha1.RbdComponentConflicted, true, "ApplyConflict", strings.Join(conflicts, "; "))
+}
+
+// updateLastError records the error of the reconciliation in the status of the RbdComponent.
+func (r *ReconcileRbdComponent) updateLastError(cpt *rainbondv1alpha1.RbdComponent, err error) error {
+	status := copyStatus(cpt)
+	status.LastError = err.Error()
+	setComponentConditions(status)
+	cpt.Status = status
+	return k8sutil.UpdateCRStatus(r.client, cpt)
+}
+
+// updateReason records why the RbdComponent is not reconciled in its status, keeping the rest of the status.
+func (r *ReconcileRbdComponent) updateReason(cpt *rainbondv1alpha1.RbdComponent, reason, message string) error {
+	status := copyStatus(cpt)
+	status.Reason = reason
+	status.Message = message
+	cpt.Status = status
+	return k8sutil.UpdateCRStatus(r.client, cpt)
+}
+
+// copyStatus returns a copy of the status of the RbdComponent, or an empty one if it has none.
+func copyStatus(cpt *rainbondv1alpha1.RbdComponent) *rainbondv1alpha1.RbdComponentStatus {
+	if cpt.Status == nil {
+		return &rainbondv1alpha1.RbdComponentStatus{
+			ControllerType: rainbondv1alpha1.ControllerTypeUnknown,
+			ControllerName: cpt.Name,
+		}
+	}
+	return cpt.Status.DeepCopy()
+}
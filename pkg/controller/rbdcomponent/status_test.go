@@ -0,0 +1,146 @@
+package rbdcomponent
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+)
+
+func TestSetComponentConditions(t *testing.T) {
+	tests := []struct {
+		name                             string
+		status                           *rainbondv1alpha1.RbdComponentStatus
+		available, progressing, degraded corev1.ConditionStatus
+	}{
+		{
+			name: "running",
+			status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType:  rainbondv1alpha1.ControllerTypeDeployment,
+				Replicas:        2,
+				ReadyReplicas:   2,
+				UpdatedReplicas: 2,
+			},
+			available:   corev1.ConditionTrue,
+			progressing: corev1.ConditionFalse,
+			degraded:    corev1.ConditionFalse,
+		},
+		{
+			name: "rolling update",
+			status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType:  rainbondv1alpha1.ControllerTypeDeployment,
+				Replicas:        2,
+				ReadyReplicas:   1,
+				UpdatedReplicas: 1,
+			},
+			available:   corev1.ConditionTrue,
+			progressing: corev1.ConditionTrue,
+			degraded:    corev1.ConditionFalse,
+		},
+		{
+			name: "crash loop",
+			status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType:  rainbondv1alpha1.ControllerTypeDaemonSet,
+				Replicas:        1,
+				UpdatedReplicas: 1,
+				Pods: []rainbondv1alpha1.RbdComponentPodStatus{
+					{
+						Name:  "rbd-api-xxx",
+						Phase: "NotReady",
+						ContainerStatuses: []rainbondv1alpha1.RbdComponentPodContainerStatus{
+							{Name: "rbd-api", State: "Waiting", Reason: "CrashLoopBackOff"},
+						},
+					},
+				},
+			},
+			available:   corev1.ConditionFalse,
+			progressing: corev1.ConditionTrue,
+			degraded:    corev1.ConditionTrue,
+		},
+		{
+			name: "reconcile error",
+			status: &rainbondv1alpha1.RbdComponentStatus{
+				ControllerType: rainbondv1alpha1.ControllerTypeUnknown,
+				LastError:      "foobar",
+			},
+			available:   corev1.ConditionFalse,
+			progressing: corev1.ConditionFalse,
+			degraded:    corev1.ConditionTrue,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			setComponentConditions(tc.status)
+			want := map[rainbondv1alpha1.RbdComponentConditionType]corev1.ConditionStatus{
+				rainbondv1alpha1.RbdComponentAvailable:   tc.available,
+				rainbondv1alpha1.RbdComponentProgressing: tc.progressing,
+				rainbondv1alpha1.RbdComponentDegraded:    tc.degraded,
+			}
+			for conditionType, status := range want {
+				condition := tc.status.GetCondition(conditionType)
+				if condition == nil {
+					t.Errorf("Expected condition %s, but got nil", conditionType)
+					continue
+				}
+				if condition.Status != status {
+					t.Errorf("Expected %s %v, but got %v", conditionType, status, condition.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestRunningImage(t *testing.T) {
+	cpt := &rainbondv1alpha1.RbdComponent{ObjectMeta: metav1.ObjectMeta{Name: "rbd-api"}}
+	pods := podStatuses([]corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbd-api-new"},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "rbd-api", Image: "goodrain.me/rbd-api:v5.2", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbd-api-old"},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "sidecar", Image: "envoy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+					{Name: "rbd-api", Image: "goodrain.me/rbd-api:v5.1", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				},
+			},
+		},
+	})
+
+	if got := runningImage(cpt, pods); got != "goodrain.me/rbd-api:v5.1" {
+		t.Errorf("Expected %s, but got %s", "goodrain.me/rbd-api:v5.1", got)
+	}
+}
+
+func TestConflictedCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		conflicts []string
+		want      corev1.ConditionStatus
+	}{
+		{name: "no conflicts", want: corev1.ConditionFalse},
+		{name: "replicas managed by hpa", conflicts: []string{"*v1.Deployment rbd-api: conflict with \"hpa\": .spec.replicas"}, want: corev1.ConditionTrue},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			condition := conflictedCondition(tc.conflicts)
+			if condition.Status != tc.want {
+				t.Errorf("Expected %s, but got %s", tc.want, condition.Status)
+			}
+		})
+	}
+}
@@ -24,21 +24,8 @@ func NewClusterUsecaseImpl(cfg *option.Config, componentUsecase ComponentUseCase
 
 // Uninstall uninstall cluster reset cluster
 func (c *ClusterUsecaseImpl) UnInstall() error {
-	if err := c.cfg.RainbondKubeClient.RainbondV1alpha1().RbdComponents(c.cfg.Namespace).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "name!=rbd-nfs"}); err != nil {
-		log.Error(err, "delete component error")
-		return err
-	}
-	if err := c.cfg.RainbondKubeClient.RainbondV1alpha1().RbdComponents(c.cfg.Namespace).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "name=rbd-nfs"}); err != nil {
-		log.Error(err, "delete storage component error")
-		return err
-	}
-
-	if err := c.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondPackages(c.cfg.Namespace).Delete(c.cfg.Rainbondpackage, &metav1.DeleteOptions{}); err != nil {
-		if !k8sErrors.IsNotFound(err) {
-			return err
-		}
-	}
-
+	// the rainbondcluster controller deletes the components, storage components last, and the package
+	// before the rainbondcluster is gone.
 	if err := c.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondClusters(c.cfg.Namespace).Delete(c.cfg.ClusterName, &metav1.DeleteOptions{}); err != nil {
 		if !k8sErrors.IsNotFound(err) {
 			return err
@@ -75,6 +62,7 @@ func (c *ClusterUsecaseImpl) Status() (*model.ClusterStatus, error) {
 	}
 
 	status := c.handleStatus(clusterInfo, rainbondPackage, components)
+	status.Paused = rainbondv1alpha1.IsPaused(clusterInfo)
 
 	return &status, nil
 }
@@ -86,6 +74,7 @@ func (c *ClusterUsecaseImpl) Status() (*model.ClusterStatus, error) {
 // rbdcomponent cr means cluster stauts is installing or running
 // all rbdcomponent cr are running means cluster status is running
 // rbdcomponent cr has pod with status terminal means cluster status is uninstalling
+// the phase of rainbondcluster is used if it is set by the rainbondcluster controller
 func (c *ClusterUsecaseImpl) handleStatus(rainbondCluster *rainbondv1alpha1.RainbondCluster, rainbondPackage *rainbondv1alpha1.RainbondPackage, componentStatusList []*v1.RbdComponentStatus) model.ClusterStatus {
 	reqLogger := log.WithValues("Namespace", c.cfg.Namespace)
 
@@ -97,6 +86,10 @@ func (c *ClusterUsecaseImpl) handleStatus(rainbondCluster *rainbondv1alpha1.Rain
 		rainbondClusterStatus.FinalStatus = model.UnInstalling
 		return rainbondClusterStatus
 	}
+	if rainbondCluster != nil && rainbondCluster.Status != nil && rainbondCluster.Status.Phase != "" {
+		// the phase maintained by the rainbondcluster controller takes precedence.
+		return rainbondClusterStatus
+	}
 	if rainbondClusterStatus.FinalStatus == model.Waiting {
 		return rainbondClusterStatus
 	}
@@ -156,6 +149,16 @@ func (c *ClusterUsecaseImpl) handleRainbondClusterStatus(rainbondCluster *rainbo
 			NodeName: node.NodeName,
 		})
 	}
+	switch rainbondCluster.Status.Phase {
+	case rainbondv1alpha1.RainbondClusterSetting:
+		status.FinalStatus = model.Setting
+	case rainbondv1alpha1.RainbondClusterInstalling:
+		status.FinalStatus = model.Installing
+	case rainbondv1alpha1.RainbondClusterDegraded:
+		status.FinalStatus = model.Degraded
+	case rainbondv1alpha1.RainbondClusterRunning, rainbondv1alpha1.RainbondClusterUpgrading:
+		status.FinalStatus = model.Running
+	}
 	return status
 }
 
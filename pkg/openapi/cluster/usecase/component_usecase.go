@@ -11,15 +11,12 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	plabels "k8s.io/apimachinery/pkg/labels"
 
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var log = logf.Log.WithName("usecase_cluster")
 
-type rbdComponentStatusFromSubObject func(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error)
-
 // ComponentUseCase cluster componse case
 type ComponentUseCase interface { // TODO: loop call
 	Get(name string) (*v1.RbdComponentStatus, error)
@@ -43,7 +40,12 @@ func (cc *ComponentUsecaseImpl) Get(name string) (*v1.RbdComponentStatus, error)
 		return nil, err
 	}
 
-	return cc.typeRbdComponentStatus(component)
+	status, err := cc.typeRbdComponentStatus(component)
+	if err != nil {
+		return nil, err
+	}
+	status.Paused = isComponentPaused(component)
+	return status, nil
 }
 
 // List list
@@ -74,8 +76,9 @@ func (cc *ComponentUsecaseImpl) List(isInit bool) ([]*v1.RbdComponentStatus, err
 		if component.Status == nil {
 			// Initially, status may be nil
 			status = &v1.RbdComponentStatus{
-				Name:   component.Name,
-				Status: v1.ComponentStatusIniting,
+				Name:      component.Name,
+				Status:    v1.ComponentStatusIniting,
+				DependsOn: component.Spec.DependsOn,
 			}
 		} else {
 			status, err = cc.typeRbdComponentStatus(&component)
@@ -86,188 +89,95 @@ func (cc *ComponentUsecaseImpl) List(isInit bool) ([]*v1.RbdComponentStatus, err
 					Status:          v1.ComponentStatusFailed,
 					Message:         "系统异常，请联系社区帮助",
 					ISInitComponent: component.Spec.PriorityComponent,
+					DependsOn:       component.Spec.DependsOn,
 					Reason:          fmt.Sprintf("get RbdComponent:%s status error: %s", component.Name, err.Error()),
 				}
 			}
 		}
+		status.Paused = isComponentPaused(&component)
 		statues = append(statues, status)
 	}
 
 	return statues, nil
 }
 
-func (cc *ComponentUsecaseImpl) typeRbdComponentStatus(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error) {
-	reqLogger := log.WithValues("Namespace", cpn.Namespace, "Name", cpn.Name, "ControllerType", cpn.Status.ControllerType)
-	reqLogger.Info("Start getting RbdComponent associated controller")
-
-	k2fn := map[string]rbdComponentStatusFromSubObject{
-		rainbondv1alpha1.ControllerTypeDeployment.String():  cc.rbdComponentStatusFromDeployment,
-		rainbondv1alpha1.ControllerTypeStatefulSet.String(): cc.rbdComponentStatusFromStatefulSet,
-		rainbondv1alpha1.ControllerTypeDaemonSet.String():   cc.rbdComponentStatusFromDaemonSet,
-	}
-	fn, ok := k2fn[cpn.Status.ControllerType.String()]
-	if !ok {
-		return nil, fmt.Errorf("unsupportted controller type: %s", cpn.Status.ControllerType.String())
-	}
-
-	status, err := fn(cpn)
-	if err != nil {
-		log.Error(err, "get RbdComponent associated controller")
-		return nil, err
-	}
-
-	status.Status = v1.ComponentStatusCreating
-	if status.Replicas == status.ReadyReplicas && status.Replicas > 0 {
-		status.Status = v1.ComponentStatusRunning
-	}
-
-	for index, _ := range status.PodStatuses {
-		if status.PodStatuses[index].Phase == "NotReady" {
-			for _, container := range status.PodStatuses[index].ContainerStatuses {
-				if container.State != "Running" {
-					status.PodStatuses[index].Message = container.Message
-					status.PodStatuses[index].Reason = container.Reason
-					break
-				}
-			}
-		}
-	}
-
-	return status, nil
-}
-
-func (cc *ComponentUsecaseImpl) rbdComponentStatusFromDeployment(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error) {
-	reqLogger := log.WithValues("Namespace", cpn.Namespace, "Name", cpn.Name, "ControllerType", cpn.Status.ControllerType)
-	reqLogger.Info("Start getting RbdComponent associated deployment")
-
-	deploy, err := cc.cfg.KubeClient.AppsV1().Deployments(cpn.Namespace).Get(cpn.Status.ControllerName, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	status := &v1.RbdComponentStatus{
-		Name:            cpn.Name,
-		Replicas:        deploy.Status.Replicas,
-		ReadyReplicas:   deploy.Status.ReadyReplicas,
-		ISInitComponent: cpn.Spec.PriorityComponent,
-	}
-
-	labels := deploy.Spec.Template.Labels
-	podStatuses, err := cc.listPodStatues(deploy.Namespace, labels)
-	if err != nil {
-		reqLogger.Error(err, "List deployment associated pods", "labels", labels)
-	}
-	status.PodStatuses = podStatuses
-
-	return status, nil
-}
-
-func (cc *ComponentUsecaseImpl) rbdComponentStatusFromStatefulSet(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error) {
-	reqLogger := log.WithValues("Namespace", cpn.Namespace, "Name", cpn.Name, "ControllerType", cpn.Status.ControllerType)
-	reqLogger.Info("Start getting RbdComponent associated statefulset")
-
-	sts, err := cc.cfg.KubeClient.AppsV1().StatefulSets(cpn.Namespace).Get(cpn.Status.ControllerName, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+// isComponentPaused returns whether the reconciliation of the component is paused by its annotation,
+// or by the annotation of its cluster, which the rbdcomponent controller records with the Paused condition.
+func isComponentPaused(cpn *rainbondv1alpha1.RbdComponent) bool {
+	if rainbondv1alpha1.IsPaused(cpn) {
+		return true
 	}
-
-	status := &v1.RbdComponentStatus{
-		Name:            cpn.Name,
-		Replicas:        sts.Status.Replicas,
-		ReadyReplicas:   sts.Status.ReadyReplicas,
-		ISInitComponent: cpn.Spec.PriorityComponent,
+	if cpn.Status == nil {
+		return false
 	}
-	labels := sts.Spec.Template.Labels
-	podStatuses, err := cc.listPodStatues(sts.Namespace, labels)
-	if err != nil {
-		reqLogger.Error(err, "List deployment associated pods", "labels", labels)
-	}
-	status.PodStatuses = podStatuses
-
-	return status, nil
+	condition := cpn.Status.GetCondition(rainbondv1alpha1.RbdComponentPaused)
+	return condition != nil && condition.Status == corev1.ConditionTrue
 }
 
-func (cc *ComponentUsecaseImpl) rbdComponentStatusFromDaemonSet(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error) {
-	reqLogger := log.WithValues("Namespace", cpn.Namespace, "Name", cpn.Name, "ControllerType", cpn.Status.ControllerType)
-	reqLogger.Info("Start getting RbdComponent associated daemonset")
-
-	ds, err := cc.cfg.KubeClient.AppsV1().DaemonSets(cpn.Namespace).Get(cpn.Status.ControllerName, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+// typeRbdComponentStatus converts the status of RbdComponent, which is maintained by the rbdcomponent controller.
+func (cc *ComponentUsecaseImpl) typeRbdComponentStatus(cpn *rainbondv1alpha1.RbdComponent) (*v1.RbdComponentStatus, error) {
+	if cpn.Status == nil {
+		return nil, fmt.Errorf("status of RbdComponent %s not found", cpn.Name)
+	}
+	if cpn.Status.Reason == rainbondv1alpha1.RbdComponentWaitingForDependency {
+		return &v1.RbdComponentStatus{
+			Name:            cpn.Name,
+			Status:          v1.ComponentStatusWaiting,
+			Message:         cpn.Status.Message,
+			Reason:          cpn.Status.Reason,
+			ISInitComponent: cpn.Spec.PriorityComponent,
+			DependsOn:       cpn.Spec.DependsOn,
+		}, nil
+	}
+	switch cpn.Status.ControllerType {
+	case rainbondv1alpha1.ControllerTypeDeployment, rainbondv1alpha1.ControllerTypeStatefulSet, rainbondv1alpha1.ControllerTypeDaemonSet:
+	default:
+		return nil, fmt.Errorf("unsupportted controller type: %s", cpn.Status.ControllerType.String())
 	}
 
 	status := &v1.RbdComponentStatus{
 		Name:            cpn.Name,
-		Replicas:        ds.Status.DesiredNumberScheduled,
-		ReadyReplicas:   ds.Status.NumberAvailable,
+		Replicas:        cpn.Status.Replicas,
+		ReadyReplicas:   cpn.Status.ReadyReplicas,
 		ISInitComponent: cpn.Spec.PriorityComponent,
+		DependsOn:       cpn.Spec.DependsOn,
 	}
 
-	labels := ds.Spec.Template.Labels
-	podStatuses, err := cc.listPodStatues(ds.Namespace, labels)
-	if err != nil {
-		reqLogger.Error(err, "List deployment associated pods", "labels", labels)
-	}
-	status.PodStatuses = podStatuses
-
-	return status, nil
-}
-
-func (cc *ComponentUsecaseImpl) listPodStatues(namespace string, labels map[string]string) ([]v1.PodStatus, error) {
-	selector := plabels.SelectorFromSet(labels)
-	opts := metav1.ListOptions{
-		LabelSelector: selector.String(),
-	}
-	podList, err := cc.cfg.KubeClient.CoreV1().Pods(namespace).List(opts)
-	if err != nil {
-		return nil, err
+	status.Status = v1.ComponentStatusCreating
+	if status.Replicas == status.ReadyReplicas && status.Replicas > 0 {
+		status.Status = v1.ComponentStatusRunning
 	}
 
-	var podStatuses []v1.PodStatus
-	for _, pod := range podList.Items {
+	for _, pod := range cpn.Status.Pods {
 		podStatus := v1.PodStatus{
 			Name:    pod.Name,
-			Phase:   "NotReady", // default phase NotReady, util PodReady condition is true
-			HostIP:  pod.Status.HostIP,
-			Reason:  pod.Status.Reason,
-			Message: pod.Status.Message,
+			Phase:   pod.Phase,
+			HostIP:  pod.HostIP,
+			Reason:  pod.Reason,
+			Message: pod.Message,
 		}
-		for _, condition := range pod.Status.Conditions {
-			if condition.Type == corev1.PodReady && condition.Status == "True" {
-				podStatus.Phase = "Ready"
-				break
-			}
-		}
-		var containerStatuses []v1.PodContainerStatus
-		for _, cs := range pod.Status.ContainerStatuses {
+		for _, cs := range pod.ContainerStatuses {
 			containerStatus := v1.PodContainerStatus{
-				Image: cs.Image,
-				Ready: cs.Ready,
+				Image:   cs.Image,
+				Ready:   cs.Ready,
+				State:   cs.State,
+				Reason:  cs.Reason,
+				Message: cs.Message,
 			}
 			if cs.ContainerID != "" {
-				containerStatus.ContainerID = strings.Replace(cs.ContainerID, "docker://", "", -1)[0:8]
-			}
-
-			// TODO: move out
-			if cs.State.Running != nil {
-				containerStatus.State = "Running"
-			}
-			if cs.State.Waiting != nil {
-				containerStatus.State = "Waiting"
-				containerStatus.Reason = cs.State.Waiting.Reason
-				containerStatus.Message = cs.State.Waiting.Message
-			}
-			if cs.State.Terminated != nil {
-				containerStatus.State = "Terminated"
-				containerStatus.Reason = cs.State.Terminated.Reason
-				containerStatus.Message = cs.State.Terminated.Message
+				containerID := cs.ContainerID
+				if idx := strings.Index(containerID, "://"); idx >= 0 {
+					containerID = containerID[idx+3:]
+				}
+				if len(containerID) > 8 {
+					containerID = containerID[0:8]
+				}
+				containerStatus.ContainerID = containerID
 			}
-
-			containerStatuses = append(containerStatuses, containerStatus)
+			podStatus.ContainerStatuses = append(podStatus.ContainerStatuses, containerStatus)
 		}
-		podStatus.ContainerStatuses = containerStatuses
-		podStatuses = append(podStatuses, podStatus)
+		status.PodStatuses = append(status.PodStatuses, podStatus)
 	}
 
-	return podStatuses, nil
+	return status, nil
 }
@@ -15,6 +15,13 @@ import (
 	"k8s.io/apimachinery/pkg/util/uuid"
 )
 
+const (
+	imageHubSecretName = v1alpha1.ImageHubPasswordSecretName
+	regionDBSecretName = v1alpha1.RegionDBPasswordSecretName
+	uiDBSecretName     = v1alpha1.UIDBPasswordSecretName
+	passwordSecretKey  = v1alpha1.PasswordSecretKey
+)
+
 // GlobalConfigUseCaseImpl case
 type GlobalConfigUseCaseImpl struct {
 	cfg *option.Config
@@ -97,24 +104,26 @@ func (cc *GlobalConfigUseCaseImpl) parseRainbondClusterConfig(source *v1alpha1.R
 			Domain:    source.Spec.ImageHub.Domain,
 			Namespace: source.Spec.ImageHub.Namespace,
 			Username:  source.Spec.ImageHub.Username,
-			Password:  source.Spec.ImageHub.Password,
 		}
+		password, err := cc.getPassword(source.Spec.ImageHub.Password, source.Spec.ImageHub.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		clusterInfo.ImageHub.Password = password
 	}
 	if source.Spec.RegionDatabase != nil {
-		clusterInfo.RegionDatabase = model.Database{
-			Host:     source.Spec.RegionDatabase.Host,
-			Port:     source.Spec.RegionDatabase.Port,
-			Username: source.Spec.RegionDatabase.Username,
-			Password: source.Spec.RegionDatabase.Password,
+		db, err := cc.parseDatabase(source.Spec.RegionDatabase)
+		if err != nil {
+			return nil, err
 		}
+		clusterInfo.RegionDatabase = *db
 	}
 	if source.Spec.UIDatabase != nil {
-		clusterInfo.UIDatabase = model.Database{
-			Host:     source.Spec.UIDatabase.Host,
-			Port:     source.Spec.UIDatabase.Port,
-			Username: source.Spec.UIDatabase.Username,
-			Password: source.Spec.UIDatabase.Password,
+		db, err := cc.parseDatabase(source.Spec.UIDatabase)
+		if err != nil {
+			return nil, err
 		}
+		clusterInfo.UIDatabase = *db
 	}
 	if source.Spec.EtcdConfig != nil {
 		clusterInfo.EtcdConfig = model.EtcdConfig{
@@ -181,26 +190,30 @@ func (cc *GlobalConfigUseCaseImpl) formatRainbondClusterConfig(source *model.Glo
 		clusterInfo.Spec.ImageHub = &v1alpha1.ImageHub{
 			Domain:    source.ImageHub.Domain,
 			Username:  source.ImageHub.Username,
-			Password:  source.ImageHub.Password,
 			Namespace: source.ImageHub.Namespace,
 		}
+		if source.ImageHub.Password != "" {
+			ref, err := cc.updateOrCreatePasswordSecret(imageHubSecretName, source.ImageHub.Password)
+			if err != nil {
+				return nil, err
+			}
+			clusterInfo.Spec.ImageHub.PasswordSecretRef = ref
+		}
 	}
 
 	if source.RegionDatabase.Host != "" {
-		clusterInfo.Spec.RegionDatabase = &v1alpha1.Database{
-			Host:     source.RegionDatabase.Host,
-			Port:     source.RegionDatabase.Port,
-			Username: source.RegionDatabase.Username,
-			Password: source.RegionDatabase.Password,
+		db, err := cc.formatDatabase(regionDBSecretName, &source.RegionDatabase)
+		if err != nil {
+			return nil, err
 		}
+		clusterInfo.Spec.RegionDatabase = db
 	}
 	if source.UIDatabase.Host != "" {
-		clusterInfo.Spec.UIDatabase = &v1alpha1.Database{
-			Host:     source.UIDatabase.Host,
-			Port:     source.UIDatabase.Port,
-			Username: source.UIDatabase.Username,
-			Password: source.UIDatabase.Password,
+		db, err := cc.formatDatabase(uiDBSecretName, &source.UIDatabase)
+		if err != nil {
+			return nil, err
 		}
+		clusterInfo.Spec.UIDatabase = db
 	}
 	if len(source.EtcdConfig.Endpoints) > 0 {
 		clusterInfo.Spec.EtcdConfig = &v1alpha1.EtcdConfig{
@@ -260,6 +273,79 @@ func (cc *GlobalConfigUseCaseImpl) formatRainbondClusterConfig(source *model.Glo
 	return clusterInfo, nil
 }
 
+func (cc *GlobalConfigUseCaseImpl) parseDatabase(source *v1alpha1.Database) (*model.Database, error) {
+	password, err := cc.getPassword(source.Password, source.PasswordSecretRef)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Database{
+		Host:     source.Host,
+		Port:     source.Port,
+		Username: source.Username,
+		Password: password,
+	}, nil
+}
+
+func (cc *GlobalConfigUseCaseImpl) formatDatabase(secretName string, source *model.Database) (*v1alpha1.Database, error) {
+	db := &v1alpha1.Database{
+		Host:     source.Host,
+		Port:     source.Port,
+		Username: source.Username,
+	}
+	if source.Password != "" {
+		ref, err := cc.updateOrCreatePasswordSecret(secretName, source.Password)
+		if err != nil {
+			return nil, err
+		}
+		db.PasswordSecretRef = ref
+	}
+	return db, nil
+}
+
+// getPassword returns the password referenced by ref, or the deprecated plaintext one if there is no reference.
+func (cc *GlobalConfigUseCaseImpl) getPassword(password string, ref *corev1.SecretKeySelector) (string, error) {
+	if ref == nil {
+		return password, nil
+	}
+	secret, err := cc.cfg.KubeClient.CoreV1().Secrets(cc.cfg.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) && ref.Optional != nil && *ref.Optional {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(secret.Data[ref.Key]), nil
+}
+
+// updateOrCreatePasswordSecret stores the password in the secret with the given name, and returns the reference to it.
+func (cc *GlobalConfigUseCaseImpl) updateOrCreatePasswordSecret(name, password string) (*corev1.SecretKeySelector, error) {
+	ref := v1alpha1.PasswordSecretRef(name)
+	old, err := cc.cfg.KubeClient.CoreV1().Secrets(cc.cfg.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+		new := &corev1.Secret{}
+		new.SetName(name)
+		new.SetNamespace(cc.cfg.Namespace)
+		new.Data = map[string][]byte{
+			passwordSecretKey: []byte(password),
+		}
+		if _, err := cc.cfg.KubeClient.CoreV1().Secrets(cc.cfg.Namespace).Create(new); err != nil {
+			return nil, err
+		}
+		return ref, nil
+	}
+	if old.Data == nil {
+		old.Data = make(map[string][]byte)
+	}
+	old.Data[passwordSecretKey] = []byte(password)
+	if _, err := cc.cfg.KubeClient.CoreV1().Secrets(cc.cfg.Namespace).Update(old); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
 //TODO generate test case
 func (cc *GlobalConfigUseCaseImpl) updateOrCreateEtcdCertInfo(certInfo model.EtcdCertInfo) error {
 	old, err := cc.cfg.KubeClient.CoreV1().Secrets(cc.cfg.Namespace).Get(cc.cfg.EtcdSecretName, metav1.GetOptions{})
@@ -306,6 +392,9 @@ func (cc *GlobalConfigUseCaseImpl) Uninstall() error {
 	}
 	var nfscomponent *v1alpha1.RbdComponent
 	for i := range components.Items {
+		if components.Items[i].RainbondClusterName() != cc.cfg.ClusterName {
+			continue
+		}
 		if components.Items[i].Name == "rbd-nfs" {
 			nfscomponent = &components.Items[i]
 			continue
@@ -321,5 +410,5 @@ func (cc *GlobalConfigUseCaseImpl) Uninstall() error {
 		}
 	}
 
-	return cc.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondPackages(cc.cfg.Namespace).Delete("rainbondpackage", &metav1.DeleteOptions{})
+	return cc.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondPackages(cc.cfg.Namespace).Delete(cc.cfg.Rainbondpackage, &metav1.DeleteOptions{})
 }
@@ -10,14 +10,11 @@ import (
 	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
 	"github.com/goodrain/rainbond-operator/pkg/openapi/model"
 	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-var (
-	componentClaims []componentClaim
-)
-
 var (
 	// StepSetting          StepSetting
 	StepSetting = "step_setting"
@@ -48,62 +45,6 @@ var (
 
 // TODO fanyangyang use logrus
 
-type componentClaim struct {
-	namespace string
-	name      string
-	version   string
-	image     string
-	logLevel  string
-	Configs   map[string]string
-	isInit    bool
-}
-
-var rbdVersion = "V5.2-dev"
-
-// TODO: custom domain
-var existHubDomain = "registry.cn-hangzhou.aliyuncs.com/goodrain"
-
-func init() {
-	componentClaims = []componentClaim{
-		{name: "rbd-etcd", image: existHubDomain + "/etcd:v3.3.18", isInit: true},
-		{name: "rbd-gateway", image: existHubDomain + "/rbd-gateway:" + rbdVersion, isInit: true},
-		{name: "rbd-hub", image: existHubDomain + "/registry:2.6.2", isInit: true},
-		{name: "rbd-node", image: existHubDomain + "/rbd-node:" + rbdVersion, isInit: true},
-		{name: "rbd-nfs", image: existHubDomain + "/nfs-provisioner:v2.2.1-k8s1.12", isInit: true},
-		{name: "rbd-api", image: "goodrain.me/rbd-api:" + rbdVersion},
-		{name: "rbd-app-ui", image: "goodrain.me/rbd-app-ui:" + rbdVersion},
-		{name: "rbd-chaos", image: "goodrain.me/rbd-chaos:" + rbdVersion},
-		{name: "rbd-db", image: "goodrain.me/rbd-db:v5.1.9"},
-		{name: "rbd-dns", image: "goodrain.me/rbd-dns"},
-		{name: "rbd-eventlog", image: "goodrain.me/rbd-eventlog:" + rbdVersion},
-		{name: "rbd-monitor", image: "goodrain.me/rbd-monitor:" + rbdVersion},
-		{name: "rbd-mq", image: "goodrain.me/rbd-mq:" + rbdVersion},
-		{name: "rbd-worker", image: "goodrain.me/rbd-worker:" + rbdVersion},
-		{name: "rbd-webcli", image: "goodrain.me/rbd-webcli:" + rbdVersion},
-		{name: "rbd-repo", image: "goodrain.me/rbd-repo:6.16.0"},
-		{name: "metrics-server", image: "goodrain.me/metrics-server:v0.3.6"},
-	}
-}
-
-func parseComponentClaim(claim componentClaim) *v1alpha1.RbdComponent {
-	component := &v1alpha1.RbdComponent{}
-	component.Namespace = claim.namespace
-	component.Name = claim.name
-	component.Spec.Version = claim.version
-	component.Spec.Image = claim.image
-	component.Spec.Configs = claim.Configs
-	component.Spec.LogLevel = v1alpha1.ParseLogLevel(claim.logLevel)
-	component.Spec.Type = claim.name
-	labels := map[string]string{"name": claim.name}
-	log.Info(fmt.Sprintf("component %s labels:%+v", component.Name, component.Labels))
-	if claim.isInit {
-		component.Spec.PriorityComponent = true
-		labels["priorityComponent"] = "true"
-	}
-	component.Labels = labels
-	return component
-}
-
 const (
 	md5CheckStatusWait int32 = iota
 	md5CheckStatusProcess
@@ -134,7 +75,7 @@ func (ic *InstallUseCaseImpl) Install() error {
 		return err
 	}
 
-	return ic.createComponents(componentClaims...)
+	return ic.createComponents()
 }
 
 func (ic *InstallUseCaseImpl) initRainbondPackage() error {
@@ -146,7 +87,10 @@ func (ic *InstallUseCaseImpl) initRainbondPackage() error {
 			Name:      ic.cfg.Rainbondpackage,
 			Namespace: ic.cfg.Namespace,
 		},
-		Spec: v1alpha1.RainbondPackageSpec{PkgPath: ic.cfg.ArchiveFilePath},
+		Spec: v1alpha1.RainbondPackageSpec{
+			PkgPath:     ic.cfg.ArchiveFilePath,
+			ClusterName: ic.cfg.ClusterName,
+		},
 	}
 	_, err := ic.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondPackages(ic.cfg.Namespace).Create(pkg)
 	if err != nil {
@@ -165,12 +109,13 @@ func (ic *InstallUseCaseImpl) initResourceDep() error {
 	return nil
 }
 
-func (ic *InstallUseCaseImpl) createComponents(components ...componentClaim) error {
+func (ic *InstallUseCaseImpl) createComponents() error {
 	defer commonutil.TimeConsume(time.Now())
-	for _, rbdComponent := range components {
-		data := parseComponentClaim(rbdComponent)
-		// init component
-		data.Namespace = ic.cfg.Namespace
+	cluster, err := ic.cfg.RainbondKubeClient.RainbondV1alpha1().RainbondClusters(ic.cfg.Namespace).Get(ic.cfg.ClusterName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get rainbondcluster: %v", err)
+	}
+	for _, data := range rbdutil.InstallComponents(cluster) {
 		if _, err := ic.cfg.RainbondKubeClient.RainbondV1alpha1().RbdComponents(ic.cfg.Namespace).Create(data); err != nil {
 			return err
 		}
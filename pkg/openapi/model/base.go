@@ -13,6 +13,8 @@ const (
 	Installing GlobalStatus = "Installing"
 	//Running running status
 	Running GlobalStatus = "Running"
+	//Degraded degraded status, some components of the running cluster are not ready
+	Degraded GlobalStatus = "Degraded"
 	//UnInstalling uninstalling status
 	UnInstalling GlobalStatus = "UnInstalling"
 )
@@ -119,6 +119,8 @@ type StatusRes struct {
 type ClusterStatus struct {
 	FinalStatus GlobalStatus `json:"final_status"`
 	ClusterInfo ClusterInfo  `json:"clusterInfo"`
+	// Paused is true if the reconciliation of the cluster and its components is paused.
+	Paused bool `json:"paused"`
 }
 
 // ClusterInfo cluster info used for config
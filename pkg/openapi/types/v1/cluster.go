@@ -14,6 +14,8 @@ const (
 	ComponentStatusTerminating = "Terminating" // TODO fanyangyang have not found this case
 	// ComponentStatusFailed failed
 	ComponentStatusFailed = "Failed"
+	// ComponentStatusWaiting waiting for the components it depends on
+	ComponentStatusWaiting = "Waiting"
 )
 
 // RbdComponentStatus rainbond component status
@@ -32,6 +34,10 @@ type RbdComponentStatus struct {
 	Message         string          `json:"message"`
 	Reason          string          `json:"reason"`
 	ISInitComponent bool            `json:"isInitComponent"`
+	// DependsOn is the names of the components that have to be available before the component is created.
+	DependsOn []string `json:"dependsOn"`
+	// Paused is true if the reconciliation of the component is paused, by the component or the cluster.
+	Paused bool `json:"paused"`
 
 	PodStatuses []PodStatus `json:"podStatus"`
 }
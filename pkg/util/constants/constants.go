@@ -1,22 +1,21 @@
 package constants
 
 const (
-	//Namespace namespace
-	Namespace = "rbd-system"
 	// DefImageRepositoryDomain is the default domain name of the mirror repository that Rainbond is installed.
 	DefImageRepositoryDomain = "goodrain.me"
 	//DefInstallPkgDestPath  Default destination path of the installation package extraction.
 	DefInstallPkgDestPath = "/tmp/DefInstallPkgDestPath"
-	//RainbondClusterName rainbond cluster resource name
-	RainbondClusterName = "rainbondcluster"
-	//RainbondPackageName rainbond package resource name
-	RainbondPackageName = "rainbondpackage"
 	//DefStorageClass -
 	DefStorageClass = "rbd-nfs"
+	// LegacyNamespace is the namespace that Rainbond was installed in before the regions were isolated by namespace.
+	// The cluster-scoped resources and the hostpath data of the region in it keep their names.
+	LegacyNamespace = "rbd-system"
 	//DefImageRepository -
 	DefImageRepository = "goodrain.me"
 	//GrDataPVC -
 	GrDataPVC = "grdata"
 	// CachePVC
 	CachePVC = "cache"
+	// DefInstallVersion is the version of Rainbond installed if the rainbondcluster does not specify one.
+	DefInstallVersion = "V5.2-dev"
 )
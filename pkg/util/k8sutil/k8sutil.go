@@ -2,29 +2,65 @@ package k8sutil
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
+// SpecHashAnnotation is the annotation that holds the hash of the desired state of an object managed by the operator.
+const SpecHashAnnotation = "rainbond.io/spec-hash"
+
+// UpdateOrCreateResource creates obj if it does not exist, or updates it if its desired state has changed since the last update.
+// On return, obj holds the state of the object in the API server.
 func UpdateOrCreateResource(ctx context.Context, cli client.Client, reqLogger logr.Logger, obj runtime.Object, meta metav1.Object) error {
-	err := cli.Get(ctx, types.NamespacedName{Name: meta.GetName(), Namespace: meta.GetNamespace()}, obj)
+	return updateOrCreateResource(ctx, cli, reqLogger, obj, meta, false)
+}
+
+// ForceUpdateOrCreateResource is UpdateOrCreateResource, but updates obj even if its desired state has not changed,
+// which reverts the changes made to it by others since the last update.
+func ForceUpdateOrCreateResource(ctx context.Context, cli client.Client, reqLogger logr.Logger, obj runtime.Object, meta metav1.Object) error {
+	return updateOrCreateResource(ctx, cli, reqLogger, obj, meta, true)
+}
+
+func updateOrCreateResource(ctx context.Context, cli client.Client, reqLogger logr.Logger, obj runtime.Object, meta metav1.Object, force bool) error {
+	hash, err := SpecHash(obj)
+	if err != nil {
+		return fmt.Errorf("hash %s: %v", obj.GetObjectKind().GroupVersionKind().Kind, err)
+	}
+	annotations := make(map[string]string, len(meta.GetAnnotations())+1)
+	for k, v := range meta.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[SpecHashAnnotation] = hash
+	meta.SetAnnotations(annotations)
+	desired := obj.DeepCopyObject()
+
+	err = cli.Get(ctx, types.NamespacedName{Name: meta.GetName(), Namespace: meta.GetNamespace()}, obj)
 	if err != nil {
 		if !errors.IsNotFound(err) {
 			reqLogger.Error(err, fmt.Sprintf("Failed to get %s", obj.GetObjectKind()))
 			return err
 		}
+		setObject(obj, desired)
 		reqLogger.Info("Creating a new", obj.GetObjectKind().GroupVersionKind().Kind, "Namespace", meta.GetNamespace(), "Name", meta.GetName())
 		err = cli.Create(ctx, obj)
 		if err != nil {
@@ -34,7 +70,16 @@ func UpdateOrCreateResource(ctx context.Context, cli client.Client, reqLogger lo
 		return nil
 	}
 
+	if !force && meta.GetAnnotations()[SpecHashAnnotation] == hash {
+		// nothing changed
+		return nil
+	}
+
 	// obj exsits, update
+	live := obj.DeepCopyObject()
+	setObject(obj, desired)
+	meta.SetResourceVersion(live.(metav1.Object).GetResourceVersion())
+	preserveDefaultedFields(obj, live)
 	reqLogger.Info(fmt.Sprintf("Update %s", obj.GetObjectKind().GroupVersionKind().Kind), "Namespace", meta.GetNamespace(), "Name", meta.GetName())
 	if err := cli.Update(ctx, obj); err != nil {
 		reqLogger.Error(err, "Failed to update ", obj.GetObjectKind())
@@ -44,6 +89,190 @@ func UpdateOrCreateResource(ctx context.Context, cli client.Client, reqLogger lo
 	return nil
 }
 
+// FieldManager is the field manager of the operator for server-side apply.
+const FieldManager = "rainbond-operator"
+
+// ApplyResource applies obj with server-side apply as FieldManager. The fields owned by other field managers,
+// e.g. spec.replicas of a workload scaled by a HorizontalPodAutoscaler, are dropped from the apply configuration,
+// so they are left to their managers instead of failing the whole apply. The conflicts of fields taken by others
+// in the meantime are returned as an error for which errors.IsConflict returns true.
+// On return, obj holds the state of the object in the API server.
+func ApplyResource(ctx context.Context, cli client.Client, scheme *runtime.Scheme, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return err
+	}
+	// apply requests must specify the kind of the object
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return fmt.Errorf("%T is not a metav1.Object", obj)
+	}
+	meta.SetResourceVersion("")
+	meta.SetManagedFields(nil)
+
+	live := obj.DeepCopyObject()
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()}, live); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		return cli.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager))
+	}
+	data, err := applyConfiguration(obj, live.(metav1.Object).GetManagedFields())
+	if err != nil {
+		return fmt.Errorf("apply configuration of %s %s: %v", gvk.Kind, meta.GetName(), err)
+	}
+	return cli.Patch(ctx, obj, client.ConstantPatch(types.ApplyPatchType, data), client.FieldOwner(FieldManager))
+}
+
+// applyConfiguration returns obj as the apply configuration, without the fields owned by the other field managers.
+// The fields of the first apply after the object was updated without server-side apply, which are owned by
+// before-first-apply, are kept.
+func applyConfiguration(obj runtime.Object, managedFields []metav1.ManagedFieldsEntry) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	var dropped bool
+	for _, entry := range managedFields {
+		if entry.Manager == FieldManager || entry.Manager == "before-first-apply" || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, fmt.Errorf("fields of %s: %v", entry.Manager, err)
+		}
+		dropped = dropFields(config, fields) || dropped
+	}
+	if !dropped {
+		return data, nil
+	}
+	return json.Marshal(config)
+}
+
+// dropFields removes the leaf fields of the managed fields set from obj, and returns whether any is removed.
+// The fields are keyed by "f:<name>" in objects and "k:<key fields>" in the lists of items with keys,
+// the key fields of an item are never removed. Values of sets and items of atomic lists are left as they are.
+func dropFields(obj interface{}, fields map[string]interface{}) bool {
+	var dropped bool
+	for key, value := range fields {
+		children, _ := value.(map[string]interface{})
+		switch {
+		case strings.HasPrefix(key, "f:"):
+			m, ok := obj.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := strings.TrimPrefix(key, "f:")
+			if _, ok := m[name]; !ok {
+				continue
+			}
+			if len(children) == 0 {
+				delete(m, name)
+				dropped = true
+				continue
+			}
+			dropped = dropFields(m[name], children) || dropped
+		case strings.HasPrefix(key, "k:"):
+			list, ok := obj.([]interface{})
+			if !ok {
+				continue
+			}
+			var itemKey map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &itemKey); err != nil {
+				continue
+			}
+			others := make(map[string]interface{}, len(children))
+			for k, v := range children {
+				if _, isKey := itemKey[strings.TrimPrefix(k, "f:")]; !isKey {
+					others[k] = v
+				}
+			}
+			for _, item := range list {
+				if m, ok := item.(map[string]interface{}); ok && hasKey(m, itemKey) {
+					dropped = dropFields(m, others) || dropped
+				}
+			}
+		}
+	}
+	return dropped
+}
+
+// hasKey returns whether the list item has the given key fields.
+func hasKey(item, key map[string]interface{}) bool {
+	for k, v := range key {
+		if !reflect.DeepEqual(item[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// SpecHash returns the hash of the desired state of obj, ignoring the hash annotation.
+func SpecHash(obj runtime.Object) (string, error) {
+	obj = obj.DeepCopyObject()
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return "", fmt.Errorf("%T is not a metav1.Object", obj)
+	}
+	if annotations := meta.GetAnnotations(); annotations != nil {
+		delete(annotations, SpecHashAnnotation)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// setObject overwrites dst with src, which must be pointers to the same type.
+func setObject(dst, src runtime.Object) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+}
+
+// preserveDefaultedFields copies the fields set by the API server, or that can't be updated,
+// from the live object to the desired one, so that the update neither clears nor rejects them.
+func preserveDefaultedFields(desired, live runtime.Object) {
+	switch obj := desired.(type) {
+	case *corev1.Service:
+		old := live.(*corev1.Service)
+		if obj.Spec.ClusterIP == "" {
+			obj.Spec.ClusterIP = old.Spec.ClusterIP
+		}
+		if obj.Spec.HealthCheckNodePort == 0 {
+			obj.Spec.HealthCheckNodePort = old.Spec.HealthCheckNodePort
+		}
+		for i := range obj.Spec.Ports {
+			port := &obj.Spec.Ports[i]
+			if port.NodePort != 0 {
+				continue
+			}
+			for _, oldPort := range old.Spec.Ports {
+				if oldPort.Port == port.Port && (oldPort.Protocol == port.Protocol || port.Protocol == "") {
+					port.NodePort = oldPort.NodePort
+					break
+				}
+			}
+		}
+	case *corev1.PersistentVolumeClaim:
+		obj.Spec = live.(*corev1.PersistentVolumeClaim).Spec
+	case *appsv1.StatefulSet:
+		old := live.(*appsv1.StatefulSet)
+		obj.Spec.Selector = old.Spec.Selector
+		obj.Spec.ServiceName = old.Spec.ServiceName
+		obj.Spec.PodManagementPolicy = old.Spec.PodManagementPolicy
+		obj.Spec.VolumeClaimTemplates = old.Spec.VolumeClaimTemplates
+	case *appsv1.Deployment:
+		obj.Spec.Selector = live.(*appsv1.Deployment).Spec.Selector
+	case *appsv1.DaemonSet:
+		obj.Spec.Selector = live.(*appsv1.DaemonSet).Spec.Selector
+	}
+}
+
 func MustNewKubeConfig(kubeconfigPath string) *rest.Config {
 	if kubeconfigPath != "" {
 		cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
@@ -93,6 +322,16 @@ func HostPath(hostpath corev1.HostPathType) *corev1.HostPathType {
 	return &hostpath
 }
 
+// HasFinalizer checks if the object has the given finalizer.
+func HasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
 func UpdateCRStatus(client client.Client, obj runtime.Object) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
@@ -105,3 +344,59 @@ func UpdateCRStatus(client client.Client, obj runtime.Object) error {
 	}
 	return nil
 }
+
+// GetSecretKey returns the value of the secret key selected by selector in the given namespace.
+// An empty value is returned if the selector is optional and the secret or the key does not exist.
+func GetSecretKey(ctx context.Context, cli client.Client, namespace string, selector *corev1.SecretKeySelector) (string, error) {
+	optional := selector.Optional != nil && *selector.Optional
+	secret := &corev1.Secret{}
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		if errors.IsNotFound(err) && optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("get secret %s/%s: %v", namespace, selector.Name, err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok && !optional {
+		return "", fmt.Errorf("key %s not found in secret %s/%s", selector.Key, namespace, selector.Name)
+	}
+	return string(value), nil
+}
+
+// The group versions of Ingress, from the most preferred.
+var (
+	IngressNetworkingV1      = schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}
+	IngressNetworkingV1beta1 = schema.GroupVersion{Group: "networking.k8s.io", Version: "v1beta1"}
+	IngressExtensionsV1beta1 = schema.GroupVersion{Group: "extensions", Version: "v1beta1"}
+)
+
+// ServedIngressVersion returns the most preferred group version of Ingress served by the API server.
+func ServedIngressVersion(dc discovery.DiscoveryInterface) (schema.GroupVersion, error) {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return schema.GroupVersion{}, fmt.Errorf("get server groups: %v", err)
+	}
+	served := make(map[string]bool)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+
+	for _, gv := range []schema.GroupVersion{IngressNetworkingV1, IngressNetworkingV1beta1, IngressExtensionsV1beta1} {
+		if !served[gv.String()] {
+			continue
+		}
+		// networking.k8s.io/v1 is served without Ingress before Kubernetes 1.19
+		resources, err := dc.ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			return schema.GroupVersion{}, fmt.Errorf("get server resources for %s: %v", gv, err)
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == "ingresses" {
+				return gv, nil
+			}
+		}
+	}
+	return schema.GroupVersion{}, fmt.Errorf("no group version of Ingress is served")
+}
@@ -0,0 +1,313 @@
+package k8sutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newService(port int32) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-api", Namespace: "rbd-system"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: port}},
+		},
+	}
+}
+
+func TestUpdateOrCreateResource(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewFakeClientWithScheme(scheme)
+	log := logf.Log.WithName("test")
+
+	svc := newService(8888)
+	if err := UpdateOrCreateResource(ctx, cli, log, svc, svc); err != nil {
+		t.Fatal(err)
+	}
+	hash := svc.Annotations[SpecHashAnnotation]
+	if hash == "" {
+		t.Fatalf("Expected annotation %s, but got none", SpecHashAnnotation)
+	}
+
+	// the API server allocates the cluster ip
+	live := &corev1.Service{}
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if err := cli.Get(ctx, key, live); err != nil {
+		t.Fatal(err)
+	}
+	live.Spec.ClusterIP = "10.43.0.10"
+	if err := cli.Update(ctx, live); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		port     int32
+		wantHash bool
+	}{
+		{name: "unchanged", port: 8888, wantHash: true},
+		{name: "changed", port: 8443},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newService(tc.port)
+			if err := UpdateOrCreateResource(ctx, cli, log, svc, svc); err != nil {
+				t.Fatal(err)
+			}
+			got := &corev1.Service{}
+			if err := cli.Get(ctx, key, got); err != nil {
+				t.Fatal(err)
+			}
+			if (got.Annotations[SpecHashAnnotation] == hash) != tc.wantHash {
+				t.Errorf("Expected same hash %v, but got %s", tc.wantHash, got.Annotations[SpecHashAnnotation])
+			}
+			if got.Spec.Ports[0].Port != tc.port {
+				t.Errorf("Expected port %d, but got %d", tc.port, got.Spec.Ports[0].Port)
+			}
+			if got.Spec.ClusterIP != "10.43.0.10" {
+				t.Errorf("Expected cluster ip %s, but got %s", "10.43.0.10", got.Spec.ClusterIP)
+			}
+		})
+	}
+}
+
+func TestForceUpdateOrCreateResource(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewFakeClientWithScheme(scheme)
+	log := logf.Log.WithName("test")
+
+	svc := newService(8888)
+	if err := UpdateOrCreateResource(ctx, cli, log, svc, svc); err != nil {
+		t.Fatal(err)
+	}
+	// changed by hand, the spec hash annotation is kept
+	live := &corev1.Service{}
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if err := cli.Get(ctx, key, live); err != nil {
+		t.Fatal(err)
+	}
+	live.Spec.Ports[0].Port = 9999
+	if err := cli.Update(ctx, live); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		updateOrCreate func(context.Context, client.Client, logr.Logger, runtime.Object, metav1.Object) error
+		want           int32
+	}{
+		{name: "not forced", updateOrCreate: UpdateOrCreateResource, want: 9999},
+		{name: "forced", updateOrCreate: ForceUpdateOrCreateResource, want: 8888},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newService(8888)
+			if err := tc.updateOrCreate(ctx, cli, log, svc, svc); err != nil {
+				t.Fatal(err)
+			}
+			got := &corev1.Service{}
+			if err := cli.Get(ctx, key, got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Spec.Ports[0].Port != tc.want {
+				t.Errorf("Expected port %d, but got %d", tc.want, got.Spec.Ports[0].Port)
+			}
+		})
+	}
+}
+
+func TestServedIngressVersion(t *testing.T) {
+	ingresses := []metav1.APIResource{{Name: "ingresses", Kind: "Ingress"}}
+	tests := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		want      schema.GroupVersion
+		wantErr   bool
+	}{
+		{
+			name: "kubernetes 1.16",
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+				{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "networkpolicies", Kind: "NetworkPolicy"}}},
+				{GroupVersion: "networking.k8s.io/v1beta1", APIResources: ingresses},
+			},
+			want: IngressNetworkingV1beta1,
+		},
+		{
+			name: "kubernetes 1.22",
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "networking.k8s.io/v1", APIResources: ingresses},
+			},
+			want: IngressNetworkingV1,
+		},
+		{
+			name: "extensions only",
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+			},
+			want: IngressExtensionsV1beta1,
+		},
+		{
+			name:    "no ingress",
+			wantErr: true,
+		},
+	}
+
+	for idx := range tests {
+		tc := tests[idx]
+		t.Run(tc.name, func(t *testing.T) {
+			dc := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: tc.resources}}
+			got, err := ServedIngressVersion(dc)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected %s, but got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// applyClient emulates server-side apply on top of the fake client, which does not support it:
+// applying a field owned by another manager is a conflict, the fields left out of the apply keep their values.
+type applyClient struct {
+	client.Client
+	applied []byte
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	c.applied = data
+	var applied map[string]interface{}
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return err
+	}
+	live := &appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "rbd-system", Name: "rbd-api"}, live); err != nil {
+		return err
+	}
+	spec, _ := applied["spec"].(map[string]interface{})
+	if _, ok := spec["replicas"]; ok && len(live.ManagedFields) > 0 {
+		return errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, live.Name, fmt.Errorf("conflict with %q: .spec.replicas", live.ManagedFields[0].Manager))
+	}
+	desired := obj.(*appsv1.Deployment)
+	live.Spec.Template = desired.Spec.Template
+	if err := c.Update(ctx, live); err != nil {
+		return err
+	}
+	live.DeepCopyInto(desired)
+	return nil
+}
+
+func TestApplyResource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newDeployment := func(replicas int32, image string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbd-api", Namespace: "rbd-system"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "rbd-api", Image: image}}},
+				},
+			},
+		}
+	}
+	live := newDeployment(3, "rbd-api:v1")
+	live.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationApply, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"rbd-api\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`)}},
+		{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+	}
+	cli := &applyClient{Client: fake.NewFakeClientWithScheme(scheme, live)}
+
+	desired := newDeployment(1, "rbd-api:v2")
+	if err := ApplyResource(context.Background(), cli, scheme, desired); err != nil {
+		t.Fatalf("Expected the fields owned by others to be left out of the apply, but got %v", err)
+	}
+	got := &appsv1.Deployment{}
+	if err := cli.Get(context.Background(), types.NamespacedName{Namespace: "rbd-system", Name: "rbd-api"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if image := got.Spec.Template.Spec.Containers[0].Image; image != "rbd-api:v2" {
+		t.Errorf("Expected %v, but got %v", "rbd-api:v2", image)
+	}
+	if *got.Spec.Replicas != 3 {
+		t.Errorf("Expected %v, but got %v", 3, *got.Spec.Replicas)
+	}
+	if !strings.Contains(string(cli.applied), `"name":"rbd-api"`) {
+		t.Errorf("Expected the key of the container to be applied, but got %s", cli.applied)
+	}
+}
+
+func TestDropFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    string
+		fields string
+		want   string
+	}{
+		{
+			name:   "leaf field",
+			obj:    `{"spec":{"replicas":1,"paused":false}}`,
+			fields: `{"f:spec":{"f:replicas":{}}}`,
+			want:   `{"spec":{"paused":false}}`,
+		},
+		{
+			name:   "field of a keyed item",
+			obj:    `{"containers":[{"name":"a","image":"a:v1","resources":{"limits":{"cpu":"1"}}},{"name":"b","image":"b:v1","resources":{}}]}`,
+			fields: `{"f:containers":{"k:{\"name\":\"a\"}":{".":{},"f:name":{},"f:resources":{"f:limits":{"f:cpu":{}}}}}}`,
+			want:   `{"containers":[{"image":"a:v1","name":"a","resources":{"limits":{}}},{"image":"b:v1","name":"b","resources":{}}]}`,
+		},
+		{
+			name:   "missing field",
+			obj:    `{"spec":{"paused":false}}`,
+			fields: `{"f:spec":{"f:replicas":{}},"f:status":{}}`,
+			want:   `{"spec":{"paused":false}}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var obj, fields map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.obj), &obj); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.fields), &fields); err != nil {
+				t.Fatal(err)
+			}
+			dropFields(obj, fields)
+			if got, _ := json.Marshal(obj); string(got) != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, string(got))
+			}
+		})
+	}
+}
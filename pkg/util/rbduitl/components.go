@@ -0,0 +1,103 @@
+package rbdutil
+
+import (
+	"strings"
+
+	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/constants"
+)
+
+type componentClaim struct {
+	name      string
+	version   string
+	image     string
+	logLevel  string
+	configs   map[string]string
+	isInit    bool
+	dependsOn []string
+}
+
+// rbdVersion is replaced with the install version of the rainbondcluster when the components are created.
+var rbdVersion = constants.DefInstallVersion
+
+// TODO: custom domain
+var existHubDomain = "registry.cn-hangzhou.aliyuncs.com/goodrain"
+
+var componentClaims = []componentClaim{
+	{name: "rbd-etcd", image: existHubDomain + "/etcd:v3.3.18", isInit: true},
+	{name: "rbd-gateway", image: existHubDomain + "/rbd-gateway:" + rbdVersion, version: rbdVersion, isInit: true, dependsOn: []string{"rbd-etcd"}},
+	{name: "rbd-hub", image: existHubDomain + "/registry:2.6.2", isInit: true},
+	{name: "rbd-node", image: existHubDomain + "/rbd-node:" + rbdVersion, version: rbdVersion, isInit: true, dependsOn: []string{"rbd-etcd"}},
+	{name: "rbd-nfs", image: existHubDomain + "/nfs-provisioner:v2.2.1-k8s1.12", isInit: true},
+	{name: "rbd-api", image: "goodrain.me/rbd-api:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-db", "rbd-etcd"}},
+	{name: "rbd-app-ui", image: "goodrain.me/rbd-app-ui:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-db"}},
+	{name: "rbd-chaos", image: "goodrain.me/rbd-chaos:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-db", "rbd-etcd"}},
+	{name: "rbd-db", image: "goodrain.me/rbd-db:v5.1.9"},
+	{name: "rbd-dns", image: "goodrain.me/rbd-dns"},
+	{name: "rbd-eventlog", image: "goodrain.me/rbd-eventlog:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-db", "rbd-etcd"}},
+	{name: "rbd-monitor", image: "goodrain.me/rbd-monitor:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-etcd"}},
+	{name: "rbd-mq", image: "goodrain.me/rbd-mq:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-etcd"}},
+	{name: "rbd-worker", image: "goodrain.me/rbd-worker:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-db", "rbd-etcd"}},
+	{name: "rbd-webcli", image: "goodrain.me/rbd-webcli:" + rbdVersion, version: rbdVersion, dependsOn: []string{"rbd-etcd"}},
+	{name: "rbd-repo", image: "goodrain.me/rbd-repo:6.16.0"},
+	{name: "metrics-server", image: "goodrain.me/metrics-server:v0.3.6"},
+}
+
+// InstallComponents returns the RbdComponents created by the installation of the rainbondcluster.
+func InstallComponents(cluster *v1alpha1.RainbondCluster) []*v1alpha1.RbdComponent {
+	version := GetInstallVersion(cluster)
+	var components []*v1alpha1.RbdComponent
+	for _, claim := range componentClaims {
+		claim = claim.withVersion(version)
+		claim.dependsOn = withoutExternalDependencies(claim.dependsOn, cluster)
+		component := claim.rbdComponent()
+		component.Namespace = cluster.Namespace
+		component.Spec.ClusterName = cluster.Name
+		components = append(components, component)
+	}
+	return components
+}
+
+// withVersion replaces the tag of the image of a versioned component with the given version.
+func (c componentClaim) withVersion(version string) componentClaim {
+	if c.version == "" {
+		return c
+	}
+	c.image = strings.TrimSuffix(c.image, ":"+c.version) + ":" + version
+	c.version = version
+	return c
+}
+
+func (c componentClaim) rbdComponent() *v1alpha1.RbdComponent {
+	component := &v1alpha1.RbdComponent{}
+	component.Name = c.name
+	component.Spec.Version = c.version
+	component.Spec.Image = c.image
+	component.Spec.Configs = c.configs
+	component.Spec.LogLevel = v1alpha1.ParseLogLevel(c.logLevel)
+	component.Spec.Type = c.name
+	labels := map[string]string{"name": c.name}
+	if c.isInit {
+		component.Spec.PriorityComponent = true
+		labels["priorityComponent"] = "true"
+	}
+	component.Spec.DependsOn = c.dependsOn
+	component.Labels = labels
+	return component
+}
+
+// withoutExternalDependencies removes the components that are not deployed, because the cluster uses
+// the database or etcd outside of it.
+func withoutExternalDependencies(dependsOn []string, cluster *v1alpha1.RainbondCluster) []string {
+	var deps []string
+	for _, dep := range dependsOn {
+		if dep == "rbd-db" && cluster.Spec.RegionDatabase != nil && cluster.Spec.UIDatabase != nil {
+			continue
+		}
+		if dep == "rbd-etcd" && cluster.Spec.EtcdConfig != nil {
+			continue
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
@@ -1,9 +1,19 @@
 package rbdutil
 
 import (
+	"context"
+	"fmt"
+	"path"
+
 	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
 	"github.com/goodrain/rainbond-operator/pkg/util/constants"
-	"path"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Labels map[string]string
@@ -28,11 +38,20 @@ func LabelsForRainbondResource() map[string]string {
 // GetStorageClass returns storage class name based on rainbondcluster.
 func GetStorageClass(cluster *v1alpha1.RainbondCluster) string {
 	if cluster.Spec.StorageClassName == "" {
-		return constants.DefStorageClass
+		return DefaultStorageClass(cluster.Namespace)
 	}
 	return cluster.Spec.StorageClassName
 }
 
+// DefaultStorageClass returns the name of the storage class created by rbd-nfs in the namespace.
+// The storage classes are cluster scoped, so the one of each region is named after its namespace.
+func DefaultStorageClass(namespace string) string {
+	if namespace == constants.LegacyNamespace {
+		return constants.DefStorageClass
+	}
+	return constants.DefStorageClass + "-" + namespace
+}
+
 // GetImageRepository returns image repository name based on rainbondcluster.
 func GetImageRepository(cluster *v1alpha1.RainbondCluster) string {
 	if cluster.Spec.ImageHub == nil {
@@ -40,3 +59,98 @@ func GetImageRepository(cluster *v1alpha1.RainbondCluster) string {
 	}
 	return path.Join(cluster.Spec.ImageHub.Domain, cluster.Spec.ImageHub.Namespace)
 }
+
+// GetInstallVersion returns the version of Rainbond to install based on rainbondcluster.
+func GetInstallVersion(cluster *v1alpha1.RainbondCluster) string {
+	if cluster.Spec.InstallVersion == "" {
+		return constants.DefInstallVersion
+	}
+	return cluster.Spec.InstallVersion
+}
+
+// GetRainbondPackage returns the rainbondpackage that belongs to the given rainbondcluster.
+func GetRainbondPackage(ctx context.Context, cli client.Client, cluster *v1alpha1.RainbondCluster) (*v1alpha1.RainbondPackage, error) {
+	pkgs := &v1alpha1.RainbondPackageList{}
+	if err := cli.List(ctx, pkgs, client.InNamespace(cluster.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range pkgs.Items {
+		if pkgs.Items[i].RainbondClusterName() == cluster.Name {
+			return &pkgs.Items[i], nil
+		}
+	}
+	return nil, k8sErrors.NewNotFound(v1alpha1.Resource("rainbondpackage"), cluster.Name)
+}
+
+// SavePasswordSecrets creates or updates the secrets of the passwords moved by RainbondClusterSpec.MoveInlinePasswords.
+// The secrets already holding the passwords are left as they are.
+func SavePasswordSecrets(ctx context.Context, cli client.Client, namespace string, passwords map[string]string) error {
+	for name, password := range passwords {
+		secret := &corev1.Secret{}
+		err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get secret %s: %v", name, err)
+		}
+		exists := err == nil
+		if exists && string(secret.Data[v1alpha1.PasswordSecretKey]) == password {
+			continue
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[v1alpha1.PasswordSecretKey] = []byte(password)
+		if exists {
+			err = cli.Update(ctx, secret)
+		} else {
+			secret.ObjectMeta = metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: LabelsForRainbondResource()}
+			err = cli.Create(ctx, secret)
+		}
+		if err != nil {
+			return fmt.Errorf("save secret %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ClusterClaims returns the claims shared by the components of the rainbondcluster.
+func ClusterClaims(cluster *v1alpha1.RainbondCluster) []*corev1.PersistentVolumeClaim {
+	storageRequest := resource.NewQuantity(10, resource.BinarySI) // TODO: size
+
+	grdata := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      constants.GrDataPVC,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				corev1.ReadWriteMany,
+			},
+			Resources: corev1.ResourceRequirements{
+				Requests: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceStorage: *storageRequest,
+				},
+			},
+			StorageClassName: commonutil.String(GetStorageClass(cluster)),
+		},
+	}
+
+	cache := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      "cache",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				corev1.ReadWriteMany,
+			},
+			Resources: corev1.ResourceRequirements{
+				Requests: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceStorage: *storageRequest,
+				},
+			},
+			StorageClassName: commonutil.String(GetStorageClass(cluster)),
+		},
+	}
+
+	return []*corev1.PersistentVolumeClaim{grdata, cache}
+}
@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rainbondv1beta1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+)
+
+var log = logf.Log.WithName("webhook")
+
+const (
+	// ServiceName is the name of the service in front of the webhook server.
+	ServiceName = "rainbond-operator-webhook"
+	// CertSecretName is the name of the secret that keeps the self-signed certificate of the webhook server across restarts.
+	CertSecretName = "rainbond-operator-webhook-cert"
+	// webhookConfigurationName is the name of the webhook configurations in deploy/webhook.yaml.
+	webhookConfigurationName = "rainbond-operator"
+	// certRenewBefore is how long before it expires the serving certificate is regenerated.
+	certRenewBefore = 30 * 24 * time.Hour
+)
+
+// crdNames are the CRDs converted by the conversion webhook.
+var crdNames = []string{"rainbondclusters.rainbond.io", "rbdcomponents.rainbond.io", "rainbondpackages.rainbond.io"}
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+
+// Bootstrap provisions the serving certificate of the webhook server and points the webhooks at it.
+// The certificate is self-signed, kept in the secret CertSecretName in namespace and written to certDir.
+// The conversion of rainbond.io CRDs is switched to Webhook with v1beta1 as the storage version, and the caBundle and the service namespace
+// of the CRDs and the webhook configurations are set to those of the running operator.
+// cli must not be backed by the cache of the manager, which is not started yet.
+func Bootstrap(ctx context.Context, cli client.Client, namespace, certDir string) error {
+	caPem, certPem, keyPem, err := ensureCertSecret(ctx, cli, namespace)
+	if err != nil {
+		return fmt.Errorf("ensure webhook certificate: %v", err)
+	}
+	if err := writeCerts(certDir, certPem, keyPem); err != nil {
+		return fmt.Errorf("write webhook certificate: %v", err)
+	}
+
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhookClientConfig": map[string]interface{}{
+			"caBundle": base64.StdEncoding.EncodeToString(caPem),
+			"service": map[string]interface{}{
+				"name":      ServiceName,
+				"namespace": namespace,
+				"path":      convertPath,
+			},
+		},
+	}
+	if err := setConversion(ctx, cli, conversion, rainbondv1beta1.SchemeGroupVersion.Version); err != nil {
+		return err
+	}
+	return injectWebhookConfigurations(ctx, cli, namespace, caPem)
+}
+
+// DisableConversion sets the conversion of rainbond.io CRDs back to None and v1alpha1 as the storage version,
+// which is used when the webhooks are disabled. Otherwise every request to rainbond.io fails once the webhook server
+// stops serving. It fails if any object has been stored as v1beta1, which can only be read back through the webhook.
+func DisableConversion(ctx context.Context, cli client.Client) error {
+	return setConversion(ctx, cli, map[string]interface{}{"strategy": "None"}, rainbondv1alpha1.SchemeGroupVersion.Version)
+}
+
+// setConversion sets the conversion and the storage version of rainbond.io CRDs. v1beta1 is only served
+// along with the conversion webhook, and the conversion is never None while v1beta1 is the storage version.
+func setConversion(ctx context.Context, cli client.Client, conversion map[string]interface{}, storageVersion string) error {
+	beta := rainbondv1beta1.SchemeGroupVersion.Version
+	webhook := conversion["strategy"] == "Webhook"
+	for _, name := range crdNames {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(crdGVK)
+		if err := cli.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+			if k8sErrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("get crd %s: %v", name, err)
+		}
+		if !webhook {
+			storedVersions, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+			for _, version := range storedVersions {
+				if version == beta {
+					return fmt.Errorf("objects of crd %s are stored as %s, which are only read through the conversion webhook: "+
+						"start with --enable-webhooks", name, beta)
+				}
+			}
+		}
+		if err := unstructured.SetNestedMap(crd.Object, conversion, "spec", "conversion"); err != nil {
+			return fmt.Errorf("set conversion of crd %s: %v", name, err)
+		}
+		versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		if err != nil {
+			return fmt.Errorf("get versions of crd %s: %v", name, err)
+		}
+		for _, v := range versions {
+			version, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			version["storage"] = version["name"] == storageVersion
+			if version["name"] == beta {
+				version["served"] = webhook
+			}
+		}
+		if err := unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"); err != nil {
+			return fmt.Errorf("set versions of crd %s: %v", name, err)
+		}
+		if err := cli.Update(ctx, crd); err != nil {
+			return fmt.Errorf("update conversion of crd %s: %v", name, err)
+		}
+		log.Info("Set conversion of crd", "Name", name, "Strategy", conversion["strategy"], "StorageVersion", storageVersion)
+	}
+	return nil
+}
+
+// injectWebhookConfigurations sets the caBundle and the service namespace of the webhook configurations, if they are applied.
+func injectWebhookConfigurations(ctx context.Context, cli client.Client, namespace string, caPem []byte) error {
+	inject := func(cfg *admissionregistrationv1beta1.WebhookClientConfig) {
+		cfg.CABundle = caPem
+		if cfg.Service != nil {
+			cfg.Service.Namespace = namespace
+		}
+	}
+	key := types.NamespacedName{Name: webhookConfigurationName}
+
+	mutating := &admissionregistrationv1beta1.MutatingWebhookConfiguration{}
+	if err := cli.Get(ctx, key, mutating); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get mutating webhook configuration: %v", err)
+		}
+	} else {
+		for i := range mutating.Webhooks {
+			inject(&mutating.Webhooks[i].ClientConfig)
+		}
+		if err := cli.Update(ctx, mutating); err != nil {
+			return fmt.Errorf("update mutating webhook configuration: %v", err)
+		}
+	}
+
+	validating := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{}
+	if err := cli.Get(ctx, key, validating); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("get validating webhook configuration: %v", err)
+		}
+		return nil
+	}
+	for i := range validating.Webhooks {
+		inject(&validating.Webhooks[i].ClientConfig)
+	}
+	if err := cli.Update(ctx, validating); err != nil {
+		return fmt.Errorf("update validating webhook configuration: %v", err)
+	}
+	return nil
+}
+
+// ensureCertSecret returns the certificate in the secret CertSecretName, which is regenerated if it is missing,
+// about to expire or issued for another namespace.
+func ensureCertSecret(ctx context.Context, cli client.Client, namespace string) (caPem, certPem, keyPem []byte, err error) {
+	host := fmt.Sprintf("%s.%s.svc", ServiceName, namespace)
+	secret := &corev1.Secret{}
+	err = cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: CertSecretName}, secret)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, nil, nil, err
+	}
+	if err == nil && certValid(secret.Data[corev1.TLSCertKey], host, time.Now()) {
+		return secret.Data["ca.crt"], secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], nil
+	}
+	exists := err == nil
+
+	caPem, certPem, keyPem, err = commonutil.DomainSign(nil, ServiceName, ServiceName+"."+namespace, host, host+".cluster.local")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	secret.Type = corev1.SecretTypeOpaque
+	secret.Data = map[string][]byte{
+		"ca.crt":                caPem,
+		corev1.TLSCertKey:       certPem,
+		corev1.TLSPrivateKeyKey: keyPem,
+	}
+	if exists {
+		err = cli.Update(ctx, secret)
+	} else {
+		secret.ObjectMeta = metav1.ObjectMeta{Namespace: namespace, Name: CertSecretName}
+		err = cli.Create(ctx, secret)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Info("Generated webhook certificate", "Host", host)
+	return caPem, certPem, keyPem, nil
+}
+
+// certValid returns whether the certificate is issued for host and does not expire soon.
+func certValid(certPem []byte, host string, now time.Time) bool {
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	if now.Add(certRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+	return cert.VerifyHostname(host) == nil
+}
+
+func writeCerts(dir string, certPem, keyPem []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, corev1.TLSCertKey), certPem, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, corev1.TLSPrivateKeyKey), keyPem, 0600)
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEnsureCertSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewFakeClientWithScheme(scheme)
+	ctx := context.Background()
+
+	caPem, certPem, _, err := ensureCertSecret(ctx, cli, "rbd-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tests := []struct {
+		name string
+		host string
+		now  time.Time
+		want bool
+	}{
+		{name: "valid", host: ServiceName + ".rbd-system.svc", now: now, want: true},
+		{name: "another namespace", host: ServiceName + ".rbd-other.svc", now: now},
+		{name: "about to expire", host: ServiceName + ".rbd-system.svc", now: now.AddDate(99, 0, 0)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := certValid(certPem, tc.host, tc.now); got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+
+	reusedCA, _, _, err := ensureCertSecret(ctx, cli, "rbd-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reusedCA, caPem) {
+		t.Errorf("Expected the certificate in the secret to be reused, but got a new one")
+	}
+}
+
+func TestSetConversion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := apiextensionsv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newCRD := func(storedVersions ...string) *apiextensionsv1beta1.CustomResourceDefinition {
+		return &apiextensionsv1beta1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "rainbondclusters.rainbond.io"},
+			Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+				Versions: []apiextensionsv1beta1.CustomResourceDefinitionVersion{
+					{Name: "v1beta1"},
+					{Name: "v1alpha1", Served: true, Storage: true},
+				},
+			},
+			Status: apiextensionsv1beta1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+		}
+	}
+	webhook := map[string]interface{}{"strategy": "Webhook"}
+	tests := []struct {
+		name          string
+		crd           *apiextensionsv1beta1.CustomResourceDefinition
+		enable        bool
+		wantErr       bool
+		wantStrategy  apiextensionsv1beta1.ConversionStrategyType
+		wantBetaStore bool
+	}{
+		{name: "enable", crd: newCRD("v1alpha1"), enable: true, wantStrategy: apiextensionsv1beta1.WebhookConverter, wantBetaStore: true},
+		{name: "disable", crd: newCRD("v1alpha1"), wantStrategy: apiextensionsv1beta1.NoneConverter},
+		{name: "disable with objects stored as v1beta1", crd: newCRD("v1alpha1", "v1beta1"), wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewFakeClientWithScheme(scheme, tc.crd)
+			var err error
+			if tc.enable {
+				err = setConversion(context.Background(), cli, webhook, "v1beta1")
+			} else {
+				err = DisableConversion(context.Background(), cli)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			crd := &apiextensionsv1beta1.CustomResourceDefinition{}
+			if err := cli.Get(context.Background(), types.NamespacedName{Name: tc.crd.Name}, crd); err != nil {
+				t.Fatal(err)
+			}
+			if crd.Spec.Conversion == nil || crd.Spec.Conversion.Strategy != tc.wantStrategy {
+				t.Errorf("Expected strategy %v, but got %v", tc.wantStrategy, crd.Spec.Conversion)
+			}
+			beta, alpha := crd.Spec.Versions[0], crd.Spec.Versions[1]
+			if beta.Storage != tc.wantBetaStore || alpha.Storage == tc.wantBetaStore {
+				t.Errorf("Expected v1beta1 storage %v, but got v1beta1 %v and v1alpha1 %v", tc.wantBetaStore, beta.Storage, alpha.Storage)
+			}
+			if beta.Served != tc.wantBetaStore {
+				t.Errorf("Expected v1beta1 served %v, but got %v", tc.wantBetaStore, beta.Served)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	apix "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rainbondv1beta1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+// converter is the conversion webhook of rainbond.io CRDs. RainbondCluster.ConvertTo replaces the inline passwords
+// with references to secrets, so the secrets are saved before any RainbondCluster is converted to v1beta1.
+type converter struct {
+	*conversion.Webhook
+	client client.Client
+}
+
+// InjectClient injects the client.
+func (c *converter) InjectClient(cli client.Client) error {
+	c.client = cli
+	return nil
+}
+
+func (c *converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err, "failed to read conversion request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := c.saveInlinePasswords(r.Context(), body); err != nil {
+		log.Error(err, "failed to save inline passwords")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.Webhook.ServeHTTP(w, r)
+}
+
+// saveInlinePasswords saves the inline passwords of the v1alpha1 RainbondClusters to be converted to v1beta1.
+func (c *converter) saveInlinePasswords(ctx context.Context, body []byte) error {
+	review := &apix.ConversionReview{}
+	if err := json.Unmarshal(body, review); err != nil {
+		// left to the conversion webhook to answer
+		return nil
+	}
+	if review.Request == nil || review.Request.DesiredAPIVersion != rainbondv1beta1.SchemeGroupVersion.String() {
+		return nil
+	}
+	for _, obj := range review.Request.Objects {
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(obj.Raw, &typeMeta); err != nil {
+			return nil
+		}
+		if typeMeta.APIVersion != rainbondv1alpha1.SchemeGroupVersion.String() || typeMeta.Kind != "RainbondCluster" {
+			continue
+		}
+		cluster := &rainbondv1alpha1.RainbondCluster{}
+		if err := json.Unmarshal(obj.Raw, cluster); err != nil {
+			return nil
+		}
+		passwords := cluster.Spec.MoveInlinePasswords()
+		if err := rbdutil.SavePasswordSecrets(ctx, c.client, cluster.Namespace, passwords); err != nil {
+			return fmt.Errorf("rainbondcluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		}
+	}
+	return nil
+}
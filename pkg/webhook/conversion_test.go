@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apix "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	rainbondv1beta1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1beta1"
+)
+
+func TestConverterSavesInlinePasswords(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, rainbondv1alpha1.SchemeBuilder.AddToScheme, rainbondv1beta1.SchemeBuilder.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cli := fake.NewFakeClientWithScheme(scheme)
+	c := &converter{Webhook: &conversion.Webhook{}}
+	if err := c.InjectScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InjectClient(cli); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &rainbondv1alpha1.RainbondCluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rainbondv1alpha1.SchemeGroupVersion.String(), Kind: "RainbondCluster"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: "rainbondcluster"},
+		Spec: rainbondv1alpha1.RainbondClusterSpec{
+			ImageHub: &rainbondv1alpha1.ImageHub{Domain: "hub.example.com", Password: "secret"},
+		},
+	}
+	raw, _ := json.Marshal(cluster)
+	body, _ := json.Marshal(&apix.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: apix.SchemeGroupVersion.String(), Kind: "ConversionReview"},
+		Request: &apix.ConversionRequest{
+			UID:               "uid",
+			DesiredAPIVersion: rainbondv1beta1.SchemeGroupVersion.String(),
+			Objects:           []runtime.RawExtension{{Raw: raw}},
+		},
+	})
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodPost, convertPath, bytes.NewReader(body)))
+
+	review := &apix.ConversionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+		t.Fatal(err)
+	}
+	if review.Response == nil || review.Response.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("Expected the conversion to succeed, but got %+v", review.Response)
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: "rbd-system", Name: rainbondv1alpha1.ImageHubPasswordSecretName}
+	if err := cli.Get(context.Background(), key, secret); err != nil {
+		t.Fatalf("Expected the secret referenced by the converted object, but got %v", err)
+	}
+	if got := string(secret.Data[rainbondv1alpha1.PasswordSecretKey]); got != "secret" {
+		t.Errorf("Expected %v, but got %v", "secret", got)
+	}
+}
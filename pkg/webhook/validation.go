@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1/validation"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	rbdutil "github.com/goodrain/rainbond-operator/pkg/util/rbduitl"
+)
+
+func newRainbondCluster() runtime.Object {
+	return &rainbondv1alpha1.RainbondCluster{}
+}
+
+func newRbdComponent() runtime.Object {
+	return &rainbondv1alpha1.RbdComponent{}
+}
+
+func newRainbondPackage() runtime.Object {
+	return &rainbondv1alpha1.RainbondPackage{}
+}
+
+// moveInlinePasswords moves the inline passwords of the RainbondCluster to secrets,
+// before the RainbondCluster is converted to v1beta1, which only references passwords in secrets.
+func moveInlinePasswords(ctx context.Context, cli client.Client, obj runtime.Object, dryRun bool) error {
+	cluster := obj.(*rainbondv1alpha1.RainbondCluster)
+	passwords := cluster.Spec.MoveInlinePasswords()
+	if dryRun {
+		return nil
+	}
+	return rbdutil.SavePasswordSecrets(ctx, cli, cluster.Namespace, passwords)
+}
+
+func validateRainbondCluster(obj runtime.Object) field.ErrorList {
+	return validation.ValidateRainbondCluster(obj.(*rainbondv1alpha1.RainbondCluster))
+}
+
+func validateRbdComponent(obj runtime.Object) field.ErrorList {
+	cpt := obj.(*rainbondv1alpha1.RbdComponent)
+	allErrs := validation.ValidateRbdComponent(cpt)
+	if cpt.Spec.Type != rainbondv1alpha1.TemplateComponentType && !rbdcomponent.IsSupported(cpt.Name) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "name"), cpt.Name, rbdcomponent.SupportedComponents()))
+	}
+	return allErrs
+}
+
+// validateRbdComponentUpdate rejects the changes the handlers can not apply to an existing component.
+func validateRbdComponentUpdate(obj, old runtime.Object) field.ErrorList {
+	var allErrs field.ErrorList
+	cpt, oldCpt := obj.(*rainbondv1alpha1.RbdComponent), old.(*rainbondv1alpha1.RbdComponent)
+	if cpt.Name == handler.DBName && (cpt.Replicas() > 1) != (oldCpt.Replicas() > 1) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "replicas"), handler.ErrDBModeChanged.Error()))
+	}
+	if cpt.Name == handler.EtcdName && cpt.Replicas() != oldCpt.Replicas() {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "replicas"), handler.ErrEtcdMembersChanged.Error()))
+	}
+	return allErrs
+}
+
+func validateRainbondPackage(obj runtime.Object) field.ErrorList {
+	return validation.ValidateRainbondPackage(obj.(*rainbondv1alpha1.RainbondPackage))
+}
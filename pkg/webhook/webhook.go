@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// The paths of webhooks, which must match the webhook configurations in deploy/webhook.yaml.
+// convertPath is set on the CRDs by Bootstrap.
+const (
+	convertPath                 = "/convert"
+	mutateRainbondClusterPath   = "/mutate-rainbond-io-v1alpha1-rainbondcluster"
+	validateRainbondClusterPath = "/validate-rainbond-io-v1alpha1-rainbondcluster"
+	mutateRbdComponentPath      = "/mutate-rainbond-io-v1alpha1-rbdcomponent"
+	validateRbdComponentPath    = "/validate-rainbond-io-v1alpha1-rbdcomponent"
+	validateRainbondPackagePath = "/validate-rainbond-io-v1alpha1-rainbondpackage"
+)
+
+// AddToManager registers the conversion, validating and defaulting webhooks of rainbond.io CRDs to the webhook server of the Manager.
+func AddToManager(m manager.Manager) error {
+	hooks := map[string]admission.Handler{
+		mutateRainbondClusterPath:   &defaulter{newObject: newRainbondCluster, save: moveInlinePasswords},
+		validateRainbondClusterPath: &validator{newObject: newRainbondCluster, validate: validateRainbondCluster},
+		mutateRbdComponentPath:      &defaulter{newObject: newRbdComponent},
+		validateRbdComponentPath:    &validator{newObject: newRbdComponent, validate: validateRbdComponent, validateUpdate: validateRbdComponentUpdate},
+		validateRainbondPackagePath: &validator{newObject: newRainbondPackage, validate: validateRainbondPackage},
+	}
+	server := m.GetWebhookServer()
+	server.Register(convertPath, &converter{Webhook: &conversion.Webhook{}})
+	for path, hdl := range hooks {
+		server.Register(path, &webhook.Admission{Handler: hdl})
+	}
+	return nil
+}
+
+// defaultable is an object that can fill in its default values.
+type defaultable interface {
+	runtime.Object
+	Default()
+}
+
+// defaulter is an admission.Handler that fills in the default values of the object in the request.
+// save, if set, changes the object further and saves what the changes refer to, unless dryRun is set.
+type defaulter struct {
+	newObject func() runtime.Object
+	save      func(ctx context.Context, cli client.Client, obj runtime.Object, dryRun bool) error
+	client    client.Client
+	decoder   *admission.Decoder
+}
+
+// InjectDecoder injects the decoder.
+func (d *defaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// InjectClient injects the client.
+func (d *defaulter) InjectClient(c client.Client) error {
+	d.client = c
+	return nil
+}
+
+// Handle handles admission requests.
+func (d *defaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := d.newObject()
+	if err := d.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	obj.(defaultable).Default()
+	if d.save != nil {
+		if err := d.save(ctx, d.client, obj, req.DryRun != nil && *req.DryRun); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+	marshalled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+}
+
+// validator is an admission.Handler that validates the object in the request.
+// validateUpdate, if set, validates the changes to the old object on update.
+type validator struct {
+	newObject      func() runtime.Object
+	validate       func(obj runtime.Object) field.ErrorList
+	validateUpdate func(obj, old runtime.Object) field.ErrorList
+	decoder        *admission.Decoder
+}
+
+// InjectDecoder injects the decoder.
+func (v *validator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle handles admission requests.
+func (v *validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := v.newObject()
+	if err := v.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		// objects created before a validation was added must stay deletable and their status updatable
+		if meta, ok := obj.(metav1.Object); ok && meta.GetDeletionTimestamp() != nil {
+			return admission.Allowed("being deleted")
+		}
+		if specUnchanged(req) {
+			return admission.Allowed("spec unchanged")
+		}
+	}
+
+	errs := v.validate(obj)
+	if req.Operation == admissionv1beta1.Update && v.validateUpdate != nil {
+		old := v.newObject()
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = append(errs, v.validateUpdate(obj, old)...)
+	}
+	if len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+// specUnchanged returns whether the update in the request leaves the spec of the object as it is.
+func specUnchanged(req admission.Request) bool {
+	var obj, old struct {
+		Spec interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(obj.Spec, old.Spec)
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	rainbondv1alpha1 "github.com/goodrain/rainbond-operator/pkg/apis/rainbond/v1alpha1"
+	"github.com/goodrain/rainbond-operator/pkg/controller/rbdcomponent/handler"
+	"github.com/goodrain/rainbond-operator/pkg/util/commonutil"
+)
+
+func TestValidatorHandle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rainbondv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &validator{newObject: newRbdComponent, validate: validateRbdComponent, validateUpdate: validateRbdComponentUpdate}
+	if err := v.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	// a legacy object that no longer passes the validation
+	legacy := &rainbondv1alpha1.RbdComponent{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rainbondv1alpha1.SchemeGroupVersion.String(), Kind: "RbdComponent"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "rbd-system", Name: handler.APIName},
+		Spec:       rainbondv1alpha1.RbdComponentSpec{Replicas: commonutil.Int32(-1)},
+	}
+	deleting := legacy.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	labeled := legacy.DeepCopy()
+	labeled.Labels = map[string]string{"foo": "bar"}
+	changed := legacy.DeepCopy()
+	changed.Spec.LogLevel = rainbondv1alpha1.LogLevelDebug
+
+	tests := []struct {
+		name      string
+		operation admissionv1beta1.Operation
+		obj       *rainbondv1alpha1.RbdComponent
+		old       *rainbondv1alpha1.RbdComponent
+		want      bool
+	}{
+		{name: "create", operation: admissionv1beta1.Create, obj: legacy},
+		{name: "being deleted", operation: admissionv1beta1.Update, obj: deleting, old: legacy, want: true},
+		{name: "spec unchanged", operation: admissionv1beta1.Update, obj: labeled, old: legacy, want: true},
+		{name: "spec changed", operation: admissionv1beta1.Update, obj: changed, old: legacy},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{Operation: tc.operation}}
+			req.Object.Raw, _ = json.Marshal(tc.obj)
+			if tc.old != nil {
+				req.OldObject.Raw, _ = json.Marshal(tc.old)
+			}
+			if got := v.Handle(context.Background(), req).Allowed; got != tc.want {
+				t.Errorf("Expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}